@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/spf13/cobra"
+)
+
+var attestCmd = &cobra.Command{
+	Use:   "attest",
+	Short: "Attestation commands",
+}
+
+var attestMatchCmd = &cobra.Command{
+	Use:   "match <file>",
+	Short: "Check if local artifacts cover the subjects of the passed attestation",
+	Long: `Hashes local artifacts with the digest algorithms used by the subjects of
+the Statement in the passed DSSE envelope, and reports covered, mismatched and
+uncovered subjects. A subject is covered if a local artifact has its digest,
+and mismatched if it is not covered but a local artifact has its name. Exits
+with a non-zero status if any subject is not covered.`,
+	Args: cobra.ExactArgs(1),
+	RunE: attestMatch,
+}
+
+func init() {
+	rootCmd.AddCommand(attestCmd)
+	attestCmd.AddCommand(attestMatchCmd)
+
+	attestMatchCmd.Flags().StringArrayVarP(
+		&paths,
+		"path",
+		"p",
+		[]string{"."},
+		"file or directory paths to local artifacts, default is CWD",
+	)
+
+	attestMatchCmd.Flags().StringArrayVarP(
+		&exclude,
+		"exclude",
+		"e",
+		[]string{},
+		"gitignore-style patterns to exclude artifacts from matching",
+	)
+
+	attestMatchCmd.Flags().StringArrayVar(
+		&lStripPaths,
+		"lstrip-paths",
+		[]string{},
+		`Path prefixes used to left-strip artifact paths before matching
+them with subject names.`,
+	)
+}
+
+func attestMatch(cmd *cobra.Command, args []string) error {
+	env, err := intoto.LoadBundleEnvelope(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load attestation at %s: %w", args[0], err)
+	}
+
+	statement, ok := env.GetPayload().(*intoto.ParsedStatement)
+	if !ok {
+		return fmt.Errorf("attestation must be a statement")
+	}
+
+	matches, err := intoto.MatchSubjects(statement.Subject, paths, exclude, lStripPaths)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range matches.Covered {
+		fmt.Printf("Covered: %s\n", name)
+	}
+	for _, name := range matches.Mismatched {
+		fmt.Printf("Hashes differ: %s\n", name)
+	}
+	for _, name := range matches.Uncovered {
+		fmt.Printf("Not covered: %s\n", name)
+	}
+
+	if len(matches.Mismatched) != 0 || len(matches.Uncovered) != 0 {
+		return fmt.Errorf("%d subject(s) not covered by local artifacts", len(matches.Mismatched)+len(matches.Uncovered))
+	}
+
+	return nil
+}
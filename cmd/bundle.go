@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bundlePredicateTypes []string
+	bundleSubjectDigest  string
+	bundleKeyIDs         []string
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Attestation bundle commands",
+	Long: `Commands to work with attestation bundles in JSON Lines format, i.e.
+'.intoto.jsonl' files with one DSSE envelope per line.`,
+}
+
+var bundleFilterCmd = &cobra.Command{
+	Use:   "filter <bundle>",
+	Short: "Output the envelopes of a bundle that match all passed filters",
+	Args:  cobra.ExactArgs(1),
+	RunE:  bundleFilter,
+}
+
+var bundleAppendCmd = &cobra.Command{
+	Use:   "append <bundle> <envelope>...",
+	Short: "Append DSSE envelopes to a bundle, which is created if it does not exist",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  bundleAppend,
+}
+
+var bundleVerifyCmd = &cobra.Command{
+	Use:   "verify <bundle>",
+	Short: "Verify that every envelope of a bundle is signed by one of the passed keys",
+	Args:  cobra.ExactArgs(1),
+	RunE:  bundleVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+	bundleCmd.AddCommand(bundleFilterCmd)
+	bundleCmd.AddCommand(bundleAppendCmd)
+	bundleCmd.AddCommand(bundleVerifyCmd)
+
+	bundleFilterCmd.Flags().StringSliceVar(
+		&bundlePredicateTypes,
+		"predicate-type",
+		[]string{},
+		`Predicate type(s) of the envelopes to output.`,
+	)
+
+	bundleFilterCmd.Flags().StringVar(
+		&bundleSubjectDigest,
+		"subject-digest",
+		"",
+		`Digest of a subject of the envelopes to output, in
+'<algorithm>:<hex digest>' format, e.g. 'sha256:abc...'.`,
+	)
+
+	bundleFilterCmd.Flags().StringSliceVar(
+		&bundleKeyIDs,
+		"keyid",
+		[]string{},
+		`Key ID(s) of signers of the envelopes to output. Only the
+key IDs recorded in the signatures are compared, the signatures
+are not verified. Use 'bundle verify' to verify them.`,
+	)
+
+	bundleFilterCmd.Flags().StringVarP(
+		&outputPath,
+		"output",
+		"o",
+		"",
+		`Path to write the matching envelopes to as bundle. If not passed,
+they are written to standard output.`,
+	)
+
+	bundleVerifyCmd.Flags().StringSliceVarP(
+		&pubKeyPaths,
+		"key",
+		"k",
+		[]string{},
+		`Path(s) to PEM formatted public key(s). Every envelope must carry
+a valid signature for at least one of them.`,
+	)
+
+	bundleVerifyCmd.Flags().StringVar(
+		&keyScheme,
+		"scheme",
+		"",
+		`Signature scheme of the passed keys, e.g. 'rsa-pkcs1v15-sha256'.
+If not passed, the default scheme for the key type is used.`,
+	)
+
+	bundleVerifyCmd.MarkFlagRequired("key") //nolint:errcheck
+}
+
+func bundleFilter(cmd *cobra.Command, args []string) error {
+	envs, err := intoto.LoadBundle(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load bundle at %s: %w", args[0], err)
+	}
+
+	filter := intoto.BundleFilter{
+		PredicateTypes: bundlePredicateTypes,
+		KeyIDs:         bundleKeyIDs,
+	}
+	if bundleSubjectDigest != "" {
+		algorithm, digest, ok := strings.Cut(bundleSubjectDigest, ":")
+		if !ok || algorithm == "" || digest == "" {
+			return fmt.Errorf("invalid subject digest '%s', expected '<algorithm>:<hex digest>'", bundleSubjectDigest)
+		}
+		filter.SubjectDigest = intoto.HashObj{algorithm: digest}
+	}
+
+	var out io.Writer = os.Stdout
+	if outputPath != "" {
+		outFile, err := os.Create(outputPath)
+		if err != nil {
+			return err
+		}
+		defer outFile.Close()
+		out = outFile
+	}
+
+	return intoto.WriteBundle(out, intoto.FilterBundle(envs, filter))
+}
+
+func bundleAppend(cmd *cobra.Command, args []string) error {
+	envs := []*intoto.Envelope{}
+	for _, path := range args[1:] {
+		env, err := intoto.LoadBundleEnvelope(path)
+		if err != nil {
+			return fmt.Errorf("failed to load envelope at %s: %w", path, err)
+		}
+		envs = append(envs, env)
+	}
+
+	return intoto.AppendToBundle(args[0], envs...)
+}
+
+func bundleVerify(cmd *cobra.Command, args []string) error {
+	envs, err := intoto.LoadBundle(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load bundle at %s: %w", args[0], err)
+	}
+
+	keys := make(map[string]intoto.Key, len(pubKeyPaths))
+	for _, pubKeyPath := range pubKeyPaths {
+		var pubKey intoto.Key
+		if err := loadKeyWithScheme(&pubKey, pubKeyPath); err != nil {
+			return fmt.Errorf("invalid key at %s: %w", pubKeyPath, err)
+		}
+		keys[pubKey.KeyID] = pubKey
+	}
+
+	if err := intoto.VerifyBundle(envs, keys); err != nil {
+		return fmt.Errorf("bundle verification failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/spf13/cobra"
+)
+
+var checkStepName string
+
+var certCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Certificate commands",
+}
+
+var certCheckCmd = &cobra.Command{
+	Use:   "check <cert>",
+	Short: "Check a certificate against the certificate constraints of a step",
+	Long: `Checks the passed PEM formatted certificate against every certificate
+constraint of a step of the passed layout, and reports for each constraint the
+checked attributes with their expected and actual values, and any error building
+the chain of trust to the root CAs of the layout. Certificates following the
+first one in the file are used as intermediates. The signatures of the layout
+are not verified. Exits with a non-zero status if the certificate matches none
+of the constraints.`,
+	Args: cobra.ExactArgs(1),
+	RunE: certCheck,
+}
+
+func init() {
+	rootCmd.AddCommand(certCmd)
+	certCmd.AddCommand(certCheckCmd)
+
+	certCheckCmd.Flags().StringVarP(
+		&layoutPath,
+		"layout",
+		"l",
+		"",
+		`Path to the layout with the step whose constraints are checked`,
+	)
+
+	certCheckCmd.Flags().StringVarP(
+		&checkStepName,
+		"step",
+		"s",
+		"",
+		`Name of the step whose constraints are checked`,
+	)
+
+	certCheckCmd.Flags().StringSliceVarP(
+		&intermediatePaths,
+		"intermediate-certs",
+		"i",
+		[]string{},
+		`Path(s) to PEM formatted certificates, used as intermediaries to verify
+the chain of trust to the layout's trusted root. These will be used in
+addition to any intermediates in the layout.`,
+	)
+
+	certCheckCmd.MarkFlagRequired("layout")
+	certCheckCmd.MarkFlagRequired("step")
+}
+
+func certCheck(cmd *cobra.Command, args []string) error {
+	layoutMb, err := intoto.LoadMetadata(layoutPath)
+	if err != nil {
+		return fmt.Errorf("failed to load layout at %s: %w", layoutPath, err)
+	}
+
+	layout, ok := layoutMb.GetPayload().(intoto.Layout)
+	if !ok {
+		return fmt.Errorf("metadata at %s is not a layout", layoutPath)
+	}
+
+	var step *intoto.Step
+	for i := range layout.Steps {
+		if layout.Steps[i].Name == checkStepName {
+			step = &layout.Steps[i]
+			break
+		}
+	}
+	if step == nil {
+		return fmt.Errorf("layout has no step '%s'", checkStepName)
+	}
+
+	var certKey intoto.Key
+	if err := certKey.LoadKeyDefaults(args[0]); err != nil {
+		return fmt.Errorf("invalid cert at %s: %w", args[0], err)
+	}
+
+	intermediatePems := make([][]byte, 0, len(intermediatePaths)+1)
+	for _, intermediate := range intermediatePaths {
+		pemBytes, err := os.ReadFile(intermediate)
+		if err != nil {
+			return fmt.Errorf("failed to read intermediate %s: %w", intermediate, err)
+		}
+
+		intermediatePems = append(intermediatePems, pemBytes)
+	}
+
+	attached, err := loadIntermediates(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid cert at %s: %w", args[0], err)
+	}
+	if attached != "" {
+		intermediatePems = append(intermediatePems, []byte(attached))
+	}
+
+	rootPool, intermediatePool, err := intoto.LoadLayoutCertificates(layout, intermediatePems)
+	if err != nil {
+		return err
+	}
+
+	results, err := step.EvaluateCertConstraints(certKey, layout.RootCAIDs(), rootPool, intermediatePool, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	printConstraintResults(os.Stdout, results)
+
+	for _, result := range results {
+		if result.Passed() {
+			return nil
+		}
+	}
+	return fmt.Errorf("certificate matches no constraint of step '%s'", step.Name)
+}
+
+// printConstraintResults writes the result of every certificate constraint, with the
+// expected and actual values of each checked attribute, to the passed writer.
+func printConstraintResults(w io.Writer, results []intoto.ConstraintResult) {
+	for i, result := range results {
+		status := "passed"
+		if !result.Passed() {
+			status = "failed"
+		}
+		fmt.Fprintf(w, "Constraint %d: %s\n", i+1, status)
+
+		if result.ChainError != "" {
+			fmt.Fprintf(w, "  chain of trust: %s\n", result.ChainError)
+		}
+
+		for _, check := range result.Checks {
+			if check.Error == "" {
+				fmt.Fprintf(w, "  %s: passed\n", check.Attribute)
+			} else {
+				fmt.Fprintf(w, "  %s: failed: %s\n", check.Attribute, check.Error)
+			}
+			fmt.Fprintf(w, "    expected: %+q\n", check.Expected)
+			fmt.Fprintf(w, "    actual:   %+q\n", check.Actual)
+		}
+	}
+}
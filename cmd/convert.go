@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	metadataFormat string
+	convertKeys    []string
+	dropSignatures bool
+	forceOverwrite bool
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Converts in-toto link or layout metadata between the DSSE and the legacy signature wrapper",
+	Long: `Converts in-toto link or layout metadata between the DSSE envelope and the
+legacy signature wrapper (metablock). Signatures are only valid for the format
+they were created in, thus signed metadata must either be re-signed using
+'--key' or its signatures must be dropped explicitly using '--drop-signatures'.`,
+	RunE: convert,
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().StringVarP(
+		&layoutPath,
+		"file",
+		"f",
+		"",
+		`Path to link or layout file to be converted.`,
+	)
+
+	convertCmd.Flags().StringVarP(
+		&metadataFormat,
+		"to",
+		"t",
+		"",
+		`Target metadata format, either 'dsse' or 'metablock'.`,
+	)
+
+	convertCmd.Flags().StringVarP(
+		&outputPath,
+		"output",
+		"o",
+		"",
+		`Path to store the converted metadata file. Required, unless
+'--force' is passed to overwrite the passed file.`,
+	)
+
+	convertCmd.Flags().BoolVar(
+		&forceOverwrite,
+		"force",
+		false,
+		`Overwrite the passed file with the converted metadata, if
+'--output' is not passed.`,
+	)
+
+	convertCmd.Flags().StringSliceVarP(
+		&convertKeys,
+		"key",
+		"k",
+		[]string{},
+		`Path(s) to private key(s) used to sign the converted metadata.`,
+	)
+
+	convertCmd.Flags().StringVar(
+		&keyScheme,
+		"scheme",
+		"",
+		`Signature scheme of the passed keys, e.g. 'rsa-pkcs1v15-sha256'.
+If not passed, the default scheme for the key type is used.`,
+	)
+
+	convertCmd.Flags().BoolVar(
+		&dropSignatures,
+		"drop-signatures",
+		false,
+		`Drop signatures that cannot be carried over to the target
+format instead of failing.`,
+	)
+
+	convertCmd.MarkFlagRequired("file") //nolint:errcheck
+	convertCmd.MarkFlagRequired("to")   //nolint:errcheck
+}
+
+func convert(cmd *cobra.Command, args []string) error {
+	output, err := conversionOutputPath(layoutPath)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := intoto.LoadMetadata(layoutPath)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata at %s: %w", layoutPath, err)
+	}
+
+	keys := []intoto.Key{}
+	for _, path := range convertKeys {
+		var k intoto.Key
+		if err := loadKeyWithScheme(&k, path); err != nil {
+			return fmt.Errorf("invalid key at %s: %w", path, err)
+		}
+		keys = append(keys, k)
+	}
+
+	converted, err := intoto.ConvertMetadata(metadata, metadataFormat, keys, dropSignatures)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s: %w", layoutPath, err)
+	}
+
+	return converted.Dump(output)
+}
+
+// conversionOutputPath returns the path passed via --output, or the passed
+// input path if --force is passed, so that signed inputs are only replaced
+// on request.
+func conversionOutputPath(inputPath string) (string, error) {
+	if outputPath != "" {
+		return outputPath, nil
+	}
+	if !forceOverwrite {
+		return "", fmt.Errorf("refusing to overwrite %s, pass '--output' or '--force'", inputPath)
+	}
+	return inputPath, nil
+}
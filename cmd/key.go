@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 
 	intoto "github.com/in-toto/in-toto-golang/in_toto"
@@ -30,17 +31,70 @@ var keyLayoutCmd = &cobra.Command{
 	RunE:  keyLayout,
 }
 
+var (
+	convertFormat     string
+	convertOutput     string
+	convertPublicOnly bool
+)
+
+var keyConvertCmd = &cobra.Command{
+	Use:   "convert <file>",
+	Short: "Convert a key between PEM, securesystemslib JSON and JWK formats",
+	Long: `Convert a key between PEM, securesystemslib JSON and JWK formats.
+The input format is detected automatically. A JWK Set is accepted as
+input if it contains exactly one key.`,
+	Args: cobra.ExactArgs(1),
+	RunE: keyConvert,
+}
+
 func init() {
 	rootCmd.AddCommand(keyCmd)
 
 	keyCmd.AddCommand(keyIDCmd)
 	keyCmd.AddCommand(keyLayoutCmd)
+	keyCmd.AddCommand(keyConvertCmd)
+
+	for _, c := range []*cobra.Command{keyIDCmd, keyLayoutCmd} {
+		c.Flags().StringVar(
+			&keyScheme,
+			"scheme",
+			"",
+			`Signature scheme of the passed key, e.g. 'rsa-pkcs1v15-sha256'.
+If not passed, the default scheme for the key type is used.`,
+		)
+	}
+
+	keyConvertCmd.Flags().StringVarP(
+		&convertFormat,
+		"to",
+		"t",
+		"",
+		`Output format, one of 'pem', 'sslib' or 'jwk'`,
+	)
+
+	keyConvertCmd.Flags().StringVarP(
+		&convertOutput,
+		"output",
+		"o",
+		"",
+		`Path to write the converted key to. If not passed the key
+is written to stdout.`,
+	)
+
+	keyConvertCmd.Flags().BoolVar(
+		&convertPublicOnly,
+		"public",
+		false,
+		`Only output the public part of the key`,
+	)
+
+	keyConvertCmd.MarkFlagRequired("to") //nolint:errcheck
 }
 
 func keyID(cmd *cobra.Command, args []string) error {
 	var key intoto.Key
 
-	err := key.LoadKeyDefaults(args[0])
+	err := loadKeyWithScheme(&key, args[0])
 	if err != nil {
 		return err
 	}
@@ -53,7 +107,7 @@ func keyID(cmd *cobra.Command, args []string) error {
 func keyLayout(cmd *cobra.Command, args []string) error {
 	var key intoto.Key
 
-	err := key.LoadKeyDefaults(args[0])
+	err := loadKeyWithScheme(&key, args[0])
 	if err != nil {
 		return err
 	}
@@ -71,3 +125,41 @@ func keyLayout(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func keyConvert(cmd *cobra.Command, args []string) error {
+	var key intoto.Key
+
+	if err := key.LoadKeyAnyFormat(args[0]); err != nil {
+		return fmt.Errorf("invalid key at %s: %w", args[0], err)
+	}
+
+	var (
+		out []byte
+		err error
+	)
+	switch convertFormat {
+	case "pem":
+		out, err = key.MarshalPEM(!convertPublicOnly)
+	case "sslib":
+		out, err = key.MarshalSSLib(!convertPublicOnly)
+	case "jwk":
+		out, err = key.MarshalJWK(!convertPublicOnly)
+	default:
+		return fmt.Errorf("unknown output format '%s', must be one of 'pem', 'sslib' or 'jwk'", convertFormat)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to convert key: %w", err)
+	}
+
+	if !strings.HasSuffix(string(out), "\n") {
+		out = append(out, '\n')
+	}
+
+	if convertOutput == "" {
+		fmt.Print(string(out))
+		return nil
+	}
+
+	// Converted keys may contain private key material (-rw-------)
+	return os.WriteFile(convertOutput, out, 0600)
+}
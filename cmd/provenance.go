@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/spf13/cobra"
+)
+
+// provenanceVersions maps the SLSA versions accepted by 'provenance convert'
+// to their predicate types.
+var provenanceVersions = map[string]string{
+	"v0.2": slsa02.PredicateSLSAProvenance,
+	"v1":   slsa1.PredicateSLSAProvenance,
+}
+
+var provenanceVersion string
+
+var provenanceCmd = &cobra.Command{
+	Use:   "provenance",
+	Short: "SLSA provenance commands",
+}
+
+var provenanceConvertCmd = &cobra.Command{
+	Use:   "convert <file>",
+	Short: "Convert SLSA provenance between predicate versions",
+	Long: `Converts the SLSA provenance in a DSSE envelope between predicate versions.
+SLSA v0.1 provenance can be converted to v0.2 and v1, v0.2 to v1, and v1 to
+v0.2. Fields of the passed provenance that cannot be mapped to the target
+version are reported. As the signatures of the passed provenance are not valid
+for the converted provenance, it is only signed if '--key' is passed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: provenanceConvert,
+}
+
+func init() {
+	rootCmd.AddCommand(provenanceCmd)
+	provenanceCmd.AddCommand(provenanceConvertCmd)
+
+	provenanceConvertCmd.Flags().StringVarP(
+		&provenanceVersion,
+		"to",
+		"t",
+		"",
+		`Target SLSA provenance version, either 'v0.2' or 'v1'.`,
+	)
+
+	provenanceConvertCmd.Flags().StringVarP(
+		&outputPath,
+		"output",
+		"o",
+		"",
+		`Path to store the converted provenance. Required, unless
+'--force' is passed to overwrite the passed file.`,
+	)
+
+	provenanceConvertCmd.Flags().BoolVar(
+		&forceOverwrite,
+		"force",
+		false,
+		`Overwrite the passed file with the converted provenance, if
+'--output' is not passed.`,
+	)
+
+	provenanceConvertCmd.Flags().StringVarP(
+		&keyPath,
+		"key",
+		"k",
+		"",
+		`Path to a private key used to sign the converted provenance.`,
+	)
+
+	provenanceConvertCmd.Flags().StringVar(
+		&keyScheme,
+		"scheme",
+		"",
+		`Signature scheme of the passed key, e.g. 'rsa-pkcs1v15-sha256'.
+If not passed, the default scheme for the key type is used.`,
+	)
+
+	provenanceConvertCmd.MarkFlagRequired("to") //nolint:errcheck
+}
+
+func provenanceConvert(cmd *cobra.Command, args []string) error {
+	predicateType, ok := provenanceVersions[provenanceVersion]
+	if !ok {
+		return fmt.Errorf("unsupported provenance version '%s', expected 'v0.2' or 'v1'", provenanceVersion)
+	}
+
+	output, err := conversionOutputPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	metadata, err := intoto.LoadMetadata(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load provenance at %s: %w", args[0], err)
+	}
+	env, ok := metadata.(*intoto.Envelope)
+	if !ok {
+		return fmt.Errorf("provenance at %s is not a DSSE envelope", args[0])
+	}
+
+	var signingKey intoto.Key
+	if keyPath != "" {
+		if err := loadKeyWithScheme(&signingKey, keyPath); err != nil {
+			return fmt.Errorf("invalid key at %s: %w", keyPath, err)
+		}
+	}
+
+	converted, unmapped, err := intoto.ConvertProvenance(env, predicateType, signingKey)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s: %w", args[0], err)
+	}
+	for _, field := range unmapped {
+		fmt.Printf("Unmapped field: %s\n", field)
+	}
+
+	return converted.Dump(output)
+}
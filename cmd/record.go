@@ -71,13 +71,22 @@ i.e. ‘<name>.<keyid prefix>.link’. See ‘–key-type’ for available
 formats. Passing one of ‘–key’ or ‘–gpg’ is required.`,
 	)
 
+	recordCmd.PersistentFlags().StringVar(
+		&keyScheme,
+		"scheme",
+		"",
+		`Signature scheme of the passed key, e.g. 'rsa-pkcs1v15-sha256'.
+If not passed, the default scheme for the key type is used.`,
+	)
+
 	recordCmd.PersistentFlags().StringVarP(
 		&certPath,
 		"cert",
 		"c",
 		"",
 		`Path to a PEM formatted certificate that corresponds
-with the provided key.`,
+with the provided key, optionally followed by intermediate
+certificates, which are attached to the signature.`,
 	)
 
 	recordCmd.PersistentFlags().StringVarP(
@@ -134,6 +143,25 @@ with a new line character.`,
 		"Create metadata using DSSE instead of the legacy signature wrapper.",
 	)
 
+	recordCmd.PersistentFlags().StringVar(
+		&attestationFormat,
+		"attestation-format",
+		intoto.AttestationFormatLink,
+		`Format of the link created by 'record stop', either 'link'
+or 'statement-v1'. 'statement-v1' creates a DSSE envelope
+with an ITE-6 v1 Statement, whose subjects are the
+recorded products and whose predicate is the link. The
+unfinished link created by 'record start' is always a link.`,
+	)
+
+	addProvenanceFlags(recordCmd.PersistentFlags())
+
+	addTimestampFlag(recordStopCmd.Flags())
+
+	addRecordTimesFlag(recordCmd.PersistentFlags())
+
+	addEnvironmentFlags(recordStartCmd.Flags())
+
 	recordCmd.PersistentFlags().BoolVar(
 		&followSymlinkDirs,
 		"follow-symlink-dirs",
@@ -173,7 +201,11 @@ command is executed. Symlinks are followed.`,
 }
 
 func recordStart(cmd *cobra.Command, args []string) error {
-	block, err := intoto.InTotoRecordStart(recordStepName, recordMaterialsPaths, key, []string{"sha256"}, exclude, lStripPaths, lineNormalization, followSymlinkDirs, useDSSE)
+	if err := validateAttestationFormat(); err != nil {
+		return err
+	}
+
+	block, err := intoto.InTotoRecordStartWithOptions(recordStepName, recordMaterialsPaths, key, []string{"sha256"}, exclude, lStripPaths, recordRunOptions())
 	if err != nil {
 		return fmt.Errorf("failed to create start link file: %w", err)
 	}
@@ -189,6 +221,13 @@ func recordStart(cmd *cobra.Command, args []string) error {
 }
 
 func recordStop(cmd *cobra.Command, args []string) error {
+	if err := validateAttestationFormat(); err != nil {
+		return err
+	}
+	if err := validateProvenanceFlags(); err != nil {
+		return err
+	}
+
 	prelimLinkName := fmt.Sprintf(intoto.PreliminaryLinkNameFormat, recordStepName, key.KeyID)
 	prelimLinkPath := filepath.Join(outDir, prelimLinkName)
 	prelimLinkMb, err := intoto.LoadMetadata(prelimLinkPath)
@@ -196,11 +235,19 @@ func recordStop(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load start link file at %s: %w", prelimLinkName, err)
 	}
 
-	linkMb, err := intoto.InTotoRecordStop(prelimLinkMb, recordProductsPaths, key, []string{"sha256"}, exclude, lStripPaths, lineNormalization, followSymlinkDirs, useDSSE)
+	// Provenance records the start time of the start link, if it has one
+	options := recordRunOptions()
+	options.Provenance = provenanceOptions()
+
+	linkMb, provenance, err := intoto.InTotoRecordStopWithProvenance(prelimLinkMb, recordProductsPaths, key, []string{"sha256"}, exclude, lStripPaths, options)
 	if err != nil {
 		return fmt.Errorf("failed to create stop link file: %w", err)
 	}
 
+	if err := addTimestamp(linkMb); err != nil {
+		return fmt.Errorf("failed to timestamp stop link file: %w", err)
+	}
+
 	linkName := fmt.Sprintf(intoto.LinkNameFormat, recordStepName, key.KeyID)
 	linkPath := filepath.Join(outDir, linkName)
 	err = linkMb.Dump(linkPath)
@@ -208,6 +255,10 @@ func recordStop(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to write stop link file to %s: %w", prelimLinkName, err)
 	}
 
+	if err := writeProvenance(provenance); err != nil {
+		return err
+	}
+
 	err = os.Remove(prelimLinkPath)
 	if err != nil {
 		return fmt.Errorf("failed to remove start link file at %s: %w", prelimLinkName, err)
@@ -215,3 +266,15 @@ func recordStop(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// recordRunOptions returns the options passed to record start and stop.
+func recordRunOptions() intoto.RunOptions {
+	return intoto.RunOptions{
+		LineNormalization: lineNormalization,
+		FollowSymlinkDirs: followSymlinkDirs,
+		UseDSSE:           useDSSE,
+		RecordTimes:       recordTimes,
+		Environment:       environmentOptions(),
+		AttestationFormat: attestationFormat,
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	intoto "github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/in-toto/in-toto-golang/internal/spiffe"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
@@ -25,6 +27,26 @@ var (
 	lineNormalization bool
 	followSymlinkDirs bool
 	useDSSE           bool
+	keyScheme         string
+	attestationFormat string
+	provenancePath    string
+	builderID         string
+	buildType         string
+	timestampServer   string
+	recordTimes       bool
+	envVariables      []string
+	envRedact         []string
+	envHostname       bool
+	envPlatform       bool
+	envCommands       []string
+	maxOutputSize     int
+	commandTimeout    time.Duration
+	commandStdin      string
+	cleanEnv          bool
+	passEnv           []string
+	limitCPU          time.Duration
+	limitMemory       uint64
+	limitOpenFiles    uint64
 )
 
 var rootCmd = &cobra.Command{
@@ -83,7 +105,7 @@ func loadKeyFromDisk() error {
 
 	if len(keyPath) > 0 {
 		if _, err := os.Stat(keyPath); err == nil {
-			if err := key.LoadKeyDefaults(keyPath); err != nil {
+			if err := loadKeyWithScheme(&key, keyPath); err != nil {
 				return fmt.Errorf("invalid key at %s: %w", keyPath, err)
 			}
 		} else {
@@ -96,7 +118,11 @@ func loadKeyFromDisk() error {
 			if err := cert.LoadKeyDefaults(certPath); err != nil {
 				return fmt.Errorf("invalid cert at %s: %w", certPath, err)
 			}
-			key.KeyVal.Certificate = cert.KeyVal.Certificate
+			intermediates, err := loadIntermediates(certPath)
+			if err != nil {
+				return fmt.Errorf("invalid cert at %s: %w", certPath, err)
+			}
+			key.KeyVal.Certificate = cert.KeyVal.Certificate + intermediates
 		} else {
 			return fmt.Errorf("cert not found at %s: %w", certPath, err)
 		}
@@ -104,6 +130,337 @@ func loadKeyFromDisk() error {
 	return nil
 }
 
+// loadIntermediates returns the PEM encoded certificates that follow the first
+// certificate in the file at path, so that they can be attached to signatures.
+func loadIntermediates(path string) (string, error) {
+	certBytes, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	intermediates := ""
+	firstCert := true
+	for {
+		var block *pem.Block
+		block, certBytes = pem.Decode(certBytes)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if firstCert {
+			firstCert = false
+			continue
+		}
+		intermediates += string(pem.EncodeToMemory(block))
+	}
+	return intermediates, nil
+}
+
+// validateAttestationFormat checks the format passed via --attestation-format.
+func validateAttestationFormat() error {
+	switch attestationFormat {
+	case intoto.AttestationFormatLink:
+		return nil
+	case intoto.AttestationFormatStatementV1:
+		// The link predicate of the statement has no start and finish times
+		if recordTimes {
+			return fmt.Errorf("'--record-times' cannot be used with '--attestation-format %s'", attestationFormat)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown attestation format '%s'", attestationFormat)
+}
+
+// validateProvenanceFlags checks that the flags required to create provenance
+// are passed together with --provenance.
+func validateProvenanceFlags() error {
+	if provenancePath == "" {
+		return nil
+	}
+	if builderID == "" {
+		return fmt.Errorf("'--builder-id' is required with '--provenance'")
+	}
+	if buildType == "" {
+		return fmt.Errorf("'--build-type' is required with '--provenance'")
+	}
+	return nil
+}
+
+// provenanceOptions returns the options for SLSA v1 provenance passed via
+// flags, or nil if no provenance is requested with --provenance.
+func provenanceOptions() *intoto.ProvenanceOptions {
+	if provenancePath == "" {
+		return nil
+	}
+	return &intoto.ProvenanceOptions{
+		BuilderID: builderID,
+		BuildType: buildType,
+	}
+}
+
+// writeProvenance writes the passed provenance to the path passed via
+// --provenance, if any.
+func writeProvenance(provenance *intoto.Envelope) error {
+	if provenance == nil {
+		return nil
+	}
+
+	if err := provenance.Dump(provenancePath); err != nil {
+		return fmt.Errorf("failed to write provenance to %s: %w", provenancePath, err)
+	}
+	return nil
+}
+
+// addProvenanceFlags adds the flags to create provenance to the passed flag set.
+func addProvenanceFlags(flags *pflag.FlagSet) {
+	flags.StringVar(
+		&provenancePath,
+		"provenance",
+		"",
+		`Path to store signed SLSA v1 provenance of the step in
+addition to the link metadata. Requires '--builder-id' and
+'--build-type'.`,
+	)
+
+	flags.StringVar(
+		&builderID,
+		"builder-id",
+		"",
+		`URI identifying the builder in the SLSA provenance.`,
+	)
+
+	flags.StringVar(
+		&buildType,
+		"build-type",
+		"",
+		`URI identifying the build type in the SLSA provenance.`,
+	)
+}
+
+// addRecordTimesFlag adds the flag to record link start and finish times to the passed flag set.
+func addRecordTimesFlag(flags *pflag.FlagSet) {
+	flags.BoolVar(
+		&recordTimes,
+		"record-times",
+		false,
+		`Record the times at which the step started and finished in
+the link, which allows verifying the maximum link age and
+order of steps defined in the layout. Cannot be used with
+'--attestation-format statement-v1', whose link predicate
+has no times.`,
+	)
+}
+
+// addEnvironmentFlags adds the flags to record the environment of a step to the passed flag set.
+func addEnvironmentFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVar(
+		&envVariables,
+		"env",
+		[]string{},
+		`Names or glob patterns of environment variables, whose
+values are recorded in the environment of the link, e.g.
+'CI' or 'GITHUB_*'. Values of variables whose names look
+like secrets are redacted.`,
+	)
+
+	flags.StringSliceVar(
+		&envRedact,
+		"env-redact",
+		[]string{},
+		`Additional names or glob patterns of environment variables
+recorded with '--env', whose values are redacted.`,
+	)
+
+	flags.BoolVar(
+		&envHostname,
+		"env-hostname",
+		false,
+		`Record the hostname in the environment of the link.`,
+	)
+
+	flags.BoolVar(
+		&envPlatform,
+		"env-platform",
+		false,
+		`Record the operating system, architecture and Go runtime
+version in the environment of the link.`,
+	)
+
+	flags.StringArrayVar(
+		&envCommands,
+		"env-cmd",
+		[]string{},
+		`Command, whose output is recorded in the environment of the
+link, e.g. 'go version'. The command is not run in a shell.
+Can be passed multiple times.`,
+	)
+}
+
+// environmentOptions returns the environment capture options passed via flags.
+func environmentOptions() intoto.EnvironmentOptions {
+	return intoto.EnvironmentOptions{
+		Variables:      envVariables,
+		RedactPatterns: envRedact,
+		Hostname:       envHostname,
+		Platform:       envPlatform,
+		Commands:       envCommands,
+	}
+}
+
+/*
+addExecutionPolicyFlags adds the flags to restrict the execution of commands to
+the passed flag set.  Their names start with the passed prefix, such that the
+same policy can be set for different commands, e.g. for inspections.
+*/
+func addExecutionPolicyFlags(flags *pflag.FlagSet, prefix string) {
+	flags.IntVar(
+		&maxOutputSize,
+		prefix+"max-output-size",
+		0,
+		`Maximum number of bytes of stdout and stderr each, which are
+recorded in the link. Longer output is truncated and ends with
+a marker. 0 records the complete output.`,
+	)
+
+	flags.DurationVar(
+		&commandTimeout,
+		prefix+"timeout",
+		0,
+		`Kill the command, and on Unix the processes it started, if
+it runs longer than the passed duration, e.g. '10m', and
+fail. 0 disables the timeout.`,
+	)
+
+	flags.StringVar(
+		&commandStdin,
+		prefix+"stdin",
+		"",
+		`Path to a file, which is read as standard input of the
+command, or '-' to pass on the standard input of in-toto.
+If not passed, the command reads from the null device.`,
+	)
+
+	flags.BoolVar(
+		&cleanEnv,
+		prefix+"clean-env",
+		false,
+		`Run the command with an empty environment, to which only
+the variables passed with '--`+prefix+`pass-env' are passed.`,
+	)
+
+	flags.StringSliceVar(
+		&passEnv,
+		prefix+"pass-env",
+		[]string{},
+		`Names or glob patterns of environment variables, which are
+passed to the command despite '--`+prefix+`clean-env', e.g. 'PATH'.`,
+	)
+
+	flags.DurationVar(
+		&limitCPU,
+		prefix+"limit-cpu",
+		0,
+		`Maximum CPU time of the command, rounded up to full seconds.
+Only supported on Linux. 0 sets no limit.`,
+	)
+
+	flags.Uint64Var(
+		&limitMemory,
+		prefix+"limit-memory",
+		0,
+		`Maximum size of the virtual memory of the command in bytes.
+Only supported on Linux. 0 sets no limit.`,
+	)
+
+	flags.Uint64Var(
+		&limitOpenFiles,
+		prefix+"limit-open-files",
+		0,
+		`Maximum number of files the command can open at once.
+Only supported on Linux. 0 sets no limit.`,
+	)
+}
+
+/*
+executionPolicy returns the command options passed via the execution policy
+flags, and a function that closes the standard input file of the command.
+*/
+func executionPolicy() (intoto.CommandOptions, func(), error) {
+	if maxOutputSize < 0 {
+		return intoto.CommandOptions{}, nil, fmt.Errorf("invalid max output size %d, must not be negative", maxOutputSize)
+	}
+
+	options := intoto.CommandOptions{
+		MaxOutputSize: maxOutputSize,
+		Timeout:       commandTimeout,
+		CleanEnv:      cleanEnv,
+		PassEnv:       passEnv,
+		Limits: intoto.ResourceLimits{
+			CPUTime:   limitCPU,
+			Memory:    limitMemory,
+			OpenFiles: limitOpenFiles,
+		},
+	}
+
+	closeStdin := func() {}
+	switch commandStdin {
+	case "":
+	case "-":
+		options.Stdin = os.Stdin
+	default:
+		stdin, err := os.Open(commandStdin)
+		if err != nil {
+			return intoto.CommandOptions{}, nil, fmt.Errorf("failed to open command stdin: %w", err)
+		}
+		options.Stdin = stdin
+		closeStdin = func() { stdin.Close() }
+	}
+
+	return options, closeStdin, nil
+}
+
+// addTimestampFlag adds the flag to timestamp link signatures to the passed flag set.
+func addTimestampFlag(flags *pflag.FlagSet) {
+	flags.StringVar(
+		&timestampServer,
+		"timestamp-server",
+		"",
+		`URL of an RFC 3161 time stamping authority (TSA), which
+timestamps the link signature. Requires a key with a
+certificate. A timestamp by a TSA that is trusted during
+verification allows verifying the certificate at the time
+of signing, e.g. after a short-lived certificate expired.`,
+	)
+}
+
+// addTimestamp attaches a timestamp by the server passed via --timestamp-server
+// to the signature of the loaded key.
+func addTimestamp(metadata intoto.Metadata) error {
+	if timestampServer == "" {
+		return nil
+	}
+
+	timestamper := intoto.NewRFC3161Timestamper(timestampServer)
+	switch m := metadata.(type) {
+	case *intoto.Envelope:
+		return m.AddTimestamp(key.KeyID, timestamper)
+	case *intoto.Metablock:
+		return m.AddTimestamp(key.KeyID, timestamper)
+	}
+	return fmt.Errorf("unsupported metadata type %T", metadata)
+}
+
+// loadKeyWithScheme loads the PEM formatted key at path using the scheme passed
+// via --scheme, or the default scheme for the key type if none was passed.
+func loadKeyWithScheme(k *intoto.Key, path string) error {
+	if keyScheme == "" {
+		return k.LoadKeyDefaults(path)
+	}
+	return k.LoadKey(path, keyScheme, []string{"sha256", "sha512"})
+}
+
 func getKeyCert(cmd *cobra.Command, args []string) error {
 	if spiffeUDS != "" {
 		return loadKeyFromSpireSocket()
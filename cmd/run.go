@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
 	intoto "github.com/in-toto/in-toto-golang/in_toto"
@@ -14,6 +15,8 @@ var (
 	materialsPaths []string
 	productsPaths  []string
 	noCommand      bool
+	streamOutput   bool
+	digestOutput   bool
 )
 
 var runCmd = &cobra.Command{
@@ -61,13 +64,22 @@ exist, be writable, and not be a symlink.`,
 the resulting link metadata.`,
 	)
 
+	runCmd.Flags().StringVar(
+		&keyScheme,
+		"scheme",
+		"",
+		`Signature scheme of the passed key, e.g. 'rsa-pkcs1v15-sha256'.
+If not passed, the default scheme for the key type is used.`,
+	)
+
 	runCmd.Flags().StringVarP(
 		&certPath,
 		"cert",
 		"c",
 		"",
 		`Path to a PEM formatted certificate that corresponds with
-the provided key.`,
+the provided key, optionally followed by intermediate
+certificates, which are attached to the signature.`,
 	)
 
 	runCmd.Flags().StringArrayVarP(
@@ -156,6 +168,42 @@ recorded independently of this parameter.`,
 		"Create metadata using DSSE instead of the legacy signature wrapper.",
 	)
 
+	runCmd.Flags().StringVar(
+		&attestationFormat,
+		"attestation-format",
+		intoto.AttestationFormatLink,
+		`Format of the created attestation, either 'link' or
+'statement-v1'. 'statement-v1' creates a DSSE envelope
+with an ITE-6 v1 Statement, whose subjects are the
+recorded products and whose predicate is the link.`,
+	)
+
+	addProvenanceFlags(runCmd.Flags())
+
+	addTimestampFlag(runCmd.Flags())
+
+	addRecordTimesFlag(runCmd.Flags())
+
+	addEnvironmentFlags(runCmd.Flags())
+
+	runCmd.Flags().BoolVar(
+		&streamOutput,
+		"stream-output",
+		false,
+		`Stream stdout and stderr of the command to the terminal
+while it runs, in addition to recording them in the link.`,
+	)
+
+	runCmd.Flags().BoolVar(
+		&digestOutput,
+		"digest-output",
+		false,
+		`Record the sha256 digests of stdout and stderr in the link,
+instead of their contents.`,
+	)
+
+	addExecutionPolicyFlags(runCmd.Flags(), "")
+
 	runCmd.Flags().StringVar(
 		&spiffeUDS,
 		"spiffe-workload-api-path",
@@ -166,6 +214,13 @@ recorded independently of this parameter.`,
 }
 
 func run(cmd *cobra.Command, args []string) error {
+	if err := validateAttestationFormat(); err != nil {
+		return err
+	}
+	if err := validateProvenanceFlags(); err != nil {
+		return err
+	}
+
 	if noCommand && len(args) > 0 {
 		return fmt.Errorf("command arguments passed with --no-command/-x flag")
 	}
@@ -174,11 +229,30 @@ func run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no command arguments passed, please specify or use --no-command option")
 	}
 
-	metadata, err := intoto.InTotoRun(stepName, runDir, materialsPaths, productsPaths, args, key, []string{"sha256"}, exclude, lStripPaths, lineNormalization, followSymlinkDirs, useDSSE)
+	options, closeStdin, err := commandOptions()
+	if err != nil {
+		return err
+	}
+	defer closeStdin()
+
+	metadata, provenance, err := intoto.InTotoRunWithProvenance(stepName, runDir, materialsPaths, productsPaths, args, key, []string{"sha256"}, exclude, lStripPaths, intoto.RunOptions{
+		LineNormalization: lineNormalization,
+		FollowSymlinkDirs: followSymlinkDirs,
+		UseDSSE:           useDSSE,
+		RecordTimes:       recordTimes,
+		Environment:       environmentOptions(),
+		Command:           options,
+		Provenance:        provenanceOptions(),
+		AttestationFormat: attestationFormat,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to create link metadata: %w", err)
 	}
 
+	if err := addTimestamp(metadata); err != nil {
+		return fmt.Errorf("failed to timestamp link metadata: %w", err)
+	}
+
 	link, ok := metadata.GetPayload().(intoto.Link)
 	if !ok {
 		return fmt.Errorf("metadata must be link")
@@ -191,5 +265,23 @@ func run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to write link metadata to %s: %w", linkPath, err)
 	}
 
-	return nil
+	return writeProvenance(provenance)
+}
+
+/*
+commandOptions returns the options for executing the command and for streaming
+and capturing its output passed via flags, and a function that closes the
+standard input file of the command.
+*/
+func commandOptions() (intoto.CommandOptions, func(), error) {
+	options, closeStdin, err := executionPolicy()
+	if err != nil {
+		return intoto.CommandOptions{}, nil, err
+	}
+	options.DigestOutput = digestOutput
+	if streamOutput {
+		options.Stdout = os.Stdout
+		options.Stderr = os.Stderr
+	}
+	return options, closeStdin, nil
 }
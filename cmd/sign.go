@@ -10,13 +10,17 @@ import (
 var (
 	outputPath string
 	verifyFile bool
+	mergeFiles bool
 )
 
 var signCmd = &cobra.Command{
-	Use:   "sign",
+	Use:   "sign [--merge <file>...]",
 	Short: "Provides command line interface to sign in-toto link or layout metadata",
-	Long:  `Provides command line interface to sign in-toto link or layout metadata`,
-	RunE:  sign,
+	Long: `Provides command line interface to sign in-toto link or layout metadata.
+With '--merge', the signatures of the passed files, which must carry identical
+payloads, are combined into a single file. If '--key' is passed as well, the
+merged file is additionally signed with that key.`,
+	RunE: sign,
 }
 
 func init() {
@@ -27,7 +31,7 @@ func init() {
 		"output",
 		"o",
 		"",
-		`Path to store metadata file after signing`,
+		`Path to store metadata file after signing. Required with '--merge'.`,
 	)
 
 	signCmd.Flags().StringVarP(
@@ -35,7 +39,8 @@ func init() {
 		"file",
 		"f",
 		"",
-		`Path to link or layout file to be signed or verified.`,
+		`Path to link or layout file to be signed or verified.
+Required, unless '--merge' is passed.`,
 	)
 
 	signCmd.Flags().StringVarP(
@@ -45,7 +50,15 @@ func init() {
 		"",
 		`Path to PEM formatted private key used to sign the passed 
 root layout's signature(s). Passing exactly one key using
-'--key' is required.`,
+'--key' is required, unless '--merge' is passed.`,
+	)
+
+	signCmd.Flags().StringVar(
+		&keyScheme,
+		"scheme",
+		"",
+		`Signature scheme of the passed key, e.g. 'rsa-pkcs1v15-sha256'.
+If not passed, the default scheme for the key type is used.`,
 	)
 
 	signCmd.Flags().BoolVar(
@@ -55,18 +68,35 @@ root layout's signature(s). Passing exactly one key using
 		"Verify signature of signed file",
 	)
 
-	signCmd.MarkFlagRequired("file")
-	signCmd.MarkFlagRequired("key")
+	signCmd.Flags().BoolVar(
+		&mergeFiles,
+		"merge",
+		false,
+		`Merge the signatures of the link or layout files passed as
+arguments. All files must be either DSSE envelopes or
+legacy metablocks and carry identical payloads.`,
+	)
 }
 
 func sign(cmd *cobra.Command, args []string) error {
+	if mergeFiles {
+		return merge(args)
+	}
+
+	if len(layoutPath) == 0 {
+		return fmt.Errorf("required flag \"file\" not set")
+	}
+	if len(keyPath) == 0 {
+		return fmt.Errorf("required flag \"key\" not set")
+	}
+
 	layoutEnv, err := intoto.LoadMetadata(layoutPath)
 	if err != nil {
 		return fmt.Errorf("failed to load layout at %s: %w", layoutPath, err)
 	}
 
 	key = intoto.Key{}
-	if err := key.LoadKeyDefaults(keyPath); err != nil {
+	if err := loadKeyWithScheme(&key, keyPath); err != nil {
 		return fmt.Errorf("invalid key at %s: %w", keyPath, err)
 	}
 
@@ -86,3 +116,41 @@ func sign(cmd *cobra.Command, args []string) error {
 	}
 	return layoutEnv.Dump(outputPath)
 }
+
+func merge(paths []string) error {
+	if len(paths) < 2 {
+		return fmt.Errorf("at least two files are required to merge signatures")
+	}
+	if len(layoutPath) != 0 || verifyFile {
+		return fmt.Errorf("'--merge' cannot be used with '--file' or '--verify'")
+	}
+	if len(outputPath) == 0 {
+		return fmt.Errorf("'--output' is required with '--merge'")
+	}
+
+	metadata := []intoto.Metadata{}
+	for _, path := range paths {
+		m, err := intoto.LoadMetadata(path)
+		if err != nil {
+			return fmt.Errorf("failed to load metadata at %s: %w", path, err)
+		}
+		metadata = append(metadata, m)
+	}
+
+	merged, err := intoto.MergeSignatures(metadata...)
+	if err != nil {
+		return fmt.Errorf("failed to merge signatures: %w", err)
+	}
+
+	if len(keyPath) != 0 {
+		key = intoto.Key{}
+		if err := loadKeyWithScheme(&key, keyPath); err != nil {
+			return fmt.Errorf("invalid key at %s: %w", keyPath, err)
+		}
+		if err := merged.Sign(key); err != nil {
+			return err
+		}
+	}
+
+	return merged.Dump(outputPath)
+}
@@ -1,19 +1,46 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
 
 	intoto "github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/spf13/cobra"
 )
 
 var (
-	pubKeyPaths       []string
-	linkDir           string
-	intermediatePaths []string
+	pubKeyPaths        []string
+	linkDir            string
+	intermediatePaths  []string
+	crlPaths           []string
+	tsaCertPaths       []string
+	vsaPath            string
+	verifierID         string
+	vsaResourceURI     string
+	vsaPolicyURI       string
+	vsaVerifiedLevels  []string
+	sandboxInspections bool
+	reportPath         string
 )
 
+// verificationReport is the machine readable result of 'verify', see --report.
+type verificationReport struct {
+	Passed  bool           `json:"passed"`
+	Error   string         `json:"error,omitempty"`
+	Step    string         `json:"step,omitempty"`
+	Signers []signerReport `json:"signers,omitempty"`
+}
+
+// signerReport explains why the links of a signer were rejected for a step.
+type signerReport struct {
+	KeyID             string                    `json:"keyid"`
+	Error             string                    `json:"error"`
+	ConstraintResults []intoto.ConstraintResult `json:"constraint_results,omitempty"`
+}
+
 var verifyCmd = &cobra.Command{
 	Use:   "verify",
 	Short: "Verify that the software supply chain of the delivered product",
@@ -47,6 +74,15 @@ root layout's signature(s). Passing at least one key using
 must carry a valid signature.`,
 	)
 
+	verifyCmd.Flags().StringVar(
+		&keyScheme,
+		"scheme",
+		"",
+		`Signature scheme of the passed layout keys and of the key
+passed via '--key', e.g. 'rsa-pkcs1v15-sha256'. If not passed,
+the default scheme for the key type is used.`,
+	)
+
 	verifyCmd.Flags().StringVarP(
 		&linkDir,
 		"link-dir",
@@ -67,6 +103,27 @@ the chain of trust to the layout's trusted root. These will be used in
 addition to any intermediates in the layout.`,
 	)
 
+	verifyCmd.Flags().StringSliceVar(
+		&crlPaths,
+		"crl",
+		[]string{},
+		`Path(s) to PEM or DER formatted certificate revocation lists (CRLs),
+used in addition to any CRLs in the layout to reject link signatures
+by revoked certificates. CRLs that the layout references but does not
+embed must be passed here. Signatures fail verification if the only
+CRLs of a CA were due for update before the verification time.`,
+	)
+
+	verifyCmd.Flags().StringSliceVar(
+		&tsaCertPaths,
+		"tsa-cert",
+		[]string{},
+		`Path(s) to PEM formatted certificates of trusted RFC 3161 time
+stamping authorities (TSAs), used in addition to any TSAs in the
+layout. Certificates of link signatures timestamped by a trusted
+TSA are verified at the time of the timestamp.`,
+	)
+
 	verifyCmd.MarkFlagRequired("layout")
 	verifyCmd.MarkFlagRequired("layout-keys")
 
@@ -78,9 +135,92 @@ addition to any intermediates in the layout.`,
 operating systems. It is done by replacing all line separators
 with a new line character.`,
 	)
+
+	addExecutionPolicyFlags(verifyCmd.Flags(), "inspection-")
+
+	verifyCmd.Flags().BoolVar(
+		&sandboxInspections,
+		"sandbox-inspections",
+		false,
+		`Run inspection commands in Linux user, mount and network
+namespaces, without network access, as an unprivileged user
+and with read-only mounts. Inspections can only write to a
+scratch directory at /tmp. Fails if namespaces are unavailable.`,
+	)
+
+	verifyCmd.Flags().StringVar(
+		&vsaPath,
+		"emit-vsa",
+		"",
+		`Path to store a SLSA Verification Summary Attestation (VSA)
+for the final products, if verification passes. Requires '--key',
+'--vsa-resource-uri' and '--vsa-policy-uri'.`,
+	)
+
+	verifyCmd.Flags().StringVar(
+		&keyPath,
+		"key",
+		"",
+		`Path to a private key used to sign the VSA.`,
+	)
+
+	verifyCmd.Flags().StringVar(
+		&verifierID,
+		"verifier-id",
+		intoto.DefaultVerifierID,
+		`Identity of the verifier recorded in the VSA.`,
+	)
+
+	verifyCmd.Flags().StringVar(
+		&vsaResourceURI,
+		"vsa-resource-uri",
+		"",
+		`URI of the verified resource recorded in the VSA.`,
+	)
+
+	verifyCmd.Flags().StringVar(
+		&vsaPolicyURI,
+		"vsa-policy-uri",
+		"",
+		`URI of the verified layout recorded in the VSA, which must be
+resolvable by consumers of the VSA. The digest of the layout
+passed via '--layout' is recorded along with it.`,
+	)
+
+	verifyCmd.Flags().StringSliceVar(
+		&vsaVerifiedLevels,
+		"vsa-verified-levels",
+		[]string{},
+		`SLSA levels the final products were verified to meet, which are
+recorded in the VSA. Defaults to SLSA_BUILD_LEVEL_0.`,
+	)
+
+	verifyCmd.Flags().StringVar(
+		&reportPath,
+		"report",
+		"",
+		`Path to store a JSON report of the verification, whether it
+passes or fails. If a step lacks links from authorized signers,
+the report lists why each signer was rejected, including the
+result of every certificate constraint of the step.`,
+	)
 }
 
 func verify(cmd *cobra.Command, args []string) error {
+	if (vsaPath == "") != (keyPath == "") {
+		return fmt.Errorf("'--emit-vsa' and '--key' must be passed together")
+	}
+	if vsaPath != "" && (vsaResourceURI == "" || vsaPolicyURI == "") {
+		return fmt.Errorf("'--emit-vsa' requires '--vsa-resource-uri' and '--vsa-policy-uri'")
+	}
+
+	var vsaKey intoto.Key
+	if keyPath != "" {
+		if err := loadKeyWithScheme(&vsaKey, keyPath); err != nil {
+			return fmt.Errorf("invalid key at %s: %w", keyPath, err)
+		}
+	}
+
 	layoutMb, err := intoto.LoadMetadata(layoutPath)
 	if err != nil {
 		return fmt.Errorf("failed to load layout at %s: %w", layoutPath, err)
@@ -91,7 +231,7 @@ func verify(cmd *cobra.Command, args []string) error {
 	for _, pubKeyPath := range pubKeyPaths {
 		var pubKey intoto.Key
 
-		if err := pubKey.LoadKeyDefaults(pubKeyPath); err != nil {
+		if err := loadKeyWithScheme(&pubKey, pubKeyPath); err != nil {
 			return fmt.Errorf("invalid key at %s: %w", pubKeyPath, err)
 		}
 
@@ -108,10 +248,143 @@ func verify(cmd *cobra.Command, args []string) error {
 		intermediatePems = append(intermediatePems, pemBytes)
 	}
 
-	_, err = intoto.InTotoVerify(layoutMb, layoutKeys, linkDir, "", make(map[string]string), intermediatePems, lineNormalization)
+	crlPems := make([][]byte, 0, len(crlPaths))
+	for _, crlPath := range crlPaths {
+		crlBytes, err := os.ReadFile(crlPath)
+		if err != nil {
+			return fmt.Errorf("failed to read CRL %s: %w", crlPath, err)
+		}
+
+		crlPems = append(crlPems, crlBytes)
+	}
+
+	tsaCertPems := make([][]byte, 0, len(tsaCertPaths))
+	for _, tsaCertPath := range tsaCertPaths {
+		pemBytes, err := os.ReadFile(tsaCertPath)
+		if err != nil {
+			return fmt.Errorf("failed to read TSA certificate %s: %w", tsaCertPath, err)
+		}
+
+		tsaCertPems = append(tsaCertPems, pemBytes)
+	}
+
+	inspectionCommand, closeStdin, err := executionPolicy()
+	if err != nil {
+		return err
+	}
+	defer closeStdin()
+	inspectionCommand.Sandbox = sandboxInspections
+
+	summary, err := intoto.InTotoVerifyWithOptions(layoutMb, layoutKeys, linkDir, "", make(map[string]string), intoto.VerifyOptions{
+		IntermediatePems:  intermediatePems,
+		CRLPems:           crlPems,
+		TSACertPems:       tsaCertPems,
+		LineNormalization: lineNormalization,
+		InspectionCommand: inspectionCommand,
+	})
+	if reportErr := writeVerificationReport(err); reportErr != nil {
+		return reportErr
+	}
 	if err != nil {
+		printThresholdReport(err)
 		return fmt.Errorf("inspection failed: %w", err)
 	}
 
+	if vsaPath != "" {
+		return writeVerificationSummary(summary, vsaKey)
+	}
+
 	return nil
 }
+
+func writeVerificationSummary(summary intoto.Metadata, vsaKey intoto.Key) error {
+	summaryLink, ok := summary.GetPayload().(intoto.Link)
+	if !ok {
+		return fmt.Errorf("verification summary is not a link")
+	}
+
+	layoutDigest, err := intoto.RecordArtifact(layoutPath, []string{"sha256"}, false)
+	if err != nil {
+		return fmt.Errorf("failed to hash layout at %s: %w", layoutPath, err)
+	}
+
+	env, err := intoto.NewVerificationSummaryEnvelope(summaryLink, intoto.VerificationSummaryOptions{
+		VerifierID:     verifierID,
+		PolicyURI:      vsaPolicyURI,
+		PolicyDigest:   layoutDigest,
+		ResourceURI:    vsaResourceURI,
+		VerifiedLevels: vsaVerifiedLevels,
+	}, vsaKey)
+	if err != nil {
+		return fmt.Errorf("failed to create VSA: %w", err)
+	}
+
+	return env.Dump(vsaPath)
+}
+
+// writeVerificationReport writes the report of a verification, which failed with
+// the passed error or passed if it is nil, to the path passed via --report.
+func writeVerificationReport(verifyErr error) error {
+	if reportPath == "" {
+		return nil
+	}
+
+	report := verificationReport{Passed: verifyErr == nil}
+	if verifyErr != nil {
+		report.Error = verifyErr.Error()
+	}
+	var thresholdErr *intoto.ThresholdError
+	if errors.As(verifyErr, &thresholdErr) {
+		report.Step = thresholdErr.Step
+		report.Signers = signerReports(thresholdErr)
+	}
+
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(reportPath, append(reportBytes, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report to %s: %w", reportPath, err)
+	}
+	return nil
+}
+
+// signerReports returns the reasons for rejecting the links of each signer of
+// the passed threshold error, ordered by key ID.
+func signerReports(thresholdErr *intoto.ThresholdError) []signerReport {
+	keyIDs := make([]string, 0, len(thresholdErr.SignerErrors))
+	for keyID := range thresholdErr.SignerErrors {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+
+	reports := make([]signerReport, 0, len(keyIDs))
+	for _, keyID := range keyIDs {
+		report := signerReport{KeyID: keyID, Error: thresholdErr.SignerErrors[keyID].Error()}
+		var constraintsErr *intoto.CertConstraintsError
+		if errors.As(thresholdErr.SignerErrors[keyID], &constraintsErr) {
+			report.ConstraintResults = constraintsErr.Results
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// printThresholdReport explains why the certificates of signers of rejected links
+// match none of the certificate constraints of their step, if the passed
+// verification error is due to a step threshold.
+func printThresholdReport(err error) {
+	var thresholdErr *intoto.ThresholdError
+	if !errors.As(err, &thresholdErr) {
+		return
+	}
+
+	for _, report := range signerReports(thresholdErr) {
+		if report.ConstraintResults == nil {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "Certificate of signer '%s' matches no constraint of step '%s':\n", report.KeyID, thresholdErr.Step)
+		printConstraintResults(os.Stderr, report.ConstraintResults)
+	}
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/spf13/cobra"
+)
+
+var (
+	policyBuilderIDs []string
+	policyBuildTypes []string
+	policySourceRepo string
+	policySourceRef  string
+	policyParameters []string
+	artifactPath     string
+)
+
+var verifyProvenanceCmd = &cobra.Command{
+	Use:   "verify-provenance",
+	Short: "Verify SLSA provenance against a policy",
+	Long: `Verifies that a DSSE envelope with SLSA v0.1, v0.2 or v1 provenance is
+signed by one of the passed keys and satisfies the policy defined by the
+passed flags. Expectations whose flags are not passed are not checked.`,
+	RunE: verifyProvenance,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyProvenanceCmd)
+
+	verifyProvenanceCmd.Flags().StringVarP(
+		&provenancePath,
+		"file",
+		"f",
+		"",
+		`Path to the DSSE envelope with the provenance to be verified.`,
+	)
+
+	verifyProvenanceCmd.Flags().StringSliceVarP(
+		&pubKeyPaths,
+		"key",
+		"k",
+		[]string{},
+		`Path(s) to PEM formatted public key(s) of the builder. The
+provenance must carry a valid signature for at least one of them.`,
+	)
+
+	verifyProvenanceCmd.Flags().StringVar(
+		&keyScheme,
+		"scheme",
+		"",
+		`Signature scheme of the passed keys, e.g. 'rsa-pkcs1v15-sha256'.
+If not passed, the default scheme for the key type is used.`,
+	)
+
+	verifyProvenanceCmd.Flags().StringSliceVar(
+		&policyBuilderIDs,
+		"builder-id",
+		[]string{},
+		`Accepted builder ID(s).`,
+	)
+
+	verifyProvenanceCmd.Flags().StringSliceVar(
+		&policyBuildTypes,
+		"build-type",
+		[]string{},
+		`Accepted build type(s).`,
+	)
+
+	verifyProvenanceCmd.Flags().StringVar(
+		&policySourceRepo,
+		"source-repo",
+		"",
+		`Expected source repository of the build, e.g.
+'git+https://github.com/in-toto/in-toto-golang'.`,
+	)
+
+	verifyProvenanceCmd.Flags().StringVar(
+		&policySourceRef,
+		"source-ref",
+		"",
+		`Expected source ref of the build, e.g. 'refs/heads/master'.`,
+	)
+
+	verifyProvenanceCmd.Flags().StringSliceVar(
+		&policyParameters,
+		"allowed-parameter",
+		nil,
+		`Name(s) of the external parameters the build may have been
+invoked with. If not passed, any parameters are allowed.`,
+	)
+
+	verifyProvenanceCmd.Flags().StringVar(
+		&artifactPath,
+		"artifact",
+		"",
+		`Path to an artifact, whose sha256 digest must match a subject
+of the provenance.`,
+	)
+
+	verifyProvenanceCmd.MarkFlagRequired("file") //nolint:errcheck
+	verifyProvenanceCmd.MarkFlagRequired("key")  //nolint:errcheck
+}
+
+func verifyProvenance(cmd *cobra.Command, args []string) error {
+	metadata, err := intoto.LoadMetadata(provenancePath)
+	if err != nil {
+		return fmt.Errorf("failed to load provenance at %s: %w", provenancePath, err)
+	}
+	env, ok := metadata.(*intoto.Envelope)
+	if !ok {
+		return fmt.Errorf("provenance at %s is not a DSSE envelope", provenancePath)
+	}
+
+	keys := make(map[string]intoto.Key, len(pubKeyPaths))
+	for _, pubKeyPath := range pubKeyPaths {
+		var pubKey intoto.Key
+		if err := loadKeyWithScheme(&pubKey, pubKeyPath); err != nil {
+			return fmt.Errorf("invalid key at %s: %w", pubKeyPath, err)
+		}
+		keys[pubKey.KeyID] = pubKey
+	}
+
+	policy := intoto.ProvenancePolicy{
+		BuilderIDs:       policyBuilderIDs,
+		BuildTypes:       policyBuildTypes,
+		SourceRepository: policySourceRepo,
+		SourceRef:        policySourceRef,
+	}
+	if cmd.Flags().Changed("allowed-parameter") {
+		policy.AllowedExternalParameters = policyParameters
+	}
+	if artifactPath != "" {
+		policy.ArtifactDigest, err = intoto.RecordArtifact(artifactPath, []string{"sha256"}, false)
+		if err != nil {
+			return fmt.Errorf("failed to record artifact %s: %w", artifactPath, err)
+		}
+	}
+
+	if err := intoto.VerifyProvenance(env, keys, policy); err != nil {
+		return fmt.Errorf("provenance verification failed: %w", err)
+	}
+
+	return nil
+}
@@ -25,6 +25,9 @@ const (
 	PredicateCycloneDX = "https://cyclonedx.org/bom"
 	// PredicateLinkV1 represents an in-toto 0.9 link.
 	PredicateLinkV1 = "https://in-toto.io/Link/v1"
+	// PredicateLinkV03 represents an ITE-6 in-toto link predicate, whose
+	// products are the subjects of the statement.
+	PredicateLinkV03 = "https://in-toto.io/attestation/link/v0.3"
 )
 
 // Subject describes the set of software artifacts the statement applies to.
@@ -111,11 +114,10 @@ type LinkStatement struct {
 
 /*
 SPDXStatement is the definition for an entire SPDX statement.
-This is currently not implemented. Some tooling exists here:
-https://github.com/spdx/tools-golang, but this software is still in
-early state.
 This struct is the same as the generic Statement struct but is added for
-completeness
+completeness.  Use DecodeSPDXPredicate to decode the packages of an SPDX 2.3
+predicate.  Full SPDX tooling exists here:
+https://github.com/spdx/tools-golang
 */
 type SPDXStatement struct {
 	StatementHeader
@@ -123,9 +125,9 @@ type SPDXStatement struct {
 }
 
 /*
-CycloneDXStatement defines a cyclonedx sbom in the predicate. It is not
-currently serialized just as its SPDX counterpart. It is an empty
-interface, like the generic Statement.
+CycloneDXStatement defines a cyclonedx sbom in the predicate. It is an empty
+interface, like the generic Statement.  Use DecodeCycloneDXPredicate to decode
+the components of a CycloneDX 1.5 predicate.
 */
 type CycloneDXStatement struct {
 	StatementHeader
@@ -0,0 +1,233 @@
+package in_toto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+)
+
+// ErrBundleVerification indicates that an envelope of a bundle is not signed by any of the keys
+var ErrBundleVerification = errors.New("bundle envelope is not signed by any of the keys")
+
+/*
+BundleFilter selects envelopes of an attestation bundle.  An envelope matches
+if its Statement has one of the PredicateTypes, a subject matching the
+SubjectDigest, and a signature by one of the KeyIDs.  Empty fields match any
+envelope.  Note that KeyIDs only match the unverified keyid of signatures, i.e.
+filtering is no authentication.  Use VerifyBundle to verify the signatures of
+the selected envelopes.
+*/
+type BundleFilter struct {
+	PredicateTypes []string
+	SubjectDigest  HashObj
+	KeyIDs         []string
+}
+
+/*
+decodeBundleEnvelope decodes a DSSE envelope of an attestation bundle.  Its
+payload is a *ParsedStatement, see decodeStatement, as for LoadMetadata, except
+that Statements usable as link are not converted to a link.
+*/
+func decodeBundleEnvelope(jsonBytes []byte) (*Envelope, error) {
+	dsseEnv := &dsse.Envelope{}
+	if err := json.Unmarshal(jsonBytes, dsseEnv); err != nil {
+		return nil, err
+	}
+	if dsseEnv.PayloadType != PayloadType {
+		return nil, ErrInvalidPayloadType
+	}
+
+	payloadBytes, err := dsseEnv.DecodeB64Payload()
+	if err != nil {
+		return nil, err
+	}
+	statement, err := decodeStatement(payloadBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &Envelope{envelope: dsseEnv, payload: statement, extensions: map[string]*signatureExtension{}}
+	if err := env.loadExtensions(jsonBytes); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+/*
+LoadBundleEnvelope loads a single DSSE envelope with an ITE-6 Statement from
+the passed path, e.g. to append it to an attestation bundle.  As for envelopes
+in bundles, its payload is a *ParsedStatement.
+*/
+func LoadBundleEnvelope(path string) (*Envelope, error) {
+	jsonBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBundleEnvelope(jsonBytes)
+}
+
+/*
+ReadBundle reads an attestation bundle in JSON Lines format, i.e. one DSSE
+envelope with an ITE-6 Statement per line, as commonly stored in
+'.intoto.jsonl' files.  Empty lines are skipped.
+*/
+func ReadBundle(r io.Reader) ([]*Envelope, error) {
+	envs := []*Envelope{}
+	reader := bufio.NewReader(r)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			env, loadErr := decodeBundleEnvelope(line)
+			if loadErr != nil {
+				return nil, fmt.Errorf("invalid envelope in line %d: %w", lineNumber, loadErr)
+			}
+			envs = append(envs, env)
+		}
+
+		if err == io.EOF {
+			return envs, nil
+		}
+	}
+}
+
+// LoadBundle reads the attestation bundle at the passed path using ReadBundle.
+func LoadBundle(path string) ([]*Envelope, error) {
+	bundleFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer bundleFile.Close()
+
+	return ReadBundle(bundleFile)
+}
+
+// WriteBundle writes the passed envelopes as attestation bundle, one per line.
+func WriteBundle(w io.Writer, envs []*Envelope) error {
+	for _, env := range envs {
+		jsonBytes, err := json.Marshal(env.withExtensions())
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(jsonBytes, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+AppendToBundle appends the passed envelopes to the attestation bundle at the
+passed path, which is created if it does not exist.
+*/
+func AppendToBundle(path string, envs ...*Envelope) error {
+	// Open file for appending with permissions (-rw-r--r--)
+	bundleFile, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteBundle(bundleFile, envs); err != nil {
+		bundleFile.Close()
+		return err
+	}
+	return bundleFile.Close()
+}
+
+/*
+matchesBundleFilter returns true if the passed envelope is selected by the
+passed filter.  Envelopes without valid Statement only match the empty filter.
+*/
+func matchesBundleFilter(env *Envelope, filter BundleFilter) bool {
+	if len(filter.KeyIDs) > 0 {
+		signed := false
+		for _, sig := range env.Sigs() {
+			for _, keyID := range filter.KeyIDs {
+				signed = signed || sig.KeyID == keyID
+			}
+		}
+		if !signed {
+			return false
+		}
+	}
+
+	if len(filter.PredicateTypes) == 0 && len(filter.SubjectDigest) == 0 {
+		return true
+	}
+
+	payloadBytes, err := env.envelope.DecodeB64Payload()
+	if err != nil {
+		return false
+	}
+	statement, _, err := parseStatementHeader(payloadBytes)
+	if err != nil {
+		return false
+	}
+
+	if len(filter.PredicateTypes) > 0 {
+		matched := false
+		for _, predicateType := range filter.PredicateTypes {
+			matched = matched || statement.PredicateType == predicateType
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(filter.SubjectDigest) > 0 {
+		for _, subject := range statement.Subject {
+			if matchesDigest(filter.SubjectDigest, subject.GetDigest()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// FilterBundle returns the envelopes selected by the passed filter in order.
+func FilterBundle(envs []*Envelope, filter BundleFilter) []*Envelope {
+	filtered := []*Envelope{}
+	for _, env := range envs {
+		if matchesBundleFilter(env, filter) {
+			filtered = append(filtered, env)
+		}
+	}
+	return filtered
+}
+
+/*
+VerifyBundle verifies that every passed envelope carries a valid signature for
+at least one of the passed keys.  If the key map is empty or an envelope is not
+signed by any of the keys, an error naming the envelope's position in the
+bundle is returned.
+*/
+func VerifyBundle(envs []*Envelope, keys map[string]Key) error {
+	if len(keys) < 1 {
+		return fmt.Errorf("bundle verification requires at least one key")
+	}
+
+	for i, env := range envs {
+		verified := false
+		for _, key := range keys {
+			if err := env.VerifySignature(key); err == nil {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			return fmt.Errorf("%w: envelope %d", ErrBundleVerification, i+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundle(t *testing.T) {
+	var carol, carolPub, dan, danPub Key
+	assert.Nil(t, carol.LoadKeyDefaults("carol"))
+	assert.Nil(t, carolPub.LoadKeyDefaults("carol.pub"))
+	assert.Nil(t, dan.LoadKeyDefaults("dan"))
+	assert.Nil(t, danPub.LoadKeyDefaults("dan.pub"))
+
+	link := Link{
+		Type:      "link",
+		Name:      "build",
+		Materials: map[string]HashObj{"src": {"sha256": testSourceDigest}},
+		Products:  map[string]HashObj{"app": {"sha256": testAppDigest}},
+	}
+	provenance, err := NewProvenanceEnvelope(link, ProvenanceOptions{BuilderID: "builder", BuildType: "type"}, carol)
+	assert.Nil(t, err)
+	vsa, err := NewVerificationSummaryEnvelope(link, VerificationSummaryOptions{
+		PolicyURI:   "https://example.com/root.layout",
+		ResourceURI: "https://example.com/app",
+	}, dan)
+	assert.Nil(t, err)
+	custom := &Envelope{}
+	assert.Nil(t, custom.SetPayload(map[string]any{
+		"_type":         StatementInTotoV1,
+		"subject":       []map[string]any{{"name": "src", "digest": map[string]string{"sha256": testSourceDigest}}},
+		"predicateType": "https://example.com/custom/v1",
+		"predicate":     map[string]any{"custom": true},
+	}))
+	assert.Nil(t, custom.Sign(carol))
+
+	bundlePath := filepath.Join(t.TempDir(), "attestations.intoto.jsonl")
+	assert.Nil(t, AppendToBundle(bundlePath, provenance, vsa))
+	assert.Nil(t, AppendToBundle(bundlePath, custom))
+
+	envs, err := LoadBundle(bundlePath)
+	if !assert.Nil(t, err) || !assert.Len(t, envs, 3) {
+		return
+	}
+	statement, ok := envs[0].GetPayload().(*ParsedStatement)
+	if assert.True(t, ok, "payload must be parsed statement") {
+		assert.Equal(t, slsa1.PredicateSLSAProvenance, statement.PredicateType)
+		assert.IsType(t, slsa1.ProvenancePredicate{}, statement.Predicate)
+	}
+	statement, ok = envs[2].GetPayload().(*ParsedStatement)
+	if assert.True(t, ok, "payload must be parsed statement") {
+		assert.JSONEq(t, `{"custom": true}`, string(statement.Predicate.(json.RawMessage)))
+	}
+
+	tables := []struct {
+		name     string
+		filter   BundleFilter
+		expected []*Envelope
+	}{
+		{"no filter", BundleFilter{}, envs},
+		{"predicate type", BundleFilter{PredicateTypes: []string{PredicateVSAV1, "https://example.com/custom/v1"}}, envs[1:]},
+		{"subject digest", BundleFilter{SubjectDigest: HashObj{"sha256": testAppDigest}}, envs[:2]},
+		{"key id", BundleFilter{KeyIDs: []string{carol.KeyID}}, []*Envelope{envs[0], envs[2]}},
+		{"combined", BundleFilter{KeyIDs: []string{carol.KeyID}, SubjectDigest: HashObj{"sha256": testSourceDigest}}, envs[2:]},
+		{"no match", BundleFilter{PredicateTypes: []string{PredicateSPDX}}, []*Envelope{}},
+	}
+	for _, table := range tables {
+		assert.Equal(t, table.expected, FilterBundle(envs, table.filter), table.name)
+	}
+
+	assert.Nil(t, VerifyBundle(envs, map[string]Key{carolPub.KeyID: carolPub, danPub.KeyID: danPub}))
+	assert.ErrorIs(t, VerifyBundle(envs, map[string]Key{carolPub.KeyID: carolPub}), ErrBundleVerification)
+	assert.NotNil(t, VerifyBundle(envs, map[string]Key{}))
+
+	// Bundles can be round tripped
+	roundTripPath := filepath.Join(t.TempDir(), "round-trip.intoto.jsonl")
+	assert.Nil(t, AppendToBundle(roundTripPath, envs...))
+	original, _ := os.ReadFile(bundlePath)
+	roundTrip, _ := os.ReadFile(roundTripPath)
+	assert.Equal(t, string(original), string(roundTrip))
+
+	// Predicates that are invalid for their registered decoder are kept raw
+	invalid := &Envelope{}
+	assert.Nil(t, invalid.SetPayload(map[string]any{
+		"_type":         StatementInTotoV1,
+		"subject":       []map[string]any{{"name": "app", "digest": map[string]string{"sha256": testAppDigest}}},
+		"predicateType": slsa1.PredicateSLSAProvenance,
+		"predicate":     map[string]any{"buildDefinition": "invalid"},
+	}))
+	assert.Nil(t, AppendToBundle(bundlePath, invalid))
+	envs, err = LoadBundle(bundlePath)
+	if assert.Nil(t, err) && assert.Len(t, envs, 4) {
+		statement, ok = envs[3].GetPayload().(*ParsedStatement)
+		if assert.True(t, ok, "payload must be parsed statement") {
+			assert.JSONEq(t, `{"buildDefinition": "invalid"}`, string(statement.Predicate.(json.RawMessage)))
+		}
+	}
+	original, _ = os.ReadFile(bundlePath)
+
+	// Invalid lines are reported
+	assert.Nil(t, os.WriteFile(bundlePath, append(original, []byte("\n{}\n")...), 0644))
+	_, err = LoadBundle(bundlePath)
+	assert.ErrorContains(t, err, "line 6")
+}
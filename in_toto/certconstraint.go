@@ -2,85 +2,261 @@ package in_toto
 
 import (
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"fmt"
 	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
 const (
 	AllowAllConstraint = "*"
+	// RegexConstraintPrefix marks a constraint value as regular expression,
+	// which must match the whole attribute value.
+	RegexConstraintPrefix = "regex:"
+	// GlobConstraintPrefix marks a constraint value as glob pattern.
+	GlobConstraintPrefix = "glob:"
 )
 
 // CertificateConstraint defines the attributes a certificate must have to act as a functionary.
 // A wildcard `*` allows any value in the specified attribute, where as an empty array or value
 // asserts that the certificate must have nothing for that attribute. A certificate must have
 // every value defined in a constraint to match.
+//
+// Values of CommonName, DNSNames, Emails and URIs may be patterns, which match
+// any attribute value they describe. Values prefixed with `glob:` are glob
+// patterns with the syntax of path.Match, where `*` does not match across `/`
+// and, in DNS names, across `.`. SPIFFE ID patterns, e.g.
+// `glob:spiffe://example.com/ci/*`, are matched segment by segment, and a `**`
+// segment matches any number of path segments. Values prefixed with `regex:`
+// are regular expressions, which must match the whole attribute value. Other
+// values match literally.
+// Every value of a certificate attribute must match a constraint value, and
+// every constraint value must match a value of the certificate attribute.
+//
+// The extended attributes ExtKeyUsages, KeyUsages, PolicyOIDs,
+// OrganizationalUnits and Extensions are optional and only checked if set, so
+// that constraints written before they existed keep their meaning.
+// OrganizationalUnits is matched like Organizations. For ExtKeyUsages,
+// KeyUsages, PolicyOIDs and Extensions the certificate must have every listed
+// value, but may have others.
 type CertificateConstraint struct {
-	CommonName    string   `json:"common_name"`
-	DNSNames      []string `json:"dns_names"`
-	Emails        []string `json:"emails"`
-	Organizations []string `json:"organizations"`
-	Roots         []string `json:"roots"`
-	URIs          []string `json:"uris"`
+	CommonName          string                `json:"common_name"`
+	DNSNames            []string              `json:"dns_names"`
+	Emails              []string              `json:"emails"`
+	Organizations       []string              `json:"organizations"`
+	Roots               []string              `json:"roots"`
+	URIs                []string              `json:"uris"`
+	OrganizationalUnits []string              `json:"organizational_units,omitempty"`
+	ExtKeyUsages        []string              `json:"ext_key_usages,omitempty"`
+	KeyUsages           []string              `json:"key_usages,omitempty"`
+	PolicyOIDs          []string              `json:"policy_oids,omitempty"`
+	Extensions          []ExtensionConstraint `json:"extensions,omitempty"`
+}
+
+// ExtensionConstraint requires a certificate to have the extension with the
+// given OID, in dotted notation, and value. Values that are DER encoded strings,
+// such as the Fulcio OIDC issuer (1.3.6.1.4.1.57264.1.8) or source repository
+// ref (1.3.6.1.4.1.57264.1.14) extensions, are compared after decoding, other
+// values are compared as raw bytes. A wildcard `*` value allows any value.
+type ExtensionConstraint struct {
+	OID   string `json:"oid"`
+	Value string `json:"value"`
+}
+
+// extKeyUsageNames maps the names accepted in ExtKeyUsages constraints to the
+// extended key usages they stand for.
+var extKeyUsageNames = map[string]x509.ExtKeyUsage{
+	"any":                               x509.ExtKeyUsageAny,
+	"server_auth":                       x509.ExtKeyUsageServerAuth,
+	"client_auth":                       x509.ExtKeyUsageClientAuth,
+	"code_signing":                      x509.ExtKeyUsageCodeSigning,
+	"email_protection":                  x509.ExtKeyUsageEmailProtection,
+	"ipsec_end_system":                  x509.ExtKeyUsageIPSECEndSystem,
+	"ipsec_tunnel":                      x509.ExtKeyUsageIPSECTunnel,
+	"ipsec_user":                        x509.ExtKeyUsageIPSECUser,
+	"time_stamping":                     x509.ExtKeyUsageTimeStamping,
+	"ocsp_signing":                      x509.ExtKeyUsageOCSPSigning,
+	"microsoft_server_gated_crypto":     x509.ExtKeyUsageMicrosoftServerGatedCrypto,
+	"netscape_server_gated_crypto":      x509.ExtKeyUsageNetscapeServerGatedCrypto,
+	"microsoft_commercial_code_signing": x509.ExtKeyUsageMicrosoftCommercialCodeSigning,
+	"microsoft_kernel_code_signing":     x509.ExtKeyUsageMicrosoftKernelCodeSigning,
+}
+
+// extKeyUsageOIDs maps extended key usages to their OIDs, so that constraints
+// may use either names or OIDs.
+var extKeyUsageOIDs = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "2.5.29.37.0",
+	x509.ExtKeyUsageServerAuth:                     "1.3.6.1.5.5.7.3.1",
+	x509.ExtKeyUsageClientAuth:                     "1.3.6.1.5.5.7.3.2",
+	x509.ExtKeyUsageCodeSigning:                    "1.3.6.1.5.5.7.3.3",
+	x509.ExtKeyUsageEmailProtection:                "1.3.6.1.5.5.7.3.4",
+	x509.ExtKeyUsageIPSECEndSystem:                 "1.3.6.1.5.5.7.3.5",
+	x509.ExtKeyUsageIPSECTunnel:                    "1.3.6.1.5.5.7.3.6",
+	x509.ExtKeyUsageIPSECUser:                      "1.3.6.1.5.5.7.3.7",
+	x509.ExtKeyUsageTimeStamping:                   "1.3.6.1.5.5.7.3.8",
+	x509.ExtKeyUsageOCSPSigning:                    "1.3.6.1.5.5.7.3.9",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "1.3.6.1.4.1.311.10.3.3",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "2.16.840.1.113730.4.1",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "1.3.6.1.4.1.311.2.1.22",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "1.3.6.1.4.1.311.61.1.1",
+}
+
+// keyUsageNames maps the names accepted in KeyUsages constraints to the key
+// usage bits they stand for.
+var keyUsageNames = map[string]x509.KeyUsage{
+	"digital_signature":  x509.KeyUsageDigitalSignature,
+	"content_commitment": x509.KeyUsageContentCommitment,
+	"key_encipherment":   x509.KeyUsageKeyEncipherment,
+	"data_encipherment":  x509.KeyUsageDataEncipherment,
+	"key_agreement":      x509.KeyUsageKeyAgreement,
+	"cert_sign":          x509.KeyUsageCertSign,
+	"crl_sign":           x509.KeyUsageCRLSign,
+	"encipher_only":      x509.KeyUsageEncipherOnly,
+	"decipher_only":      x509.KeyUsageDecipherOnly,
+}
+
+// AttributeCheck is the result of checking one certificate attribute against a
+// constraint. Expected holds the values of the constraint and Actual the values
+// of the certificate they were compared with. Error is empty if the check passed.
+type AttributeCheck struct {
+	Attribute string   `json:"attribute"`
+	Expected  []string `json:"expected"`
+	Actual    []string `json:"actual"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// ConstraintResult is the result of checking a certificate against a constraint.
+// It lists every attribute that was checked, and the error building the chain of
+// trust of the certificate, if any.
+type ConstraintResult struct {
+	Constraint CertificateConstraint `json:"constraint"`
+	Checks     []AttributeCheck      `json:"checks"`
+	ChainError string                `json:"chain_error,omitempty"`
+}
+
+// Passed returns true if the certificate passed every check of the constraint.
+func (r ConstraintResult) Passed() bool {
+	return len(r.failures()) == 0
+}
+
+// Err reduces all of the errors into one error with a
+// combined error message. If there are no errors, nil
+// will be returned.
+func (r ConstraintResult) Err() error {
+	failures := r.failures()
+	if len(failures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("cert failed constraints check: %+q", failures)
+}
+
+// failures returns the errors of the failed attribute checks.
+func (r ConstraintResult) failures() []string {
+	failures := []string{}
+	for _, check := range r.Checks {
+		if check.Error != "" {
+			failures = append(failures, check.Error)
+		}
+	}
+	return failures
 }
 
 // checkResult is a data structure used to hold
-// certificate constraint errors
+// certificate constraint check results
 type checkResult struct {
-	errors []error
+	result ConstraintResult
 }
 
 // newCheckResult initializes a new checkResult
-func newCheckResult() *checkResult {
+func newCheckResult(constraint CertificateConstraint) *checkResult {
 	return &checkResult{
-		errors: make([]error, 0),
+		result: ConstraintResult{
+			Constraint: constraint,
+			Checks:     make([]AttributeCheck, 0),
+		},
 	}
 }
 
-// evaluate runs a constraint check on a certificate
-func (cr *checkResult) evaluate(cert *x509.Certificate, constraintCheck func(*x509.Certificate) error) *checkResult {
+// evaluate runs a constraint check on a certificate and records its result for the
+// attribute with the passed expected and actual values
+func (cr *checkResult) evaluate(cert *x509.Certificate, attribute string, expected, actual []string, constraintCheck func(*x509.Certificate) error) *checkResult {
+	check := AttributeCheck{
+		Attribute: attribute,
+		Expected:  expected,
+		Actual:    actual,
+	}
 	err := constraintCheck(cert)
 	if err != nil {
-		cr.errors = append(cr.errors, err)
+		check.Error = err.Error()
 	}
+	cr.result.Checks = append(cr.result.Checks, check)
 	return cr
 }
 
-// error reduces all of the errors into one error with a
-// combined error message. If there are no errors, nil
-// will be returned.
-func (cr *checkResult) error() error {
-	if len(cr.errors) == 0 {
-		return nil
+// evaluateOptional works like evaluate, but skips optional attributes that the
+// constraint does not set
+func (cr *checkResult) evaluateOptional(cert *x509.Certificate, attribute string, expected, actual []string, constraintCheck func(*x509.Certificate) error) *checkResult {
+	if expected == nil {
+		return cr
 	}
-	return fmt.Errorf("cert failed constraints check: %+q", cr.errors)
+	return cr.evaluate(cert, attribute, expected, actual, constraintCheck)
 }
 
 // Check tests the provided certificate against the constraint. An error is returned if the certificate
 // fails any of the constraints. nil is returned if the certificate passes all of the constraints.
 func (cc CertificateConstraint) Check(cert *x509.Certificate, rootCAIDs []string, rootCertPool, intermediateCertPool *x509.CertPool) error {
-	return newCheckResult().
-		evaluate(cert, cc.checkCommonName).
-		evaluate(cert, cc.checkDNSNames).
-		evaluate(cert, cc.checkEmails).
-		evaluate(cert, cc.checkOrganizations).
-		evaluate(cert, cc.checkRoots(rootCAIDs, rootCertPool, intermediateCertPool)).
-		evaluate(cert, cc.checkURIs).
-		error()
+	return cc.CheckAt(cert, rootCAIDs, rootCertPool, intermediateCertPool, time.Time{})
+}
+
+// CheckAt works like Check, but verifies the chain of trust of the certificate at the passed time
+// instead of the current time, unless it is the zero time.
+func (cc CertificateConstraint) CheckAt(cert *x509.Certificate, rootCAIDs []string, rootCertPool, intermediateCertPool *x509.CertPool, verificationTime time.Time) error {
+	return cc.Evaluate(cert, rootCAIDs, rootCertPool, intermediateCertPool, verificationTime).Err()
+}
+
+// Evaluate tests the provided certificate against the constraint like CheckAt, but returns the
+// result of every attribute check instead of a single error, to explain why a certificate does
+// or does not match the constraint.
+func (cc CertificateConstraint) Evaluate(cert *x509.Certificate, rootCAIDs []string, rootCertPool, intermediateCertPool *x509.CertPool, verificationTime time.Time) ConstraintResult {
+	_, chainErr := VerifyCertificateTrustAt(cert, rootCertPool, intermediateCertPool, verificationTime)
+
+	cr := newCheckResult(cc).
+		evaluate(cert, "common name", []string{cc.CommonName}, []string{cert.Subject.CommonName}, cc.checkCommonName).
+		evaluate(cert, "dns name", cc.DNSNames, cert.DNSNames, cc.checkDNSNames).
+		evaluate(cert, "email", cc.Emails, cert.EmailAddresses, cc.checkEmails).
+		evaluate(cert, "organization", cc.Organizations, cert.Subject.Organization, cc.checkOrganizations).
+		evaluate(cert, "root", cc.Roots, rootCAIDs, cc.checkRoots(rootCAIDs, chainErr)).
+		evaluate(cert, "uri", cc.URIs, urisToStrings(cert.URIs), cc.checkURIs).
+		evaluateOptional(cert, "organizational unit", cc.OrganizationalUnits, cert.Subject.OrganizationalUnit, cc.checkOrganizationalUnits).
+		evaluateOptional(cert, "extended key usage", cc.ExtKeyUsages, certExtKeyUsages(cert), cc.checkExtKeyUsages).
+		evaluateOptional(cert, "key usage", cc.KeyUsages, certKeyUsages(cert), cc.checkKeyUsages).
+		evaluateOptional(cert, "policy", cc.PolicyOIDs, certPolicyOIDs(cert), cc.checkPolicyOIDs).
+		evaluateOptional(cert, "extension", cc.extensionConstraintStrings(), cc.certExtensionStrings(cert), cc.checkExtensions)
+
+	if chainErr != nil {
+		cr.result.ChainError = chainErr.Error()
+	}
+	return cr.result
 }
 
 // checkCommonName verifies that the certificate's common name matches the constraint.
 func (cc CertificateConstraint) checkCommonName(cert *x509.Certificate) error {
-	return checkCertConstraint("common name", []string{cc.CommonName}, []string{cert.Subject.CommonName})
+	return checkCertConstraintMatch("common name", []string{cc.CommonName}, []string{cert.Subject.CommonName}, matchGlob)
 }
 
 // checkDNSNames verifies that the certificate's dns names matches the constraint.
 func (cc CertificateConstraint) checkDNSNames(cert *x509.Certificate) error {
-	return checkCertConstraint("dns name", cc.DNSNames, cert.DNSNames)
+	return checkCertConstraintMatch("dns name", cc.DNSNames, cert.DNSNames, matchDNSName)
 }
 
 // checkEmails verifies that the certificate's emails matches the constraint.
 func (cc CertificateConstraint) checkEmails(cert *x509.Certificate) error {
-	return checkCertConstraint("email", cc.Emails, cert.EmailAddresses)
+	return checkCertConstraintMatch("email", cc.Emails, cert.EmailAddresses, matchGlob)
 }
 
 // checkOrganizations verifies that the certificate's organizations matches the constraint.
@@ -89,12 +265,12 @@ func (cc CertificateConstraint) checkOrganizations(cert *x509.Certificate) error
 }
 
 // checkRoots verifies that the certificate's roots matches the constraint.
-// The certificates trust chain must also be verified.
-func (cc CertificateConstraint) checkRoots(rootCAIDs []string, rootCertPool, intermediateCertPool *x509.CertPool) func(*x509.Certificate) error {
+// The certificates trust chain must also be verified, the passed error is the
+// result of verifying it.
+func (cc CertificateConstraint) checkRoots(rootCAIDs []string, chainErr error) func(*x509.Certificate) error {
 	return func(cert *x509.Certificate) error {
-		_, err := VerifyCertificateTrust(cert, rootCertPool, intermediateCertPool)
-		if err != nil {
-			return fmt.Errorf("failed to verify roots: %w", err)
+		if chainErr != nil {
+			return fmt.Errorf("failed to verify roots: %w", chainErr)
 		}
 		return checkCertConstraint("root", cc.Roots, rootCAIDs)
 	}
@@ -102,7 +278,160 @@ func (cc CertificateConstraint) checkRoots(rootCAIDs []string, rootCertPool, int
 
 // checkURIs verifies that the certificate's URIs matches the constraint.
 func (cc CertificateConstraint) checkURIs(cert *x509.Certificate) error {
-	return checkCertConstraint("uri", cc.URIs, urisToStrings(cert.URIs))
+	return checkCertConstraintMatch("uri", cc.URIs, urisToStrings(cert.URIs), matchURI)
+}
+
+// checkOrganizationalUnits verifies that the certificate's organizational units match the
+// constraint, if it is set.
+func (cc CertificateConstraint) checkOrganizationalUnits(cert *x509.Certificate) error {
+	if cc.OrganizationalUnits == nil {
+		return nil
+	}
+	return checkCertConstraint("organizational unit", cc.OrganizationalUnits, cert.Subject.OrganizationalUnit)
+}
+
+// checkExtKeyUsages verifies that the certificate has every extended key usage of the
+// constraint. Extended key usages are identified by name, e.g. `code_signing`, or by OID.
+func (cc CertificateConstraint) checkExtKeyUsages(cert *x509.Certificate) error {
+	values := certExtKeyUsages(cert)
+
+	constraints := make([]string, 0, len(cc.ExtKeyUsages))
+	for _, constraint := range cc.ExtKeyUsages {
+		if constraint == AllowAllConstraint {
+			constraints = append(constraints, constraint)
+			continue
+		}
+		if usage, ok := extKeyUsageNames[constraint]; ok {
+			constraints = append(constraints, extKeyUsageOIDs[usage])
+			continue
+		}
+		if _, err := x509.ParseOID(constraint); err != nil {
+			return fmt.Errorf("unknown extended key usage %s", constraint)
+		}
+		constraints = append(constraints, constraint)
+	}
+	return checkRequiredCertValues("extended key usage", constraints, values)
+}
+
+// checkKeyUsages verifies that the certificate has every key usage of the constraint.
+// Key usages are identified by name, e.g. `digital_signature`.
+func (cc CertificateConstraint) checkKeyUsages(cert *x509.Certificate) error {
+	values := certKeyUsages(cert)
+
+	for _, constraint := range cc.KeyUsages {
+		if _, ok := keyUsageNames[constraint]; !ok && constraint != AllowAllConstraint {
+			return fmt.Errorf("unknown key usage %s", constraint)
+		}
+	}
+	return checkRequiredCertValues("key usage", cc.KeyUsages, values)
+}
+
+// checkPolicyOIDs verifies that the certificate has every certificate policy of the constraint.
+func (cc CertificateConstraint) checkPolicyOIDs(cert *x509.Certificate) error {
+	return checkRequiredCertValues("policy", cc.PolicyOIDs, certPolicyOIDs(cert))
+}
+
+// checkExtensions verifies that the certificate has every extension of the constraint
+// with the expected value.
+func (cc CertificateConstraint) checkExtensions(cert *x509.Certificate) error {
+	for _, constraint := range cc.Extensions {
+		oid, err := x509.ParseOID(constraint.OID)
+		if err != nil {
+			return fmt.Errorf("invalid extension OID %s: %w", constraint.OID, err)
+		}
+
+		found := false
+		for _, ext := range cert.Extensions {
+			if !oid.EqualASN1OID(ext.Id) {
+				continue
+			}
+			found = true
+			value := extensionValue(ext)
+			if constraint.Value != AllowAllConstraint && value != constraint.Value {
+				return fmt.Errorf("cert has an unexpected value %q for extension %s, expected %q", value, constraint.OID, constraint.Value)
+			}
+			break
+		}
+		if !found {
+			return fmt.Errorf("cert does not have extension %s", constraint.OID)
+		}
+	}
+	return nil
+}
+
+// certExtKeyUsages returns the OIDs of the extended key usages of the certificate.
+func certExtKeyUsages(cert *x509.Certificate) []string {
+	values := make([]string, 0, len(cert.ExtKeyUsage)+len(cert.UnknownExtKeyUsage))
+	for _, usage := range cert.ExtKeyUsage {
+		values = append(values, extKeyUsageOIDs[usage])
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		values = append(values, oid.String())
+	}
+	return values
+}
+
+// certKeyUsages returns the sorted names of the key usages of the certificate.
+func certKeyUsages(cert *x509.Certificate) []string {
+	values := []string{}
+	for name, usage := range keyUsageNames {
+		if cert.KeyUsage&usage != 0 {
+			values = append(values, name)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// certPolicyOIDs returns the OIDs of the certificate policies of the certificate.
+func certPolicyOIDs(cert *x509.Certificate) []string {
+	values := make([]string, 0, len(cert.Policies))
+	for _, oid := range cert.Policies {
+		values = append(values, oid.String())
+	}
+	return values
+}
+
+// extensionConstraintStrings returns the extension constraints as `oid=value` strings,
+// or nil if the constraint has none.
+func (cc CertificateConstraint) extensionConstraintStrings() []string {
+	if cc.Extensions == nil {
+		return nil
+	}
+	values := make([]string, 0, len(cc.Extensions))
+	for _, constraint := range cc.Extensions {
+		values = append(values, constraint.OID+"="+constraint.Value)
+	}
+	return values
+}
+
+// certExtensionStrings returns the extensions of the certificate that the extension
+// constraints refer to as `oid=value` strings.
+func (cc CertificateConstraint) certExtensionStrings(cert *x509.Certificate) []string {
+	values := []string{}
+	for _, constraint := range cc.Extensions {
+		oid, err := x509.ParseOID(constraint.OID)
+		if err != nil {
+			continue
+		}
+		for _, ext := range cert.Extensions {
+			if oid.EqualASN1OID(ext.Id) {
+				values = append(values, constraint.OID+"="+extensionValue(ext))
+				break
+			}
+		}
+	}
+	return values
+}
+
+// extensionValue returns the value of the extension, decoded if it is a DER encoded string.
+// Other values, such as those of the original Fulcio extensions, are returned as raw bytes.
+func extensionValue(ext pkix.Extension) string {
+	var decoded string
+	if rest, err := asn1.Unmarshal(ext.Value, &decoded); err == nil && len(rest) == 0 {
+		return decoded
+	}
+	return string(ext.Value)
 }
 
 // urisToStrings is a helper that converts a list of URL objects to the string that represents them
@@ -118,6 +447,12 @@ func urisToStrings(uris []*url.URL) []string {
 // checkCertConstraint tests that the provided test values match the allowed values of the constraint.
 // All allowed values must be met one-to-one to be considered a successful match.
 func checkCertConstraint(attributeName string, constraints, values []string) error {
+	return checkCertConstraintMatch(attributeName, constraints, values, matchExact)
+}
+
+// checkCertConstraintMatch tests that every provided test value matches an allowed value of the
+// constraint, and that every allowed value matches a test value, using the passed match function.
+func checkCertConstraintMatch(attributeName string, constraints, values []string, match func(constraint, value string) (bool, error)) error {
 	// If the only constraint is to allow all, the check succeeds
 	if len(constraints) == 1 && constraints[0] == AllowAllConstraint {
 		return nil
@@ -138,13 +473,23 @@ func checkCertConstraint(attributeName string, constraints, values []string) err
 
 	unmet := NewSet(constraints...)
 	for _, v := range values {
+		matched := false
+		for _, c := range constraints {
+			ok, err := match(c, v)
+			if err != nil {
+				return fmt.Errorf("invalid %s constraint %s: %w", attributeName, c, err)
+			}
+			if ok {
+				// consider the constraint met
+				matched = true
+				unmet.Remove(c)
+			}
+		}
+
 		// if the cert has a value we didn't expect, fail early
-		if !unmet.Has(v) {
+		if !matched {
 			return fmt.Errorf("cert has an unexpected %s %s given constraints %+q", attributeName, v, constraints)
 		}
-
-		// consider the constraint met
-		unmet.Remove(v)
 	}
 
 	// if we have any unmet left after going through each test value, fail.
@@ -154,3 +499,130 @@ func checkCertConstraint(attributeName string, constraints, values []string) err
 
 	return nil
 }
+
+// matchExact matches a value that is equal to the constraint.
+func matchExact(constraint, value string) (bool, error) {
+	return constraint == value, nil
+}
+
+// matchRegex matches a value that the regular expression matches as a whole.
+func matchRegex(expression, value string) (bool, error) {
+	re, err := regexp.Compile("^(?:" + expression + ")$")
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(value), nil
+}
+
+// matchGlob matches a value against a constraint that is either a regular expression
+// with the regex prefix, a glob pattern with the glob prefix or a plain value.
+func matchGlob(constraint, value string) (bool, error) {
+	if expression, ok := strings.CutPrefix(constraint, RegexConstraintPrefix); ok {
+		return matchRegex(expression, value)
+	}
+	if pattern, ok := strings.CutPrefix(constraint, GlobConstraintPrefix); ok {
+		return path.Match(pattern, value)
+	}
+	return constraint == value, nil
+}
+
+// matchDNSName matches a DNS name case-insensitively. In glob patterns, `*` matches a
+// single label, such that `glob:*.example.com` matches `foo.example.com` but not
+// `foo.bar.example.com`.
+func matchDNSName(constraint, value string) (bool, error) {
+	if strings.HasPrefix(constraint, RegexConstraintPrefix) {
+		return matchGlob(constraint, value)
+	}
+	if pattern, ok := strings.CutPrefix(constraint, GlobConstraintPrefix); ok {
+		return matchDNSNamePattern(pattern, value)
+	}
+	return strings.EqualFold(constraint, value), nil
+}
+
+// matchDNSNamePattern matches a DNS name case-insensitively against a glob pattern
+// without prefix, whose `*` matches a single label.
+func matchDNSNamePattern(pattern, value string) (bool, error) {
+	toPath := func(name string) string {
+		return strings.ReplaceAll(strings.ToLower(name), ".", "/")
+	}
+	return path.Match(toPath(pattern), toPath(value))
+}
+
+// matchURI matches a URI, using matchSPIFFEID for SPIFFE ID patterns.
+func matchURI(constraint, value string) (bool, error) {
+	if pattern, ok := strings.CutPrefix(constraint, GlobConstraintPrefix); ok && strings.HasPrefix(pattern, "spiffe://") {
+		return matchSPIFFEID(pattern, value)
+	}
+	return matchGlob(constraint, value)
+}
+
+// matchSPIFFEID matches a SPIFFE ID against a SPIFFE ID pattern without prefix. The trust domain
+// is matched like a DNS name, and the path segment by segment with glob patterns,
+// where a `**` segment matches any number of segments.
+func matchSPIFFEID(constraint, value string) (bool, error) {
+	pattern, err := url.Parse(constraint)
+	if err != nil {
+		return false, err
+	}
+	id, err := url.Parse(value)
+	if err != nil || id.Scheme != "spiffe" || id.User != nil || id.RawQuery != "" || id.Fragment != "" {
+		return false, nil
+	}
+
+	ok, err := matchDNSNamePattern(pattern.Host, id.Host)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	split := func(p string) []string {
+		if p == "" || p == "/" {
+			return []string{}
+		}
+		return strings.Split(strings.TrimPrefix(p, "/"), "/")
+	}
+	return matchPathSegments(split(pattern.Path), split(id.Path))
+}
+
+// matchPathSegments matches path segments against glob pattern segments, where a
+// `**` segment matches any number of segments.
+func matchPathSegments(patterns, segments []string) (bool, error) {
+	if len(patterns) == 0 {
+		return len(segments) == 0, nil
+	}
+
+	if patterns[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			ok, err := matchPathSegments(patterns[1:], segments[i:])
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+
+	if len(segments) == 0 {
+		return false, nil
+	}
+	ok, err := path.Match(patterns[0], segments[0])
+	if err != nil || !ok {
+		return false, err
+	}
+	return matchPathSegments(patterns[1:], segments[1:])
+}
+
+// checkRequiredCertValues tests that the provided test values include every value of the
+// constraint. Unlike checkCertConstraint, additional values are allowed.
+func checkRequiredCertValues(attributeName string, constraints, values []string) error {
+	if len(constraints) == 1 && constraints[0] == AllowAllConstraint {
+		return nil
+	}
+
+	present := NewSet(values...)
+	for _, c := range constraints {
+		if !present.Has(c) {
+			return fmt.Errorf("cert with %s(s) %+q does not have required %s %s", attributeName, values, attributeName, c)
+		}
+	}
+
+	return nil
+}
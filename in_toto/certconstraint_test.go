@@ -7,12 +7,15 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
 	"fmt"
 	"math/big"
 	"net/url"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 type checkConstraintAttributeCase struct {
@@ -74,6 +77,51 @@ func TestCheckCertConstraint(t *testing.T) {
 	}
 }
 
+func TestCertConstraintPatterns(t *testing.T) {
+	tests := []struct {
+		name        string
+		match       func(constraint, value string) (bool, error)
+		constraints []string
+		values      []string
+		expected    bool
+	}{
+		{"glob email", matchGlob, []string{"glob:*@example.com"}, []string{"alice@example.com", "bob@example.com"}, true},
+		{"glob email mismatch", matchGlob, []string{"glob:*@example.com"}, []string{"alice@example.org"}, false},
+		{"glob common name", matchGlob, []string{"glob:step?.example.com"}, []string{"step1.example.com"}, true},
+		{"unmatched pattern", matchGlob, []string{"glob:*@example.com", "glob:*@example.org"}, []string{"alice@example.com"}, false},
+		{"literal without prefix", matchGlob, []string{"step?.example.com"}, []string{"step1.example.com"}, false},
+		{"literal metacharacters", matchGlob, []string{"step[1].example.com"}, []string{"step[1].example.com"}, true},
+		{"regex", matchGlob, []string{"regex:step[0-9]+"}, []string{"step12"}, true},
+		{"regex is anchored", matchGlob, []string{"regex:step[0-9]+"}, []string{"step12.example.com"}, false},
+		{"invalid regex", matchGlob, []string{"regex:step("}, []string{"step"}, false},
+		{"invalid glob", matchGlob, []string{"glob:step["}, []string{"step"}, false},
+		{"dns wildcard label", matchDNSName, []string{"glob:*.example.com"}, []string{"foo.Example.com"}, true},
+		{"dns wildcard single label", matchDNSName, []string{"glob:*.example.com"}, []string{"foo.bar.example.com"}, false},
+		{"dns literal wildcard", matchDNSName, []string{"*.example.com"}, []string{"foo.example.com"}, false},
+		{"dns literal case", matchDNSName, []string{"foo.example.com"}, []string{"foo.Example.com"}, true},
+		{"dns regex", matchDNSName, []string{`regex:.*\.example\.com`}, []string{"foo.bar.example.com"}, true},
+		{"uri glob", matchURI, []string{"glob:https://example.com/*"}, []string{"https://example.com/ci"}, true},
+		{"spiffe segment", matchURI, []string{"glob:spiffe://example.com/ci/*"}, []string{"spiffe://example.com/ci/build"}, true},
+		{"spiffe segment depth", matchURI, []string{"glob:spiffe://example.com/ci/*"}, []string{"spiffe://example.com/ci/build/linux"}, false},
+		{"spiffe any depth", matchURI, []string{"glob:spiffe://example.com/ci/**"}, []string{"spiffe://example.com/ci/build/linux"}, true},
+		{"spiffe any depth inner", matchURI, []string{"glob:spiffe://example.com/**/release"}, []string{"spiffe://example.com/ci/build/release"}, true},
+		{"spiffe trust domain", matchURI, []string{"glob:spiffe://example.com/ci/*"}, []string{"spiffe://example.org/ci/build"}, false},
+		{"spiffe trust domain glob", matchURI, []string{"glob:spiffe://*.example.com/ci/*"}, []string{"spiffe://prod.example.com/ci/build"}, true},
+		{"spiffe scheme", matchURI, []string{"glob:spiffe://example.com/ci/*"}, []string{"https://example.com/ci/build"}, false},
+		{"spiffe literal", matchURI, []string{"spiffe://example.com/ci/*"}, []string{"spiffe://example.com/ci/build"}, false},
+		{"spiffe exact", matchURI, []string{"spiffe://example.com/ci"}, []string{"spiffe://example.com/ci"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCertConstraintMatch("constraint", tt.constraints, tt.values, tt.match)
+			if actual := err == nil; actual != tt.expected {
+				t.Errorf("got %v when expected %v. Constraints: %v, Values: %v, Error: %v", actual, tt.expected, tt.constraints, tt.values, err)
+			}
+		})
+	}
+}
+
 type constraintCheckCase struct {
 	Constraint CertificateConstraint
 	Cert       *x509.Certificate
@@ -267,6 +315,100 @@ func TestConstraintCheck(t *testing.T) {
 			},
 			Expected: false,
 		},
+		{
+			Cert: testCert,
+			Constraint: CertificateConstraint{
+				CommonName:    "glob:*.example.com",
+				DNSNames:      []string{"example.com"},
+				Emails:        []string{"glob:*@example.com"},
+				Organizations: []string{"example"},
+				Roots:         []string{"example"},
+				URIs:          []string{"glob:spiffe://example.com/*"},
+			},
+			Expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		err := c.Constraint.Check(c.Cert, roots, rootCertPool, intermediateCertPool)
+		actual := err == nil
+		if actual != c.Expected {
+			t.Errorf("got %v when expected %v. Constraint: %+v, Errors: %s", actual, c.Expected, c.Constraint, err)
+		}
+	}
+}
+
+func TestConstraintCheckExtended(t *testing.T) {
+	issuerExtension, _ := asn1.Marshal("https://token.actions.githubusercontent.com")
+	policyOID, _ := x509.OIDFromInts([]uint64{1, 3, 6, 1, 4, 1, 99999, 1})
+	testCertTemplate := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:         "step1.example.com",
+			OrganizationalUnit: []string{"ci"},
+		},
+		Policies: []x509.OID{policyOID},
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 3}, Value: []byte("refs/heads/main")},
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}, Value: issuerExtension},
+		},
+	}
+
+	testCert, testIntermediateCert, testRootCert, err := createTestCert(testCertTemplate, x509.Ed25519, 1*time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create test cert: %v", err)
+	}
+
+	rootCertPool := x509.NewCertPool()
+	rootCertPool.AddCert(testRootCert)
+	intermediateCertPool := x509.NewCertPool()
+	intermediateCertPool.AddCert(testIntermediateCert)
+
+	roots := []string{"example"}
+	base := CertificateConstraint{
+		CommonName:    "*",
+		DNSNames:      []string{"*"},
+		Emails:        []string{"*"},
+		Organizations: []string{"*"},
+		Roots:         []string{"*"},
+		URIs:          []string{"*"},
+	}
+	with := func(modify func(*CertificateConstraint)) CertificateConstraint {
+		constraint := base
+		modify(&constraint)
+		return constraint
+	}
+
+	cases := []constraintCheckCase{
+		{Cert: testCert, Constraint: base, Expected: true},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) { c.OrganizationalUnits = []string{"ci"} }), Expected: true},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) { c.OrganizationalUnits = []string{"release"} }), Expected: false},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) { c.OrganizationalUnits = []string{} }), Expected: false},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) { c.ExtKeyUsages = []string{"client_auth"} }), Expected: true},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) { c.ExtKeyUsages = []string{"1.3.6.1.5.5.7.3.1", "client_auth"} }), Expected: true},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) { c.ExtKeyUsages = []string{"code_signing"} }), Expected: false},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) { c.ExtKeyUsages = []string{"unknown"} }), Expected: false},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) { c.KeyUsages = []string{"digital_signature"} }), Expected: true},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) { c.KeyUsages = []string{"cert_sign"} }), Expected: false},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) { c.PolicyOIDs = []string{"1.3.6.1.4.1.99999.1"} }), Expected: true},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) { c.PolicyOIDs = []string{"1.3.6.1.4.1.99999.2"} }), Expected: false},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) {
+			c.Extensions = []ExtensionConstraint{
+				{OID: "1.3.6.1.4.1.57264.1.3", Value: "refs/heads/main"},
+				{OID: "1.3.6.1.4.1.57264.1.8", Value: "https://token.actions.githubusercontent.com"},
+			}
+		}), Expected: true},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) {
+			c.Extensions = []ExtensionConstraint{{OID: "1.3.6.1.4.1.57264.1.8", Value: "*"}}
+		}), Expected: true},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) {
+			c.Extensions = []ExtensionConstraint{{OID: "1.3.6.1.4.1.57264.1.3", Value: "refs/heads/dev"}}
+		}), Expected: false},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) {
+			c.Extensions = []ExtensionConstraint{{OID: "1.3.6.1.4.1.57264.1.9", Value: "*"}}
+		}), Expected: false},
+		{Cert: testCert, Constraint: with(func(c *CertificateConstraint) {
+			c.Extensions = []ExtensionConstraint{{OID: "not-an-oid", Value: "*"}}
+		}), Expected: false},
 	}
 
 	for _, c := range cases {
@@ -278,6 +420,68 @@ func TestConstraintCheck(t *testing.T) {
 	}
 }
 
+func TestConstraintEvaluate(t *testing.T) {
+	testCertTemplate := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   "step1.example.com",
+			Organization: []string{"example"},
+		},
+		DNSNames: []string{"step1.example.com"},
+	}
+	testCert, testIntermediateCert, testRootCert, err := createTestCert(testCertTemplate, x509.Ed25519, 1*time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create test cert: %v", err)
+	}
+
+	rootCertPool := x509.NewCertPool()
+	rootCertPool.AddCert(testRootCert)
+	intermediateCertPool := x509.NewCertPool()
+	intermediateCertPool.AddCert(testIntermediateCert)
+	roots := []string{"example"}
+
+	constraint := CertificateConstraint{
+		CommonName:    "step2.example.com",
+		DNSNames:      []string{"glob:*.example.com"},
+		Emails:        []string{},
+		Organizations: []string{"example"},
+		Roots:         []string{"*"},
+		URIs:          []string{},
+		KeyUsages:     []string{"digital_signature"},
+	}
+
+	result := constraint.Evaluate(testCert, roots, rootCertPool, intermediateCertPool, time.Time{})
+	assert.False(t, result.Passed())
+	assert.Equal(t, constraint.Check(testCert, roots, rootCertPool, intermediateCertPool), result.Err())
+	assert.Empty(t, result.ChainError)
+
+	attributes := []string{}
+	failed := []string{}
+	for _, check := range result.Checks {
+		attributes = append(attributes, check.Attribute)
+		if check.Error != "" {
+			failed = append(failed, check.Attribute)
+		}
+	}
+	// optional attributes are only evaluated if the constraint sets them
+	assert.Equal(t, []string{"common name", "dns name", "email", "organization", "root", "uri", "key usage"}, attributes)
+	assert.Equal(t, []string{"common name"}, failed)
+	assert.Equal(t, AttributeCheck{
+		Attribute: "dns name",
+		Expected:  []string{"glob:*.example.com"},
+		Actual:    []string{"step1.example.com"},
+	}, result.Checks[1])
+
+	// chain building errors are reported along with the failed root check
+	result = constraint.Evaluate(testCert, roots, x509.NewCertPool(), intermediateCertPool, time.Time{})
+	assert.NotEmpty(t, result.ChainError)
+	assert.Contains(t, result.Checks[4].Error, result.ChainError)
+
+	constraint.CommonName = "step1.example.com"
+	result = constraint.Evaluate(testCert, roots, rootCertPool, intermediateCertPool, time.Time{})
+	assert.True(t, result.Passed())
+	assert.Nil(t, result.Err())
+}
+
 func createTestCert(template *x509.Certificate, publicKeyAlgorithm x509.PublicKeyAlgorithm, validity time.Duration) (*x509.Certificate, *x509.Certificate, *x509.Certificate, error) {
 	rootCertSubject := pkix.Name{
 		CommonName: "Root CA",
@@ -334,7 +538,7 @@ func createSelfSignedCA(template *x509.Certificate, publicKeyAlgorithm x509.Publ
 	template.SerialNumber = serialNumber
 	template.NotBefore = time.Now()
 	template.NotAfter = time.Now().Add(validity)
-	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
 	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
 	template.BasicConstraintsValid = true
 	template.IsCA = true
@@ -373,7 +577,7 @@ func createCA(template, issuerCert *x509.Certificate, issuerPrivateKey crypto.Pr
 	template.SerialNumber = serialNumber
 	template.NotBefore = time.Now()
 	template.NotAfter = time.Now().Add(validity)
-	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
 	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}
 	template.BasicConstraintsValid = true
 	template.IsCA = true
@@ -0,0 +1,263 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// commandInitEnv is the environment variable, which passes the
+	// commandInitConfig of a command to the command init process
+	commandInitEnv = "IN_TOTO_COMMAND_INIT"
+	// commandInitStatusFd is the file descriptor of the pipe, to which the
+	// command init process reports its status
+	commandInitStatusFd = 3
+)
+
+// commandInitRegistered is set by MaybeRunCommandInit, such that the command init
+// process runs when the current program is re-executed
+var commandInitRegistered atomic.Bool
+
+// Status codes written by the command init process before executing the command
+const (
+	commandInitReady byte = iota
+	commandInitSandboxFailed
+	commandInitLimitsFailed
+)
+
+/*
+commandInitConfig holds the command executed by the command init process, the
+resource limits it sets before, and the run directory of a sandbox, if the
+command runs in one.  ParentPID and UserNamespace identify the process that
+started the command init process and its user namespace.
+*/
+type commandInitConfig struct {
+	Path          string         `json:"path"`
+	Args          []string       `json:"args"`
+	Limits        ResourceLimits `json:"limits"`
+	Sandbox       bool           `json:"sandbox"`
+	RunDir        string         `json:"run_dir"`
+	ParentPID     int            `json:"parent_pid"`
+	UserNamespace uint64         `json:"user_namespace"`
+}
+
+/*
+commandInit holds the status pipe of a command configured by setupCommandInit.
+After the command started, the command init process reports on the pipe
+whether it set up the sandbox and resource limits, followed by the error of
+executing the command, if any.  Executing the command closes the pipe.
+*/
+type commandInit struct {
+	sandbox      bool
+	statusReader *os.File
+	statusWriter *os.File
+}
+
+/*
+setupCommandInit configures the passed command, which has not been started, to
+be run by the command init process, see MaybeRunCommandInit, which sets the
+resource limits and sets up the sandbox of the passed options before it
+executes the command.  The passed run directory is the working directory of the
+command.
+*/
+func setupCommandInit(cmd *exec.Cmd, runDir string, options CommandOptions) (*commandInit, error) {
+	if cmd.Err != nil {
+		return nil, cmd.Err
+	}
+	if !commandInitRegistered.Load() {
+		if options.Sandbox {
+			return nil, fmt.Errorf("%w: %w", ErrSandboxUnavailable, ErrCommandInitNotRegistered)
+		}
+		return nil, ErrCommandInitNotRegistered
+	}
+
+	userNamespace, err := userNamespaceID()
+	if err != nil {
+		return nil, err
+	}
+	config := commandInitConfig{
+		Path:          cmd.Path,
+		Args:          cmd.Args,
+		Limits:        options.Limits,
+		Sandbox:       options.Sandbox,
+		ParentPID:     os.Getpid(),
+		UserNamespace: userNamespace,
+	}
+	if options.Sandbox {
+		if config.RunDir, err = configureSandbox(cmd, runDir); err != nil {
+			return nil, err
+		}
+	}
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	statusReader, statusWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(append([]string{}, env...), commandInitEnv+"="+string(configBytes))
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = []string{"in-toto-command-init"}
+	cmd.ExtraFiles = []*os.File{statusWriter}
+
+	return &commandInit{sandbox: options.Sandbox, statusReader: statusReader, statusWriter: statusWriter}, nil
+}
+
+/*
+started must be called with the error of starting the command configured by
+setupCommandInit.  It waits until the command init process executed the
+command and returns an error if the sandbox or the resource limits could not be
+set up, which wraps ErrSandboxUnavailable for the sandbox, or the error of
+executing the command.
+*/
+func (c *commandInit) started(startErr error) error {
+	c.statusWriter.Close()
+	defer c.statusReader.Close()
+
+	if startErr != nil {
+		if c.sandbox {
+			return fmt.Errorf("%w: failed to create namespaces: %s", ErrSandboxUnavailable, startErr)
+		}
+		return startErr
+	}
+
+	status, err := io.ReadAll(c.statusReader)
+	if err != nil {
+		return err
+	}
+	if len(status) == 0 {
+		return errors.New("the command init process did not run")
+	}
+	switch status[0] {
+	case commandInitReady:
+		if len(status) > 1 {
+			return errors.New(string(status[1:]))
+		}
+		return nil
+	case commandInitSandboxFailed:
+		return fmt.Errorf("%w: %s", ErrSandboxUnavailable, status[1:])
+	default:
+		return fmt.Errorf("failed to set command resource limits: %s", status[1:])
+	}
+}
+
+/*
+MaybeRunCommandInit runs the command init process, if the current process was
+started as such for a command run with resource limits or in a sandbox by
+RunCommandWithOptions, and returns otherwise.  The command init process is run
+by re-executing the current program, which must therefore call
+MaybeRunCommandInit at the start of main, before any other work, to run such
+commands.  Otherwise RunCommandWithOptions returns an
+ErrCommandInitNotRegistered for them, which also wraps ErrSandboxUnavailable
+for sandboxes.  The current process is only considered a command init process
+if the status pipe is open, and it was started by the process and in the user
+namespace recorded in its config, or in a new user namespace for a sandbox.
+
+As the sandbox is set up by the current program, the init functions of its
+packages run in the command init process before the sandbox is set up, and
+must not start any work.
+
+The command init process sets up the sandbox, see setupSandboxMounts, and then
+drops its capabilities and sets the resource limits right before it executes
+the command, such that the command never runs without them.
+*/
+func MaybeRunCommandInit() {
+	commandInitRegistered.Store(true)
+
+	configString, ok := os.LookupEnv(commandInitEnv)
+	if !ok {
+		return
+	}
+	var config commandInitConfig
+	if err := json.Unmarshal([]byte(configString), &config); err != nil || !isCommandInit(config) {
+		os.Unsetenv(commandInitEnv)
+		return
+	}
+
+	// Capabilities and no_new_privs are attributes of the thread, which
+	// executes the command
+	runtime.LockOSThread()
+
+	status := os.NewFile(commandInitStatusFd, "command-init-status")
+	unix.CloseOnExec(commandInitStatusFd)
+	fail := func(code byte, err error) {
+		_, _ = status.Write(append([]byte{code}, err.Error()...))
+		os.Exit(1)
+	}
+
+	env := []string{}
+	if config.Sandbox {
+		if err := setupSandboxMounts(config.RunDir); err != nil {
+			fail(commandInitSandboxFailed, err)
+		}
+		if err := dropCapabilities(); err != nil {
+			fail(commandInitSandboxFailed, err)
+		}
+		env = append(env, "TMPDIR="+SandboxScratchDir)
+	}
+	for _, entry := range os.Environ() {
+		if strings.HasPrefix(entry, commandInitEnv+"=") || (config.Sandbox && strings.HasPrefix(entry, "TMPDIR=")) {
+			continue
+		}
+		env = append(env, entry)
+	}
+
+	if err := setResourceLimits(config.Limits); err != nil {
+		fail(commandInitLimitsFailed, err)
+	}
+	if _, err := status.Write([]byte{commandInitReady}); err != nil {
+		os.Exit(1)
+	}
+
+	// only returns if the command cannot be executed
+	err := syscall.Exec(config.Path, config.Args, env)
+	fmt.Fprintf(status, "failed to execute %s: %s", config.Path, err)
+	os.Exit(1)
+}
+
+/*
+isCommandInit returns true if the current process was started as command init
+process with the passed config, i.e. the status pipe is open and the current
+process was started by the recorded parent, in the recorded user namespace,
+or in a new one for a sandbox.
+*/
+func isCommandInit(config commandInitConfig) bool {
+	var stat unix.Stat_t
+	if err := unix.Fstat(commandInitStatusFd, &stat); err != nil || stat.Mode&unix.S_IFMT != unix.S_IFIFO {
+		return false
+	}
+	if config.ParentPID != os.Getppid() {
+		return false
+	}
+	userNamespace, err := userNamespaceID()
+	if err != nil {
+		return false
+	}
+	return (userNamespace != config.UserNamespace) == config.Sandbox
+}
+
+// userNamespaceID returns the inode number identifying the user namespace of the current process.
+func userNamespaceID() (uint64, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat("/proc/self/ns/user", &stat); err != nil {
+		return 0, fmt.Errorf("failed to identify user namespace: %w", err)
+	}
+	return stat.Ino, nil
+}
@@ -0,0 +1,25 @@
+package in_toto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandInitNotRegistered(t *testing.T) {
+	commandInitRegistered.Store(false)
+	defer commandInitRegistered.Store(true)
+
+	_, err := RunCommandWithOptions([]string{"true"}, "", CommandOptions{Limits: ResourceLimits{OpenFiles: 64}})
+	assert.ErrorIs(t, err, ErrCommandInitNotRegistered)
+
+	_, err = RunCommandWithOptions([]string{"true"}, "", CommandOptions{Sandbox: true})
+	assert.ErrorIs(t, err, ErrCommandInitNotRegistered)
+	assert.ErrorIs(t, err, ErrSandboxUnavailable)
+
+	// Commands without limits do not need the command init process
+	result, err := RunCommandWithOptions([]string{"true"}, "", CommandOptions{})
+	if assert.Nil(t, err) {
+		assert.Equal(t, float64(0), result["return-value"])
+	}
+}
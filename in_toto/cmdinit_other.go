@@ -0,0 +1,31 @@
+//go:build !linux
+// +build !linux
+
+package in_toto
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// commandInit is not supported on the current platform.
+type commandInit struct{}
+
+/*
+setupCommandInit is not supported on the current platform, because resource
+limits and sandboxes require Linux.
+*/
+func setupCommandInit(cmd *exec.Cmd, runDir string, options CommandOptions) (*commandInit, error) {
+	if options.Sandbox {
+		return nil, fmt.Errorf("%w: sandboxes require Linux namespaces", ErrSandboxUnavailable)
+	}
+	return nil, ErrResourceLimitsUnsupported
+}
+
+// started is not supported on the current platform.
+func (c *commandInit) started(startErr error) error {
+	return startErr
+}
+
+// MaybeRunCommandInit returns immediately, because the command init process is only supported on Linux.
+func MaybeRunCommandInit() {}
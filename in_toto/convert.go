@@ -0,0 +1,95 @@
+package in_toto
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// MetadataFormatDSSE identifies metadata wrapped in a DSSE envelope.
+	MetadataFormatDSSE = "dsse"
+	// MetadataFormatMetablock identifies metadata wrapped in a legacy Metablock.
+	MetadataFormatMetablock = "metablock"
+)
+
+// ErrUnknownMetadataFormat indicates that an unsupported metadata format was requested
+var ErrUnknownMetadataFormat = errors.New("unknown metadata format")
+
+/*
+ErrSignaturesNotConvertible indicates that signed metadata would lose its
+signatures during conversion.  DSSE signatures are created over the pre-auth
+encoding of the payload, Metablock signatures over the canonical JSON of the
+signed object, thus no signature remains valid in the other format.
+*/
+var ErrSignaturesNotConvertible = errors.New("signatures cannot be carried over to the target metadata format")
+
+// MetadataFormat returns the format of the passed metadata.
+func MetadataFormat(metadata Metadata) (string, error) {
+	switch metadata.(type) {
+	case *Envelope:
+		return MetadataFormatDSSE, nil
+	case *Metablock:
+		return MetadataFormatMetablock, nil
+	}
+	return "", fmt.Errorf("%w: %T", ErrUnknownMetadataFormat, metadata)
+}
+
+/*
+ConvertMetadata re-wraps the Link or Layout of the passed metadata in the
+passed target format, i.e. MetadataFormatDSSE or MetadataFormatMetablock, and
+signs the result with each of the passed keys.
+
+If the metadata already is in the target format, its signatures are kept.
+Otherwise, signatures cannot be carried over and ConvertMetadata returns an
+ErrSignaturesNotConvertible for signed metadata, unless dropSignatures is set
+or keys to re-sign the metadata are passed.
+*/
+func ConvertMetadata(metadata Metadata, format string, keys []Key, dropSignatures bool) (Metadata, error) {
+	sourceFormat, err := MetadataFormat(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var converted Metadata
+	switch {
+	case format == sourceFormat:
+		// Merging a single metadata object yields a copy of it
+		converted, err = MergeSignatures(metadata)
+		if err != nil {
+			return nil, err
+		}
+
+	case format == MetadataFormatDSSE || format == MetadataFormatMetablock:
+		if len(metadata.Sigs()) > 0 && len(keys) == 0 && !dropSignatures {
+			return nil, ErrSignaturesNotConvertible
+		}
+
+		payload := metadata.GetPayload()
+		switch payload.(type) {
+		case Link, Layout:
+		default:
+			return nil, fmt.Errorf("%w: %T", ErrUnknownMetadataType, payload)
+		}
+
+		if format == MetadataFormatDSSE {
+			env := &Envelope{}
+			if err := env.SetPayload(payload); err != nil {
+				return nil, err
+			}
+			converted = env
+		} else {
+			converted = &Metablock{Signed: payload, Signatures: []Signature{}}
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMetadataFormat, format)
+	}
+
+	for _, key := range keys {
+		if err := converted.Sign(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return converted, nil
+}
@@ -0,0 +1,83 @@
+package in_toto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertMetadata(t *testing.T) {
+	var carol, dan Key
+	if err := carol.LoadKeyDefaults("carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dan.LoadKeyDefaults("dan"); err != nil {
+		t.Fatal(err)
+	}
+
+	link := Link{Type: "link", Name: "convert", Materials: map[string]HashObj{}, Products: map[string]HashObj{}}
+
+	mb := &Metablock{Signed: link}
+	if err := mb.Sign(carol); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("metablock to dsse with re-signing", func(t *testing.T) {
+		converted, err := ConvertMetadata(mb, MetadataFormatDSSE, []Key{dan}, false)
+		if !assert.Nil(t, err) {
+			return
+		}
+		env, ok := converted.(*Envelope)
+		assert.True(t, ok, "converted metadata must be envelope")
+		assert.Equal(t, link, env.GetPayload())
+		assert.Len(t, env.Sigs(), 1)
+		assert.Nil(t, env.VerifySignature(dan))
+		assert.NotNil(t, env.VerifySignature(carol))
+	})
+
+	t.Run("dsse to metablock", func(t *testing.T) {
+		env := &Envelope{}
+		assert.Nil(t, env.SetPayload(link))
+		assert.Nil(t, env.Sign(dan))
+
+		converted, err := ConvertMetadata(env, MetadataFormatMetablock, []Key{carol}, false)
+		if !assert.Nil(t, err) {
+			return
+		}
+		_, ok := converted.(*Metablock)
+		assert.True(t, ok, "converted metadata must be metablock")
+		assert.Equal(t, link, converted.GetPayload())
+		assert.Len(t, converted.Sigs(), 1)
+		assert.Nil(t, converted.VerifySignature(carol))
+	})
+
+	t.Run("same format keeps signatures", func(t *testing.T) {
+		converted, err := ConvertMetadata(mb, MetadataFormatMetablock, []Key{dan}, false)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Len(t, converted.Sigs(), 2)
+		assert.Nil(t, converted.VerifySignature(carol))
+		assert.Nil(t, converted.VerifySignature(dan))
+		assert.Len(t, mb.Sigs(), 1)
+	})
+
+	t.Run("signatures not convertible", func(t *testing.T) {
+		_, err := ConvertMetadata(mb, MetadataFormatDSSE, nil, false)
+		assert.ErrorIs(t, err, ErrSignaturesNotConvertible)
+
+		converted, err := ConvertMetadata(mb, MetadataFormatDSSE, nil, true)
+		assert.Nil(t, err)
+		assert.Len(t, converted.Sigs(), 0)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := ConvertMetadata(mb, "jws", nil, false)
+		assert.ErrorIs(t, err, ErrUnknownMetadataFormat)
+	})
+
+	t.Run("unknown payload", func(t *testing.T) {
+		_, err := ConvertMetadata(&Metablock{Signed: "not a link"}, MetadataFormatDSSE, nil, false)
+		assert.ErrorIs(t, err, ErrUnknownMetadataType)
+	})
+}
@@ -1,12 +1,14 @@
 package in_toto
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/secure-systems-lab/go-securesystemslib/cjson"
 	"github.com/secure-systems-lab/go-securesystemslib/dsse"
@@ -19,13 +21,113 @@ const PayloadType = "application/vnd.in-toto+json"
 // ErrInvalidPayloadType indicates that the envelope used an unknown payload type
 var ErrInvalidPayloadType = errors.New("unknown payload type")
 
+/*
+X509ChainExtensionKind is the kind of the DSSE signature extension, which
+carries the PEM encoded certificate of the signer and optional intermediate
+certificates, and optionally the base64 encoded RFC 3161 timestamp token of
+the signature, e.g.:
+
+	"extension": {
+	  "kind": "x509-certificate-chain",
+	  "ext": {
+	    "certificate": "-----BEGIN CERTIFICATE-----...",
+	    "intermediates": ["-----BEGIN CERTIFICATE-----..."],
+	    "timestamp": "MIIE..."
+	  }
+	}
+*/
+const X509ChainExtensionKind = "x509-certificate-chain"
+
 type Envelope struct {
 	envelope *dsse.Envelope
 	payload  any
+	// extensions maps the signature data of a DSSE signature to its extension
+	extensions map[string]*signatureExtension
+}
+
+// signatureExtension is the extension field of a DSSE signature.
+type signatureExtension struct {
+	Kind string          `json:"kind"`
+	Ext  json.RawMessage `json:"ext"`
+}
+
+// x509ChainExtension is the content of an X509ChainExtensionKind extension.
+type x509ChainExtension struct {
+	Certificate   string   `json:"certificate"`
+	Intermediates []string `json:"intermediates,omitempty"`
+	Timestamp     string   `json:"timestamp,omitempty"`
+}
+
+// envelopeSignature is a DSSE signature including its extension.
+type envelopeSignature struct {
+	KeyID     string              `json:"keyid"`
+	Sig       string              `json:"sig"`
+	Extension *signatureExtension `json:"extension,omitempty"`
+}
+
+// envelopeWithExtensions is the JSON representation of a DSSE envelope,
+// whose signatures may carry extensions.
+type envelopeWithExtensions struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     string              `json:"payload"`
+	Signatures  []envelopeSignature `json:"signatures"`
+}
+
+/*
+newX509ChainExtension creates an X509ChainExtensionKind signature extension
+from the passed PEM encoded certificate chain.
+*/
+func newX509ChainExtension(chain string) (*signatureExtension, error) {
+	certs, err := splitCertificateChain(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	ext, err := json.Marshal(x509ChainExtension{
+		Certificate:   certs[0],
+		Intermediates: certs[1:],
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &signatureExtension{Kind: X509ChainExtensionKind, Ext: ext}, nil
+}
+
+/*
+certificateChain returns the PEM encoded certificate chain carried by an
+X509ChainExtensionKind signature extension.  It returns an empty string for
+other extension kinds or invalid extensions.
+*/
+func (ext *signatureExtension) certificateChain() string {
+	chain, ok := ext.x509Chain()
+	if !ok {
+		return ""
+	}
+	return chain.Certificate + strings.Join(chain.Intermediates, "")
+}
+
+// timestamp returns the timestamp token carried by an X509ChainExtensionKind signature extension.
+func (ext *signatureExtension) timestamp() string {
+	chain, _ := ext.x509Chain()
+	return chain.Timestamp
+}
+
+// x509Chain decodes an X509ChainExtensionKind signature extension.
+func (ext *signatureExtension) x509Chain() (x509ChainExtension, bool) {
+	if ext == nil || ext.Kind != X509ChainExtensionKind {
+		return x509ChainExtension{}, false
+	}
+
+	var chain x509ChainExtension
+	if err := json.Unmarshal(ext.Ext, &chain); err != nil {
+		return x509ChainExtension{}, false
+	}
+	return chain, true
 }
 
 func loadEnvelope(env *dsse.Envelope) (*Envelope, error) {
-	e := &Envelope{envelope: env}
+	e := &Envelope{envelope: env, extensions: map[string]*signatureExtension{}}
 
 	contentBytes, err := env.DecodeB64Payload()
 	if err != nil {
@@ -41,6 +143,24 @@ func loadEnvelope(env *dsse.Envelope) (*Envelope, error) {
 	return e, nil
 }
 
+/*
+loadExtensions loads the extensions of the signatures in the passed JSON
+representation of the envelope, which are not part of dsse.Envelope.
+*/
+func (e *Envelope) loadExtensions(jsonBytes []byte) error {
+	env := envelopeWithExtensions{}
+	if err := json.Unmarshal(jsonBytes, &env); err != nil {
+		return err
+	}
+
+	for _, s := range env.Signatures {
+		if s.Extension != nil {
+			e.extensions[s.Sig] = s.Extension
+		}
+	}
+	return nil
+}
+
 func (e *Envelope) SetPayload(payload any) error {
 	encodedBytes, err := cjson.EncodeCanonical(payload)
 	if err != nil {
@@ -52,6 +172,7 @@ func (e *Envelope) SetPayload(payload any) error {
 		Payload:     base64.StdEncoding.EncodeToString(encodedBytes),
 		PayloadType: PayloadType,
 	}
+	e.extensions = map[string]*signatureExtension{}
 
 	return nil
 }
@@ -75,6 +196,12 @@ func (e *Envelope) VerifySignature(key Key) error {
 	return err
 }
 
+/*
+Sign signs the envelope payload with the passed Key and appends the resulting
+signature to any signatures already present in the envelope.  If the Key has a
+certificate, the certificate and its intermediates are attached to the
+signature using an X509ChainExtensionKind extension.
+*/
 func (e *Envelope) Sign(key Key) error {
 	signer, err := getSignerVerifierFromKey(key)
 	if err != nil {
@@ -96,21 +223,76 @@ func (e *Envelope) Sign(key Key) error {
 		return err
 	}
 
+	if key.KeyVal.Certificate != "" {
+		ext, err := newX509ChainExtension(key.KeyVal.Certificate)
+		if err != nil {
+			return err
+		}
+		if e.extensions == nil {
+			e.extensions = map[string]*signatureExtension{}
+		}
+		for _, s := range env.Signatures {
+			e.extensions[s.Sig] = ext
+		}
+	}
+
+	// Keep existing signatures, so that envelopes can be signed by multiple keys
+	env.Signatures = append(append([]dsse.Signature{}, e.envelope.Signatures...), env.Signatures...)
 	e.envelope = env
 	return nil
 }
 
+/*
+Sigs returns the signatures of the envelope.  The Certificate and Timestamp
+fields of a signature are populated from its X509ChainExtensionKind extension,
+if present.
+*/
 func (e *Envelope) Sigs() []Signature {
 	sigs := []Signature{}
 	for _, s := range e.envelope.Signatures {
 		sigs = append(sigs, Signature{
-			KeyID: s.KeyID,
-			Sig:   s.Sig,
+			KeyID:       s.KeyID,
+			Sig:         s.Sig,
+			Certificate: e.extensions[s.Sig].certificateChain(),
+			Timestamp:   e.extensions[s.Sig].timestamp(),
 		})
 	}
 	return sigs
 }
 
+/*
+AddTimestamp requests a timestamp token for the signature of the passed key ID
+from the passed Timestamper and attaches it to the X509ChainExtensionKind
+extension of the signature.  Only signatures with certificates can carry
+timestamps.
+*/
+func (e *Envelope) AddTimestamp(keyID string, timestamper Timestamper) error {
+	for _, s := range e.envelope.Signatures {
+		if s.KeyID != keyID {
+			continue
+		}
+
+		chain, ok := e.extensions[s.Sig].x509Chain()
+		if !ok {
+			return fmt.Errorf("signature of key '%s' has no certificate to timestamp", keyID)
+		}
+		token, err := timestamper([]byte(s.Sig))
+		if err != nil {
+			return err
+		}
+		chain.Timestamp = base64.StdEncoding.EncodeToString(token)
+
+		ext, err := json.Marshal(chain)
+		if err != nil {
+			return err
+		}
+		e.extensions[s.Sig] = &signatureExtension{Kind: X509ChainExtensionKind, Ext: ext}
+		return nil
+	}
+
+	return fmt.Errorf("no signature found for key '%s'", keyID)
+}
+
 func (e *Envelope) GetSignatureForKeyID(keyID string) (Signature, error) {
 	for _, s := range e.Sigs() {
 		if s.KeyID == keyID {
@@ -121,8 +303,28 @@ func (e *Envelope) GetSignatureForKeyID(keyID string) (Signature, error) {
 	return Signature{}, fmt.Errorf("no signature found for key '%s'", keyID)
 }
 
+/*
+withExtensions returns the serializable representation of the envelope, which
+includes the extensions of its signatures.
+*/
+func (e *Envelope) withExtensions() envelopeWithExtensions {
+	env := envelopeWithExtensions{
+		PayloadType: e.envelope.PayloadType,
+		Payload:     e.envelope.Payload,
+		Signatures:  []envelopeSignature{},
+	}
+	for _, s := range e.envelope.Signatures {
+		env.Signatures = append(env.Signatures, envelopeSignature{
+			KeyID:     s.KeyID,
+			Sig:       s.Sig,
+			Extension: e.extensions[s.Sig],
+		})
+	}
+	return env
+}
+
 func (e *Envelope) Dump(path string) error {
-	jsonBytes, err := json.MarshalIndent(e.envelope, "", "  ")
+	jsonBytes, err := json.MarshalIndent(e.withExtensions(), "", "  ")
 	if err != nil {
 		return err
 	}
@@ -136,11 +338,58 @@ func (e *Envelope) Dump(path string) error {
 	return nil
 }
 
+/*
+mergeEnvelopes returns a new Envelope that contains the payload of the first
+passed envelope and the signatures of all passed envelopes.  It returns an
+ErrPayloadMismatch if the payload types or payloads of the envelopes differ.
+*/
+func mergeEnvelopes(envs []*Envelope) (*Envelope, error) {
+	first := envs[0].envelope
+	firstPayload, err := first.DecodeB64Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &Envelope{
+		envelope: &dsse.Envelope{
+			PayloadType: first.PayloadType,
+			Payload:     first.Payload,
+			Signatures:  []dsse.Signature{},
+		},
+		payload:    envs[0].payload,
+		extensions: map[string]*signatureExtension{},
+	}
+	for _, env := range envs {
+		payload, err := env.envelope.DecodeB64Payload()
+		if err != nil {
+			return nil, err
+		}
+		if env.envelope.PayloadType != first.PayloadType || !bytes.Equal(payload, firstPayload) {
+			return nil, ErrPayloadMismatch
+		}
+
+		for _, s := range env.envelope.Signatures {
+			if !containsSignature(merged.Sigs(), Signature{KeyID: s.KeyID, Sig: s.Sig}) {
+				merged.envelope.Signatures = append(merged.envelope.Signatures, s)
+				if ext, ok := env.extensions[s.Sig]; ok {
+					merged.extensions[s.Sig] = ext
+				}
+			}
+		}
+	}
+
+	return merged, nil
+}
+
 func getSignerVerifierFromKey(key Key) (dsse.SignerVerifier, error) {
 	sslibKey := getSSLibKeyFromKey(key)
 
 	switch sslibKey.KeyType {
 	case signerverifier.RSAKeyType:
+		// The securesystemslib only implements the default RSA scheme
+		if sslibKey.Scheme != signerverifier.RSAKeyScheme {
+			return newRSASignerVerifierFromKey(key)
+		}
 		return signerverifier.NewRSAPSSSignerVerifierFromSSLibKey(&sslibKey)
 	case signerverifier.ED25519KeyType:
 		return signerverifier.NewED25519SignerVerifierFromSSLibKey(&sslibKey)
@@ -223,6 +223,22 @@ func TestEnvelopeSign(t *testing.T) {
 		assert.Equal(t, "HeacKZDQD+EIYz1dLJ2NpXxcG70tn62BOzcxnAArFSKJcWIL0qcyzvdtpSJQ0pOyq8lBxMk5nIRO0Kr89SZoBA==", env.envelope.Signatures[0].Sig)
 	})
 
+	t.Run("append signature", func(t *testing.T) {
+		var rsaKey Key
+		if err := rsaKey.LoadKeyDefaults("dan"); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Sign(rsaKey); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, env.envelope.Signatures, 2)
+		assert.Equal(t, "be6371bc627318218191ce0780fd3183cce6c36da02938a477d2e4dfae1804a6", env.envelope.Signatures[0].KeyID)
+		assert.Equal(t, rsaKey.KeyID, env.envelope.Signatures[1].KeyID)
+		assert.Nil(t, env.VerifySignature(key))
+		assert.Nil(t, env.VerifySignature(rsaKey))
+	})
+
 	t.Run("invalid key", func(t *testing.T) {
 		key := Key{
 			KeyID:   "invalid",
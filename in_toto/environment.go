@@ -0,0 +1,285 @@
+package in_toto
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// ErrEnvironmentMismatch indicates that the environment of a link does not meet the expectations of its step
+var ErrEnvironmentMismatch = errors.New("link environment does not meet expectations")
+
+// RedactedValue replaces the values of recorded environment variables that may hold secrets
+const RedactedValue = "[REDACTED]"
+
+/*
+DefaultRedactPatterns are the patterns of names of environment variables,
+whose values are always redacted when they are recorded.
+*/
+var DefaultRedactPatterns = []string{
+	"*TOKEN*",
+	"*SECRET*",
+	"*PASSWORD*",
+	"*PASSWD*",
+	"*CREDENTIAL*",
+	"*PRIVATE*",
+	"*KEY*",
+	"*AUTH*",
+}
+
+/*
+EnvironmentOptions configures which parts of the environment a step runs in
+are recorded in the Environment of its link, see CaptureEnvironment.
+
+Variables are names or glob patterns, with the syntax of path.Match, of
+environment variables whose values are recorded.  The values of variables
+whose names match RedactPatterns or DefaultRedactPatterns are replaced by
+RedactedValue.  Names are matched case-insensitively.  Hostname records the
+hostname, Platform the operating system, architecture and Go runtime version,
+and the trimmed standard output of Commands is recorded by command, e.g. to
+record tool versions.  Commands are split into arguments at white space and
+are not run in a shell.
+*/
+type EnvironmentOptions struct {
+	Variables      []string
+	RedactPatterns []string
+	Hostname       bool
+	Platform       bool
+	Commands       []string
+}
+
+/*
+CaptureEnvironment returns the parts of the current environment selected by the
+passed options, in the format of the Environment of a Link:
+
+	{
+		"variables": {<name>: <value>, ...},
+		"hostname": <hostname>,
+		"os": <GOOS>,
+		"arch": <GOARCH>,
+		"go_version": <Go runtime version>,
+		"commands": {<command>: <output>, ...}
+	}
+
+Parts that are not selected are omitted, such that the default options result
+in an empty environment.  If a command fails, the first return value is nil
+and the second return value is the error.
+*/
+func CaptureEnvironment(options EnvironmentOptions) (map[string]interface{}, error) {
+	environment := map[string]interface{}{}
+
+	if len(options.Variables) != 0 {
+		redactPatterns := append(append([]string{}, DefaultRedactPatterns...), options.RedactPatterns...)
+		variables := map[string]interface{}{}
+		for _, entry := range os.Environ() {
+			name, value, _ := strings.Cut(entry, "=")
+			selected, err := matchVariableName(options.Variables, name)
+			if err != nil {
+				return nil, err
+			}
+			if !selected {
+				continue
+			}
+			redacted, err := matchVariableName(redactPatterns, name)
+			if err != nil {
+				return nil, err
+			}
+			if redacted {
+				value = RedactedValue
+			}
+			variables[name] = value
+		}
+		environment["variables"] = variables
+	}
+
+	if options.Hostname {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		environment["hostname"] = hostname
+	}
+
+	if options.Platform {
+		environment["os"] = runtime.GOOS
+		environment["arch"] = runtime.GOARCH
+		environment["go_version"] = runtime.Version()
+	}
+
+	if len(options.Commands) != 0 {
+		commands := map[string]interface{}{}
+		for _, command := range options.Commands {
+			args := strings.Fields(command)
+			if len(args) == 0 {
+				return nil, ErrEmptyCommandArgs
+			}
+			output, err := exec.Command(args[0], args[1:]...).Output()
+			if err != nil {
+				return nil, fmt.Errorf("failed to run environment command '%s': %w", command, err)
+			}
+			commands[command] = strings.TrimSpace(string(output))
+		}
+		environment["commands"] = commands
+	}
+
+	return environment, nil
+}
+
+// matchVariableName returns true if one of the passed patterns matches the
+// environment variable name, ignoring case.
+func matchVariableName(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := path.Match(strings.ToUpper(pattern), strings.ToUpper(name))
+		if err != nil {
+			return false, fmt.Errorf("invalid environment variable pattern '%s': %w", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+/*
+VerifyLinkEnvironments verifies the environments recorded in the links of the
+passed steps metadata against the ExpectedEnvironment of the steps of the
+passed layout.  Links whose environment does not meet the expectations are
+dropped, and if fewer than Threshold links remain for a step, the first return
+value is nil and the second return value is the error.  Metadata that is not a
+link, e.g. of sublayouts, is not checked.  The returned map of link metadata
+per steps has the format of the passed one.
+*/
+func VerifyLinkEnvironments(layout Layout, stepsMetadata map[string]map[string]Metadata) (
+	map[string]map[string]Metadata, error) {
+	stepsMetadataVerified := make(map[string]map[string]Metadata)
+	for _, step := range layout.Steps {
+		if len(step.ExpectedEnvironment) == 0 {
+			stepsMetadataVerified[step.Name] = stepsMetadata[step.Name]
+			continue
+		}
+
+		var err error
+		stepsMetadataVerified[step.Name], err = filterStepLinks(step, stepsMetadata[step.Name],
+			"environment expectations", func(link Link) error {
+				return verifyLinkEnvironment(link, step.ExpectedEnvironment)
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stepsMetadataVerified, nil
+}
+
+/*
+verifyLinkEnvironment verifies that the environment of the passed link has a
+value matching each of the passed expectations, in sorted order of their keys.
+*/
+func verifyLinkEnvironment(link Link, expectations map[string]string) error {
+	keys := make([]string, 0, len(expectations))
+	for key := range expectations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, ok := lookupEnvironmentValue(link.Environment, key)
+		if !ok {
+			return fmt.Errorf("%w: no value for '%s'", ErrEnvironmentMismatch, key)
+		}
+		matched, err := matchEnvironmentValue(expectations[key], value)
+		if err != nil {
+			return fmt.Errorf("invalid environment expectation for '%s': %w", key, err)
+		}
+		if !matched {
+			return fmt.Errorf("%w: '%s' is '%s', expected '%s'", ErrEnvironmentMismatch,
+				key, value, expectations[key])
+		}
+	}
+	return nil
+}
+
+// matchEnvironmentValue matches a value against an expectation, which is either a
+// regular expression with the regex prefix or a glob pattern, see matchValueGlob.
+func matchEnvironmentValue(expectation, value string) (bool, error) {
+	if expression, ok := strings.CutPrefix(expectation, RegexConstraintPrefix); ok {
+		return matchRegex(expression, value)
+	}
+	return matchValueGlob(expectation, value)
+}
+
+/*
+matchValueGlob matches a value against a glob pattern with the syntax of
+path.Match, except that `*` matches any sequence of characters, including `/`,
+as environment values are no paths, e.g. `refs/heads/*` matches
+`refs/heads/feature/x`.
+*/
+func matchValueGlob(pattern, value string) (bool, error) {
+	var expression strings.Builder
+	chars := []rune(pattern)
+	for i := 0; i < len(chars); i++ {
+		switch chars[i] {
+		case '*':
+			expression.WriteString("(?s:.*)")
+		case '?':
+			expression.WriteString("(?s:.)")
+		case '\\':
+			i++
+			if i == len(chars) {
+				return false, path.ErrBadPattern
+			}
+			expression.WriteString(regexp.QuoteMeta(string(chars[i])))
+		case '[':
+			end := i + 1
+			if end < len(chars) && chars[end] == '^' {
+				end++
+			}
+			for end < len(chars) && chars[end] != ']' {
+				if chars[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(chars) || end == i+1 || (chars[i+1] == '^' && end == i+2) {
+				return false, path.ErrBadPattern
+			}
+			expression.WriteString(string(chars[i : end+1]))
+			i = end
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(chars[i])))
+		}
+	}
+	matched, err := matchRegex(expression.String(), value)
+	if err != nil {
+		return false, path.ErrBadPattern
+	}
+	return matched, nil
+}
+
+/*
+lookupEnvironmentValue returns the string value of the passed key in the
+passed link environment.  Keys of values nested in the environment, such as
+variables and command outputs, consist of the key of the nesting value, a dot
+and the nested key, e.g. "variables.CI" or "commands.go version".
+*/
+func lookupEnvironmentValue(environment map[string]interface{}, key string) (string, bool) {
+	if value, ok := environment[key].(string); ok {
+		return value, true
+	}
+
+	outer, inner, ok := strings.Cut(key, ".")
+	if !ok {
+		return "", false
+	}
+	nested, ok := environment[outer].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	value, ok := nested[inner].(string)
+	return value, ok
+}
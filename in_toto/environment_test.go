@@ -0,0 +1,161 @@
+package in_toto
+
+import (
+	"path"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureEnvironment(t *testing.T) {
+	t.Setenv("INTOTO_TEST_CI", "true")
+	t.Setenv("INTOTO_TEST_API_TOKEN", "secret")
+	t.Setenv("INTOTO_TEST_INTERNAL", "internal")
+	t.Setenv("INTOTO_OTHER", "other")
+
+	environment, err := CaptureEnvironment(EnvironmentOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{}, environment)
+
+	environment, err = CaptureEnvironment(EnvironmentOptions{
+		Variables:      []string{"intoto_test_*"},
+		RedactPatterns: []string{"*INTERNAL"},
+		Platform:       true,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"variables": map[string]interface{}{
+			"INTOTO_TEST_CI":        "true",
+			"INTOTO_TEST_API_TOKEN": RedactedValue,
+			"INTOTO_TEST_INTERNAL":  RedactedValue,
+		},
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+		"go_version": runtime.Version(),
+	}, environment)
+
+	_, err = CaptureEnvironment(EnvironmentOptions{Variables: []string{"["}})
+	assert.NotNil(t, err, "expected error for invalid pattern")
+
+	if !testOSisWindows() {
+		environment, err = CaptureEnvironment(EnvironmentOptions{Commands: []string{"echo  hello  world"}})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"commands": map[string]interface{}{"echo  hello  world": "hello world"}}, environment)
+	}
+
+	_, err = CaptureEnvironment(EnvironmentOptions{Commands: []string{"in-toto-does-not-exist"}})
+	assert.NotNil(t, err, "expected error for failing command")
+}
+
+// TestLinkEnvironmentSignature ensures that signatures over captured
+// environments survive writing and reading links
+func TestLinkEnvironmentSignature(t *testing.T) {
+	var key Key
+	if err := key.LoadKey("carol", "ed25519", []string{"sha256", "sha512"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("INTOTO_TEST_CI", "true")
+	for _, useDSSE := range []bool{false, true} {
+		metadata, err := InTotoRunWithOptions("env", "", nil, nil, nil, key, []string{"sha256"}, nil, nil, RunOptions{
+			UseDSSE:     useDSSE,
+			Environment: EnvironmentOptions{Variables: []string{"INTOTO_TEST_CI"}, Platform: true},
+		})
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		path := filepath.Join(t.TempDir(), "env.link")
+		assert.Nil(t, metadata.Dump(path))
+		loaded, err := LoadMetadata(path)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		assert.Nil(t, loaded.VerifySignature(key))
+		assert.Equal(t, metadata.GetPayload().(Link).Environment, loaded.GetPayload().(Link).Environment)
+	}
+}
+
+func TestVerifyLinkEnvironments(t *testing.T) {
+	linkWith := func(environment map[string]interface{}) Metadata {
+		return &Metablock{Signed: Link{Type: "link", Name: "build", Environment: environment}}
+	}
+	linux := linkWith(map[string]interface{}{
+		"os":        "linux",
+		"variables": map[string]interface{}{"CI": "true", "GITHUB_REF": "refs/heads/feature/x", "GITHUB_REPOSITORY": "in-toto/in-toto-golang"},
+		"commands":  map[string]interface{}{"go version": "go version go1.24.1 linux/amd64"},
+	})
+	windows := linkWith(map[string]interface{}{
+		"os":        "windows",
+		"variables": map[string]interface{}{"CI": "true"},
+	})
+
+	tables := []struct {
+		name        string
+		expected    map[string]string
+		links       map[string]Metadata
+		threshold   int
+		expectedErr error
+		verified    int
+	}{
+		{"no expectations", nil, map[string]Metadata{"a": linux, "b": windows}, 2, nil, 2},
+		{"exact values", map[string]string{"os": "linux", "variables.CI": "true"}, map[string]Metadata{"a": linux}, 1, nil, 1},
+		{"patterns", map[string]string{"variables.GITHUB_REF": "refs/heads/*", "commands.go version": "regex:go version go1\\.24\\..*"}, map[string]Metadata{"a": linux}, 1, nil, 1},
+		{"patterns with slashes", map[string]string{"variables.GITHUB_REPOSITORY": "in-toto/*", "variables.GITHUB_REF": "refs/*/feature/?"}, map[string]Metadata{"a": linux}, 1, nil, 1},
+		{"pattern mismatch", map[string]string{"variables.GITHUB_REF": "refs/tags/*"}, map[string]Metadata{"a": linux}, 1, ErrEnvironmentMismatch, 0},
+		{"mismatch above threshold", map[string]string{"os": "linux"}, map[string]Metadata{"a": linux, "b": windows}, 1, nil, 1},
+		{"mismatch", map[string]string{"os": "linux"}, map[string]Metadata{"a": linux, "b": windows}, 2, ErrEnvironmentMismatch, 0},
+		{"missing value", map[string]string{"variables.GITHUB_REF": "*"}, map[string]Metadata{"b": windows}, 1, ErrEnvironmentMismatch, 0},
+		{"missing nested value", map[string]string{"hostname.name": "*"}, map[string]Metadata{"a": linux}, 1, ErrEnvironmentMismatch, 0},
+	}
+
+	for _, table := range tables {
+		t.Run(table.name, func(t *testing.T) {
+			layout := Layout{Steps: []Step{{
+				SupplyChainItem:     SupplyChainItem{Name: "build"},
+				Threshold:           table.threshold,
+				ExpectedEnvironment: table.expected,
+			}}}
+			result, err := VerifyLinkEnvironments(layout, map[string]map[string]Metadata{"build": table.links})
+			if table.expectedErr != nil {
+				assert.ErrorIs(t, err, table.expectedErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Len(t, result["build"], table.verified)
+		})
+	}
+}
+
+func TestMatchValueGlob(t *testing.T) {
+	tables := []struct {
+		pattern  string
+		value    string
+		expected bool
+	}{
+		{"refs/heads/*", "refs/heads/main", true},
+		{"refs/heads/*", "refs/heads/feature/x", true},
+		{"*", "org/repo", true},
+		{"/usr/*/go", "/usr/local/bin/go", true},
+		{"go1.2?", "go1.24", true},
+		{"go1.2?", "go1.2", false},
+		{"go[0-9].*", "go1.24", true},
+		{"go[^0-9]*", "go1.24", false},
+		{"a.c", "abc", false},
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+		{"*", "multi\nline", true},
+	}
+	for _, table := range tables {
+		matched, err := matchValueGlob(table.pattern, table.value)
+		assert.Nil(t, err, table.pattern)
+		assert.Equal(t, table.expected, matched, "%s matching %s", table.pattern, table.value)
+	}
+
+	for _, pattern := range []string{"[", "[]", "[^]", "a\\", "[a"} {
+		_, err := matchValueGlob(pattern, "a")
+		assert.ErrorIs(t, err, path.ErrBadPattern, pattern)
+	}
+}
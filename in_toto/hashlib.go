@@ -2,8 +2,10 @@ package in_toto
 
 import (
 	"crypto/sha256"
+	"crypto/sha3"
 	"crypto/sha512"
 	"hash"
+	"strings"
 )
 
 /*
@@ -15,9 +17,54 @@ func getHashMapping() map[string]func() hash.Hash {
 		"sha256": sha256.New,
 		"sha512": sha512.New,
 		"sha384": sha512.New384,
+		"sha3_256": func() hash.Hash {
+			return sha3.New256()
+		},
+		"sha3_384": func() hash.Hash {
+			return sha3.New384()
+		},
+		"sha3_512": func() hash.Hash {
+			return sha3.New512()
+		},
 	}
 }
 
+/*
+NormalizeDigestAlgorithm maps spellings of SHA-2 and SHA-3 algorithm names,
+e.g. 'SHA-256' or 'SHA3-256' as used by SBOMs, to the names used in links and
+ITE-6 digest sets, e.g. 'sha256' or 'sha3_256'.  Other algorithm names, e.g.
+'gitCommit', are returned unchanged.
+*/
+func NormalizeDigestAlgorithm(algorithm string) string {
+	lower := strings.ToLower(algorithm)
+	// Only separated prefixes are SHA-3, e.g. 'sha384' is SHA-2
+	for _, prefix := range []string{"sha3-", "sha3_"} {
+		if rest, ok := strings.CutPrefix(lower, prefix); ok && rest != "" {
+			return "sha3_" + rest
+		}
+	}
+	if rest, ok := strings.CutPrefix(lower, "sha"); ok && rest != "" {
+		return "sha" + strings.TrimLeft(rest, "-_")
+	}
+	if lower == "md5" {
+		return lower
+	}
+	return algorithm
+}
+
+/*
+NormalizeDigest returns a copy of the passed digest set, whose algorithm names
+are normalized using NormalizeDigestAlgorithm, and whose hex digests are lower
+case.
+*/
+func NormalizeDigest(digest map[string]string) HashObj {
+	normalized := HashObj{}
+	for algorithm, value := range digest {
+		normalized[NormalizeDigestAlgorithm(algorithm)] = strings.ToLower(value)
+	}
+	return normalized
+}
+
 /*
 hashToHex calculates the hash over data based on hash algorithm h.
 */
@@ -28,3 +75,24 @@ func hashToHex(h hash.Hash, data []byte) []byte {
 	// we would get: "dataHASH"
 	return h.Sum(nil)
 }
+
+/*
+matchesDigest returns true if the passed digest sets have at least one hash
+algorithm in common and agree on all common algorithms.  Algorithm names are
+normalized using NormalizeDigestAlgorithm.
+*/
+func matchesDigest(expected map[string]string, digests map[string]string) bool {
+	normalized := NormalizeDigest(digests)
+	common := 0
+	for algorithm, digest := range NormalizeDigest(expected) {
+		actual, ok := normalized[algorithm]
+		if !ok {
+			continue
+		}
+		if actual != digest {
+			return false
+		}
+		common++
+	}
+	return common > 0
+}
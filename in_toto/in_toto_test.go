@@ -13,6 +13,9 @@ const testData = "../test/data"
 // This can be used for test setup and teardown, e.g. copy test data to a tmp
 // test dir, change to that dir and remove the and contents in the end
 func TestMain(m *testing.M) {
+	// Commands run with resource limits or in a sandbox re-execute the test binary
+	MaybeRunCommandInit()
+
 	testDir, err := os.MkdirTemp("", "in_toto_test_dir")
 	if err != nil {
 		panic("Cannot create temp test dir")
@@ -0,0 +1,666 @@
+package in_toto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+)
+
+// ErrInvalidJWK is returned when a JSON Web Key cannot be decoded into a key
+// supported by in-toto.
+var ErrInvalidJWK = errors.New("invalid JWK")
+
+// ErrKeyIDMismatch is returned when a securesystemslib key file carries a
+// keyid that does not match the keyid computed from its contents.
+var ErrKeyIDMismatch = errors.New("keyid does not match key contents")
+
+const (
+	jwkKeyTypeRSA = "RSA"
+	jwkKeyTypeEC  = "EC"
+	jwkKeyTypeOKP = "OKP"
+)
+
+/*
+JWK is a minimal representation of a JSON Web Key (RFC 7517) covering the
+RSA, EC and OKP (Ed25519) key types. Private key parameters are only set for
+private keys.
+*/
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	// RSA parameters
+	N  string `json:"n,omitempty"`
+	E  string `json:"e,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	DP string `json:"dp,omitempty"`
+	DQ string `json:"dq,omitempty"`
+	QI string `json:"qi,omitempty"`
+	// EC and OKP parameters
+	X string `json:"x,omitempty"`
+	Y string `json:"y,omitempty"`
+	// D is the private exponent (RSA) or private key (EC, OKP)
+	D string `json:"d,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set as defined in RFC 7517, section 5.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+/*
+getJWKAlgorithmSchemes returns a mapping from JWA signature algorithm names to
+in-toto signature schemes. We need to use this function instead of a constant
+because Go does not support global constant maps.
+*/
+func getJWKAlgorithmSchemes() map[string]string {
+	return map[string]string{
+		"PS256": rsassapsssha256Scheme,
+		"PS384": rsassapsssha384Scheme,
+		"PS512": rsassapsssha512Scheme,
+		"RS256": rsapkcs1v15sha256,
+		"RS384": rsapkcs1v15sha384,
+		"RS512": rsapkcs1v15sha512,
+		"ES256": ecdsaSha2nistp256,
+		"ES384": ecdsaSha2nistp384,
+		"ES512": ecdsaSha2nistp521,
+		"EdDSA": ed25519Scheme,
+	}
+}
+
+/*
+getJWKCurves returns a mapping from JWK curve names to elliptic curves. We need
+to use this function instead of a constant because Go does not support global
+constant maps.
+*/
+func getJWKCurves() map[string]elliptic.Curve {
+	return map[string]elliptic.Curve{
+		"P-256": elliptic.P256(),
+		"P-384": elliptic.P384(),
+		"P-521": elliptic.P521(),
+	}
+}
+
+/*
+getJWKCurveAlgorithms returns a mapping from JWK curve names to the JWA
+signature algorithms of ECDSA keys on these curves, as defined in RFC 7518,
+section 3.4.
+*/
+func getJWKCurveAlgorithms() map[string]string {
+	return map[string]string{
+		"P-256": "ES256",
+		"P-384": "ES384",
+		"P-521": "ES512",
+	}
+}
+
+/*
+getCurveKeyScheme returns the default scheme of the passed key like
+getDefaultKeyScheme, except for ECDSA keys on a JWK curve, which get the scheme
+that matches their curve instead of ecdsa-sha2-nistp256.
+*/
+func getCurveKeyScheme(key interface{}) (string, error) {
+	scheme, _, err := getDefaultKeyScheme(key)
+	if err != nil {
+		return "", err
+	}
+
+	var curve elliptic.Curve
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		curve = k.Curve
+	case *ecdsa.PublicKey:
+		curve = k.Curve
+	case *x509.Certificate:
+		return getCurveKeyScheme(k.PublicKey)
+	default:
+		return scheme, nil
+	}
+	if alg, ok := getJWKCurveAlgorithms()[curve.Params().Name]; ok {
+		scheme = getJWKAlgorithmSchemes()[alg]
+	}
+	return scheme, nil
+}
+
+/*
+LoadKeySSLib loads a key stored in the securesystemslib JSON key format at the
+passed path into the key object. This is the format produced by the Python
+in-toto and securesystemslib tooling. See LoadKeySSLibReader for details.
+*/
+func (k *Key) LoadKeySSLib(path string) error {
+	keyFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer keyFile.Close()
+
+	if err := k.LoadKeySSLibReader(keyFile); err != nil {
+		return err
+	}
+
+	return keyFile.Close()
+}
+
+/*
+LoadKeySSLibReader loads a key in the securesystemslib JSON key format from the
+supplied reader. The key type, scheme and keyid hash algorithms are taken from
+the file as is. The keyid is recomputed and, if the file carries a keyid, it
+must match the computed one. Ed25519 private keys that only contain the 32 byte
+seed, as written by python-securesystemslib, are expanded to the full private
+key.
+*/
+func (k *Key) LoadKeySSLibReader(r io.Reader) error {
+	if r == nil {
+		return fmt.Errorf("%w: no key data", ErrInvalidKey)
+	}
+	keyBytes, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var sslibKey Key
+	if err := json.Unmarshal(keyBytes, &sslibKey); err != nil {
+		return fmt.Errorf("failed to decode securesystemslib key: %w", err)
+	}
+	if sslibKey.KeyType == "" {
+		return fmt.Errorf("%w: keytype", ErrEmptyKeyField)
+	}
+
+	if sslibKey.KeyType == ed25519KeyType && sslibKey.KeyVal.Private != "" {
+		private, err := hex.DecodeString(sslibKey.KeyVal.Private)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidHexString, sslibKey.KeyVal.Private)
+		}
+		if len(private) == ed25519.SeedSize {
+			sslibKey.KeyVal.Private = hex.EncodeToString(ed25519.NewKeyFromSeed(private))
+		}
+	}
+
+	if err := validateKeyVal(sslibKey); err != nil {
+		return err
+	}
+
+	fileKeyID := sslibKey.KeyID
+	if err := sslibKey.generateKeyID(); err != nil {
+		return err
+	}
+	if fileKeyID != "" && fileKeyID != sslibKey.KeyID {
+		return fmt.Errorf("%w: got %s, computed %s", ErrKeyIDMismatch, fileKeyID, sslibKey.KeyID)
+	}
+
+	*k = sslibKey
+	return nil
+}
+
+/*
+LoadKeyJWK loads the JSON Web Key at the passed path into the key object. See
+LoadKeyJWKReader for details.
+*/
+func (k *Key) LoadKeyJWK(path string, scheme string, keyIDHashAlgorithms []string) error {
+	keyFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer keyFile.Close()
+
+	if err := k.LoadKeyJWKReader(keyFile, scheme, keyIDHashAlgorithms); err != nil {
+		return err
+	}
+
+	return keyFile.Close()
+}
+
+/*
+LoadKeyJWKReader loads a single JSON Web Key from the supplied reader. RSA, EC
+(P-256, P-384, P-521) and OKP (Ed25519) keys are supported, both public and
+private. If scheme is empty, the scheme is derived from the "alg" member of the
+JWK, or, if that is absent too, the default scheme for the key type is used (see
+LoadKeyDefaults). If keyIDHashAlgorithms is nil, sha256 and sha512 are used.
+*/
+func (k *Key) LoadKeyJWKReader(r io.Reader, scheme string, keyIDHashAlgorithms []string) error {
+	if r == nil {
+		return ErrInvalidJWK
+	}
+	jwkBytes, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var jwk JWK
+	if err := json.Unmarshal(jwkBytes, &jwk); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidJWK, err)
+	}
+
+	return k.loadJWK(jwk, scheme, keyIDHashAlgorithms)
+}
+
+/*
+LoadJWKS decodes a JSON Web Key Set from the supplied reader and returns one
+key per contained JWK, in the order they appear in the set. Schemes are derived
+as described in LoadKeyJWKReader. Loading fails if any key in the set cannot be
+decoded.
+*/
+func LoadJWKS(r io.Reader, keyIDHashAlgorithms []string) ([]Key, error) {
+	if r == nil {
+		return nil, ErrInvalidJWK
+	}
+	jwksBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks JWKS
+	if err := json.Unmarshal(jwksBytes, &jwks); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJWK, err)
+	}
+
+	keys := make([]Key, 0, len(jwks.Keys))
+	for i, jwk := range jwks.Keys {
+		var key Key
+		if err := key.loadJWK(jwk, "", keyIDHashAlgorithms); err != nil {
+			return nil, fmt.Errorf("key %d of key set: %w", i, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// loadJWK converts the JWK into a Go key object and loads it like a PEM key.
+func (k *Key) loadJWK(jwk JWK, scheme string, keyIDHashAlgorithms []string) error {
+	keyObj, err := jwk.cryptoKey()
+	if err != nil {
+		return err
+	}
+
+	if scheme == "" && jwk.Algorithm != "" {
+		var ok bool
+		scheme, ok = getJWKAlgorithmSchemes()[jwk.Algorithm]
+		if !ok {
+			return fmt.Errorf("%w: unsupported algorithm %s", ErrInvalidJWK, jwk.Algorithm)
+		}
+	}
+	if scheme == "" {
+		scheme, err = getCurveKeyScheme(keyObj)
+		if err != nil {
+			return err
+		}
+	}
+	if keyIDHashAlgorithms == nil {
+		keyIDHashAlgorithms = []string{"sha256", "sha512"}
+	}
+
+	// loadKey stores RSA and ECDSA private keys from the PEM block it is
+	// passed, so we have to synthesize one.
+	pemData := &pem.Block{}
+	switch key := keyObj.(type) {
+	case *rsa.PrivateKey:
+		pemData.Type = pemRSAPrivateKey
+		pemData.Bytes = x509.MarshalPKCS1PrivateKey(key)
+	case *ecdsa.PrivateKey:
+		pemData.Type = pemPrivateKey
+		pemData.Bytes, err = x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return k.loadKey(keyObj, pemData, scheme, keyIDHashAlgorithms)
+}
+
+// decodeJWKBigInt decodes a base64url encoded, unpadded big-endian integer.
+func decodeJWKBigInt(param string, value string) (*big.Int, error) {
+	b, err := decodeJWKBytes(param, value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// decodeJWKBytes decodes a base64url encoded, unpadded JWK parameter.
+func decodeJWKBytes(param string, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%w: missing parameter %s", ErrInvalidJWK, param)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: parameter %s: %s", ErrInvalidJWK, param, err)
+	}
+	return b, nil
+}
+
+// cryptoKey returns the Go representation of the JWK, i.e. one of the public
+// or private key types accepted by loadKey.
+func (jwk JWK) cryptoKey() (interface{}, error) {
+	switch jwk.KeyType {
+	case jwkKeyTypeRSA:
+		n, err := decodeJWKBigInt("n", jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKBigInt("e", jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("%w: public exponent too large", ErrInvalidJWK)
+		}
+		pub := rsa.PublicKey{N: n, E: int(e.Int64())}
+		if jwk.D == "" {
+			return &pub, nil
+		}
+
+		d, err := decodeJWKBigInt("d", jwk.D)
+		if err != nil {
+			return nil, err
+		}
+		p, err := decodeJWKBigInt("p", jwk.P)
+		if err != nil {
+			return nil, err
+		}
+		q, err := decodeJWKBigInt("q", jwk.Q)
+		if err != nil {
+			return nil, err
+		}
+		priv := &rsa.PrivateKey{PublicKey: pub, D: d, Primes: []*big.Int{p, q}}
+		if err := priv.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidJWK, err)
+		}
+		priv.Precompute()
+		return priv, nil
+
+	case jwkKeyTypeEC:
+		curve, ok := getJWKCurves()[jwk.Curve]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported curve %s", ErrInvalidJWK, jwk.Curve)
+		}
+		x, err := decodeJWKBigInt("x", jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKBigInt("y", jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		// Marshalling rejects points that are not on the curve
+		if _, err := x509.MarshalPKIXPublicKey(&pub); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidJWK, err)
+		}
+		if jwk.D == "" {
+			return &pub, nil
+		}
+
+		d, err := decodeJWKBigInt("d", jwk.D)
+		if err != nil {
+			return nil, err
+		}
+		priv := &ecdsa.PrivateKey{PublicKey: pub, D: d}
+		if _, err := x509.MarshalPKCS8PrivateKey(priv); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidJWK, err)
+		}
+		return priv, nil
+
+	case jwkKeyTypeOKP:
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("%w: unsupported curve %s", ErrInvalidJWK, jwk.Curve)
+		}
+		x, err := decodeJWKBytes("x", jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 public key size", ErrInvalidJWK)
+		}
+		if jwk.D == "" {
+			return ed25519.PublicKey(x), nil
+		}
+
+		d, err := decodeJWKBytes("d", jwk.D)
+		if err != nil {
+			return nil, err
+		}
+		if len(d) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 private key size", ErrInvalidJWK)
+		}
+		priv := ed25519.NewKeyFromSeed(d)
+		if !priv.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+			return nil, fmt.Errorf("%w: Ed25519 private and public key do not match", ErrInvalidJWK)
+		}
+		return priv, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedKeyType, jwk.KeyType)
+}
+
+/*
+cryptoKeys parses the key values of the key into Go key objects. The private
+key is nil if the key only holds a public key.
+*/
+func (k Key) cryptoKeys() (crypto.PublicKey, crypto.PrivateKey, error) {
+	switch k.KeyType {
+	case ed25519KeyType:
+		public, err := hex.DecodeString(k.KeyVal.Public)
+		if err != nil || len(public) != ed25519.PublicKeySize {
+			return nil, nil, fmt.Errorf("%w: invalid ed25519 public key", ErrInvalidKey)
+		}
+		if k.KeyVal.Private == "" {
+			return ed25519.PublicKey(public), nil, nil
+		}
+		private, err := hex.DecodeString(k.KeyVal.Private)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid ed25519 private key", ErrInvalidKey)
+		}
+		switch len(private) {
+		case ed25519.SeedSize:
+			return ed25519.PublicKey(public), ed25519.NewKeyFromSeed(private), nil
+		case ed25519.PrivateKeySize:
+			return ed25519.PublicKey(public), ed25519.PrivateKey(private), nil
+		}
+		return nil, nil, fmt.Errorf("%w: invalid ed25519 private key", ErrInvalidKey)
+
+	case rsaKeyType, ecdsaKeyType:
+		_, public, err := decodeAndParse([]byte(k.KeyVal.Public))
+		if err != nil {
+			return nil, nil, err
+		}
+		if k.KeyVal.Private == "" {
+			return public, nil, nil
+		}
+		_, private, err := decodeAndParse([]byte(k.KeyVal.Private))
+		if err != nil {
+			return nil, nil, err
+		}
+		return public, private, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedKeyType, k.KeyType)
+}
+
+/*
+MarshalPEM returns the key in PEM format. If includePrivate is true and the key
+holds a private key, the private key is returned as PKCS8 "PRIVATE KEY" block,
+otherwise the public key is returned as PKIX "PUBLIC KEY" block.
+*/
+func (k Key) MarshalPEM(includePrivate bool) ([]byte, error) {
+	public, private, err := k.cryptoKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	if includePrivate && private != nil {
+		privateBytes, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			return nil, err
+		}
+		return generatePEMBlock(privateBytes, pemPrivateKey), nil
+	}
+
+	publicBytes, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	return generatePEMBlock(publicBytes, pemPublicKey), nil
+}
+
+/*
+MarshalSSLib returns the key in the securesystemslib JSON key format. Ed25519
+private keys are written as 32 byte seed, which is what python-securesystemslib
+expects. The private key is only included if includePrivate is true.
+*/
+func (k Key) MarshalSSLib(includePrivate bool) ([]byte, error) {
+	if err := validateKey(k); err != nil {
+		return nil, err
+	}
+
+	sslibKey := k
+	sslibKey.KeyVal.Certificate = ""
+	if !includePrivate {
+		sslibKey.KeyVal.Private = ""
+	} else if k.KeyType == ed25519KeyType && k.KeyVal.Private != "" {
+		_, private, err := k.cryptoKeys()
+		if err != nil {
+			return nil, err
+		}
+		sslibKey.KeyVal.Private = hex.EncodeToString(private.(ed25519.PrivateKey).Seed())
+	}
+
+	return json.MarshalIndent(sslibKey, "", "  ")
+}
+
+/*
+MarshalJWK returns the key as JSON Web Key. The keyid is used as "kid" and the
+scheme is translated into the corresponding "alg" value, except for ECDSA keys,
+whose "alg" value is that of their curve. The private key is
+only included if includePrivate is true.
+*/
+func (k Key) MarshalJWK(includePrivate bool) ([]byte, error) {
+	jwk, err := k.JWK(includePrivate)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(jwk, "", "  ")
+}
+
+// JWK converts the key into a JWK. See MarshalJWK.
+func (k Key) JWK(includePrivate bool) (JWK, error) {
+	public, private, err := k.cryptoKeys()
+	if err != nil {
+		return JWK{}, err
+	}
+
+	jwk := JWK{KeyID: k.KeyID, Use: "sig"}
+	for alg, scheme := range getJWKAlgorithmSchemes() {
+		if scheme == k.Scheme {
+			jwk.Algorithm = alg
+			break
+		}
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = jwkKeyTypeRSA
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+		if priv, ok := private.(*rsa.PrivateKey); ok && includePrivate {
+			if len(priv.Primes) != 2 {
+				return JWK{}, fmt.Errorf("%w: multi-prime RSA keys are not supported", ErrInvalidJWK)
+			}
+			priv.Precompute()
+			jwk.D = b64(priv.D.Bytes())
+			jwk.P = b64(priv.Primes[0].Bytes())
+			jwk.Q = b64(priv.Primes[1].Bytes())
+			jwk.DP = b64(priv.Precomputed.Dp.Bytes())
+			jwk.DQ = b64(priv.Precomputed.Dq.Bytes())
+			jwk.QI = b64(priv.Precomputed.Qinv.Bytes())
+		}
+	case *ecdsa.PublicKey:
+		jwk.KeyType = jwkKeyTypeEC
+		jwk.Curve = pub.Curve.Params().Name
+		if _, ok := getJWKCurves()[jwk.Curve]; !ok {
+			return JWK{}, fmt.Errorf("%w: unsupported curve %s", ErrInvalidJWK, jwk.Curve)
+		}
+		// the algorithm of ECDSA keys is determined by their curve
+		jwk.Algorithm = getJWKCurveAlgorithms()[jwk.Curve]
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+		if priv, ok := private.(*ecdsa.PrivateKey); ok && includePrivate {
+			jwk.D = b64(priv.D.FillBytes(make([]byte, size)))
+		}
+	case ed25519.PublicKey:
+		jwk.KeyType = jwkKeyTypeOKP
+		jwk.Curve = "Ed25519"
+		jwk.X = b64(pub)
+		if priv, ok := private.(ed25519.PrivateKey); ok && includePrivate {
+			jwk.D = b64(priv.Seed())
+		}
+	default:
+		return JWK{}, ErrUnsupportedKeyType
+	}
+
+	return jwk, nil
+}
+
+/*
+LoadKeyAnyFormat loads the key file at the passed path, detecting whether it
+holds a PEM encoded key, a securesystemslib JSON key, a JWK or a JWK Set. JWK
+Sets must contain exactly one key. PEM keys and JWKs without "alg" member are
+loaded with the default scheme, see LoadKeyDefaults, except for ECDSA keys on
+the P-384 and P-521 curves, which are loaded with the scheme of their curve.
+*/
+func (k *Key) LoadKeyAnyFormat(path string) error {
+	keyBytes, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(keyBytes, &probe); err != nil {
+		// not JSON, so this must be PEM
+		pemData, key, err := decodeAndParse(keyBytes)
+		if err != nil {
+			return err
+		}
+		scheme, err := getCurveKeyScheme(key)
+		if err != nil {
+			return err
+		}
+		return k.loadKey(key, pemData, scheme, []string{"sha256", "sha512"})
+	}
+
+	switch {
+	case probe["keys"] != nil:
+		keys, err := LoadJWKS(bytes.NewReader(keyBytes), nil)
+		if err != nil {
+			return err
+		}
+		if len(keys) != 1 {
+			return fmt.Errorf("%w: key set must contain exactly one key, found %d", ErrInvalidJWK, len(keys))
+		}
+		*k = keys[0]
+		return nil
+	case probe["kty"] != nil:
+		return k.LoadKeyJWKReader(bytes.NewReader(keyBytes), "", nil)
+	case probe["keytype"] != nil:
+		return k.LoadKeySSLibReader(bytes.NewReader(keyBytes))
+	}
+
+	return fmt.Errorf("%w: unrecognized JSON key format", ErrUnsupportedKeyType)
+}
@@ -0,0 +1,232 @@
+package in_toto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLoadKeySSLib makes sure that keys in the securesystemslib JSON format
+// are loaded with their original scheme and keyid.
+func TestLoadKeySSLib(t *testing.T) {
+	validTables := []struct {
+		name           string
+		path           string
+		expectedKeyID  string
+		expectedScheme string
+		private        bool
+	}{
+		{"ed25519 private key", "sslib-ed25519.json", "52e3b8e73279d6ebdd62a5016e2725ff284f569665eb92ccb145d83817a02997", ed25519Scheme, true},
+		{"ed25519 public key", "sslib-ed25519.pub.json", "52e3b8e73279d6ebdd62a5016e2725ff284f569665eb92ccb145d83817a02997", ed25519Scheme, false},
+		{"ecdsa private key", "sslib-ecdsa.json", "98adf38602c48c5479e9a991ee3f8cbf541ee4f985e00f7a5fc4148d9a45b704", ecdsaSha2nistp256, true},
+		{"ecdsa public key", "sslib-ecdsa.pub.json", "98adf38602c48c5479e9a991ee3f8cbf541ee4f985e00f7a5fc4148d9a45b704", ecdsaSha2nistp256, false},
+	}
+	for _, table := range validTables {
+		var key Key
+		if err := key.LoadKeySSLib(table.path); err != nil {
+			t.Errorf("failed key.LoadKeySSLib() for %s %s. Error: %s", table.name, table.path, err)
+			continue
+		}
+		assert.Equal(t, table.expectedKeyID, key.KeyID, table.name)
+		assert.Equal(t, table.expectedScheme, key.Scheme, table.name)
+		assert.Equal(t, []string{"sha256", "sha512"}, key.KeyIDHashAlgorithms, table.name)
+		assert.Equal(t, table.private, key.KeyVal.Private != "", table.name)
+	}
+
+	// Keys loaded from seed-only ed25519 files must be usable for signing
+	var privKey, pubKey Key
+	assert.Nil(t, privKey.LoadKeySSLib("sslib-ed25519.json"))
+	assert.Nil(t, pubKey.LoadKeySSLib("sslib-ed25519.pub.json"))
+	mb := &Metablock{Signed: Link{Type: "link", Name: "sslib"}}
+	assert.Nil(t, mb.Sign(privKey))
+	assert.Nil(t, mb.VerifySignature(pubKey))
+}
+
+func TestLoadKeySSLibErrors(t *testing.T) {
+	invalidTables := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{"keyid mismatch", `{"keytype": "ed25519", "scheme": "ed25519", "keyid": "abcdef", "keyid_hash_algorithms": ["sha256", "sha512"], "keyval": {"public": "3f586ce67329419fb0081bd995914e866a7205da463d593b3b490eab2b27fd3f"}}`, ErrKeyIDMismatch},
+		{"missing keytype", `{"scheme": "ed25519", "keyval": {"public": "3f586ce67329419fb0081bd995914e866a7205da463d593b3b490eab2b27fd3f"}}`, ErrEmptyKeyField},
+		{"scheme mismatch", `{"keytype": "ed25519", "scheme": "rsassa-pss-sha256", "keyval": {"public": "3f586ce67329419fb0081bd995914e866a7205da463d593b3b490eab2b27fd3f"}}`, ErrSchemeKeyTypeMismatch},
+		{"legacy ecdsa keytype", `{"keytype": "ecdsa-sha2-nistp256", "scheme": "ecdsa-sha2-nistp256", "keyval": {"public": ""}}`, ErrUnsupportedKeyType},
+		{"invalid hex", `{"keytype": "ed25519", "scheme": "ed25519", "keyval": {"public": "zz"}}`, ErrInvalidHexString},
+	}
+	for _, table := range invalidTables {
+		var key Key
+		err := key.LoadKeySSLibReader(strings.NewReader(table.data))
+		if !errors.Is(err, table.err) {
+			t.Errorf("failed LoadKeySSLibReader() for %s, got error: %s. Should have: %s", table.name, err, table.err)
+		}
+	}
+}
+
+// TestLoadKeyJWK tests loading of the Ed25519 example key from RFC 8037,
+// appendix A.
+func TestLoadKeyJWK(t *testing.T) {
+	jwk := `{"kty":"OKP","crv":"Ed25519",
+		"d":"nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A",
+		"x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`
+
+	var key Key
+	err := key.LoadKeyJWKReader(strings.NewReader(jwk), "", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, ed25519KeyType, key.KeyType)
+	assert.Equal(t, ed25519Scheme, key.Scheme)
+	assert.Equal(t, "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a", key.KeyVal.Public)
+	assert.Equal(t, "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a", key.KeyVal.Private)
+
+	// The public part of a mismatching key pair is rejected
+	mismatch := strings.Replace(jwk, "11qYAYKxCrfVS", "21qYAYKxCrfVS", 1)
+	err = key.LoadKeyJWKReader(strings.NewReader(mismatch), "", nil)
+	assert.ErrorIs(t, err, ErrInvalidJWK)
+}
+
+func TestLoadKeyJWKErrors(t *testing.T) {
+	invalidTables := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{"not json", "-----BEGIN PUBLIC KEY-----", ErrInvalidJWK},
+		{"unknown kty", `{"kty":"oct","k":"AAAA"}`, ErrUnsupportedKeyType},
+		{"unsupported curve", `{"kty":"EC","crv":"secp256k1","x":"AA","y":"AA"}`, ErrInvalidJWK},
+		{"point not on curve", `{"kty":"EC","crv":"P-256","x":"AQ","y":"AQ"}`, ErrInvalidJWK},
+		{"missing rsa exponent", `{"kty":"RSA","n":"AQAB"}`, ErrInvalidJWK},
+		{"unsupported alg", `{"kty":"OKP","crv":"Ed25519","alg":"HS256","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`, ErrInvalidJWK},
+		{"alg mismatching key type", `{"kty":"OKP","crv":"Ed25519","alg":"ES256","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`, ErrSchemeKeyTypeMismatch},
+	}
+	for _, table := range invalidTables {
+		var key Key
+		err := key.LoadKeyJWKReader(strings.NewReader(table.data), "", nil)
+		if !errors.Is(err, table.err) {
+			t.Errorf("failed LoadKeyJWKReader() for %s, got error: %s. Should have: %s", table.name, err, table.err)
+		}
+	}
+}
+
+// TestKeyFormatRoundTrip converts keys to every supported format and back,
+// making sure the key id, i.e. key type, scheme and public key, is preserved.
+func TestKeyFormatRoundTrip(t *testing.T) {
+	for _, path := range []string{"dan", "dan.pub", "carol", "carol.pub", "frank", "frank.pub", "grace", "grace.pub", "sslib-ed25519.json", "sslib-ecdsa.json"} {
+		var key Key
+		if err := key.LoadKeyAnyFormat(path); err != nil {
+			t.Errorf("failed to load %s: %s", path, err)
+			continue
+		}
+
+		jwkBytes, err := key.MarshalJWK(true)
+		assert.Nil(t, err, path)
+		var fromJWK Key
+		assert.Nil(t, fromJWK.LoadKeyJWKReader(bytes.NewReader(jwkBytes), "", nil), path)
+		assert.Equal(t, key.KeyID, fromJWK.KeyID, path)
+		assert.Equal(t, key.KeyVal.Private != "", fromJWK.KeyVal.Private != "", path)
+
+		sslibBytes, err := key.MarshalSSLib(true)
+		assert.Nil(t, err, path)
+		var fromSSLib Key
+		assert.Nil(t, fromSSLib.LoadKeySSLibReader(bytes.NewReader(sslibBytes)), path)
+		assert.Equal(t, key, fromSSLib, path)
+
+		pemBytes, err := key.MarshalPEM(true)
+		assert.Nil(t, err, path)
+		var fromPEM Key
+		assert.Nil(t, fromPEM.LoadKeyReader(bytes.NewReader(pemBytes), key.Scheme, key.KeyIDHashAlgorithms), path)
+		assert.Equal(t, key.KeyID, fromPEM.KeyID, path)
+
+		// Public only exports must not contain any private key material
+		jwkBytes, err = key.MarshalJWK(false)
+		assert.Nil(t, err, path)
+		assert.NotContains(t, string(jwkBytes), `"d"`, path)
+		sslibBytes, err = key.MarshalSSLib(false)
+		assert.Nil(t, err, path)
+		assert.NotContains(t, string(sslibBytes), `"private"`, path)
+	}
+
+	// P-224 is not a registered JWK curve
+	var heidi Key
+	assert.Nil(t, heidi.LoadKeyDefaults("heidi"))
+	_, err := heidi.MarshalJWK(false)
+	assert.ErrorIs(t, err, ErrInvalidJWK)
+}
+
+// TestKeyFormatCurveAlgorithm makes sure ECDSA keys get the scheme and JWA
+// algorithm of their curve, regardless of the format they are loaded from.
+func TestKeyFormatCurveAlgorithm(t *testing.T) {
+	tables := []struct {
+		path      string
+		scheme    string
+		algorithm string
+		curve     string
+	}{
+		{"sslib-ecdsa.json", ecdsaSha2nistp256, "ES256", "P-256"},
+		{"grace", ecdsaSha2nistp384, "ES384", "P-384"},
+		{"grace.pub", ecdsaSha2nistp384, "ES384", "P-384"},
+		{"frank", ecdsaSha2nistp521, "ES512", "P-521"},
+	}
+	for _, table := range tables {
+		var key Key
+		if !assert.Nil(t, key.LoadKeyAnyFormat(table.path), table.path) {
+			continue
+		}
+		assert.Equal(t, table.scheme, key.Scheme, table.path)
+
+		jwk, err := key.JWK(true)
+		if !assert.Nil(t, err, table.path) {
+			continue
+		}
+		assert.Equal(t, table.algorithm, jwk.Algorithm, table.path)
+		assert.Equal(t, table.curve, jwk.Curve, table.path)
+
+		// without "alg" member, the scheme is taken from the curve
+		jwk.Algorithm = ""
+		jwkBytes, err := json.Marshal(jwk)
+		assert.Nil(t, err, table.path)
+		var fromJWK Key
+		assert.Nil(t, fromJWK.LoadKeyJWKReader(bytes.NewReader(jwkBytes), "", nil), table.path)
+		assert.Equal(t, key.Scheme, fromJWK.Scheme, table.path)
+		assert.Equal(t, key.KeyID, fromJWK.KeyID, table.path)
+	}
+
+	// keys loaded with a mismatching scheme still export the algorithm of their curve
+	var grace Key
+	assert.Nil(t, grace.LoadKeyDefaults("grace"))
+	assert.Equal(t, ecdsaSha2nistp256, grace.Scheme)
+	jwk, err := grace.JWK(false)
+	assert.Nil(t, err)
+	assert.Equal(t, "ES384", jwk.Algorithm)
+}
+
+func TestLoadJWKS(t *testing.T) {
+	var dan, carol Key
+	assert.Nil(t, dan.LoadKeyDefaults("dan.pub"))
+	assert.Nil(t, carol.LoadKeyDefaults("carol.pub"))
+
+	danJWK, err := dan.JWK(false)
+	assert.Nil(t, err)
+	carolJWK, err := carol.JWK(false)
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, json.NewEncoder(&buf).Encode(JWKS{Keys: []JWK{danJWK, carolJWK}}))
+
+	keys, err := LoadJWKS(bytes.NewReader(buf.Bytes()), nil)
+	assert.Nil(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, dan.KeyID, keys[0].KeyID)
+		assert.Equal(t, carol.KeyID, keys[1].KeyID)
+	}
+
+	// A key set with more than one key is ambiguous for LoadKeyAnyFormat
+	jwksPath := t.TempDir() + "/keys.jwks"
+	assert.Nil(t, os.WriteFile(jwksPath, buf.Bytes(), 0644))
+	var key Key
+	assert.ErrorIs(t, key.LoadKeyAnyFormat(jwksPath), ErrInvalidJWK)
+}
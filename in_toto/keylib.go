@@ -13,6 +13,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/secure-systems-lab/go-securesystemslib/cjson"
 )
@@ -36,7 +37,14 @@ const (
 	rsaKeyType            string = "rsa"
 	ecdsaKeyType          string = "ecdsa"
 	ed25519KeyType        string = "ed25519"
+	rsassapsssha224Scheme string = "rsassa-pss-sha224"
 	rsassapsssha256Scheme string = "rsassa-pss-sha256"
+	rsassapsssha384Scheme string = "rsassa-pss-sha384"
+	rsassapsssha512Scheme string = "rsassa-pss-sha512"
+	rsapkcs1v15sha224     string = "rsa-pkcs1v15-sha224"
+	rsapkcs1v15sha256     string = "rsa-pkcs1v15-sha256"
+	rsapkcs1v15sha384     string = "rsa-pkcs1v15-sha384"
+	rsapkcs1v15sha512     string = "rsa-pkcs1v15-sha512"
 	ecdsaSha2nistp224     string = "ecdsa-sha2-nistp224"
 	ecdsaSha2nistp256     string = "ecdsa-sha2-nistp256"
 	ecdsaSha2nistp384     string = "ecdsa-sha2-nistp384"
@@ -58,11 +66,15 @@ func getSupportedKeyIDHashAlgorithms() Set {
 
 /*
 getSupportedRSASchemes returns a string slice of supported RSA Key schemes.
+These are all RSA schemes known to the securesystemslib.
 We need to use this function instead of a constant because Go does not support
 global constant slices.
 */
 func getSupportedRSASchemes() []string {
-	return []string{rsassapsssha256Scheme}
+	return []string{
+		rsassapsssha224Scheme, rsassapsssha256Scheme, rsassapsssha384Scheme, rsassapsssha512Scheme,
+		rsapkcs1v15sha224, rsapkcs1v15sha256, rsapkcs1v15sha384, rsapkcs1v15sha512,
+	}
 }
 
 /*
@@ -271,8 +283,11 @@ the key type field:
 The following schemes are supported:
 
   - ed25519 -> ed25519
-  - rsa -> rsassa-pss-sha256
-  - ecdsa -> ecdsa-sha256-nistp256
+  - rsa -> rsassa-pss-sha224, rsassa-pss-sha256, rsassa-pss-sha384,
+    rsassa-pss-sha512, rsa-pkcs1v15-sha224, rsa-pkcs1v15-sha256,
+    rsa-pkcs1v15-sha384, rsa-pkcs1v15-sha512
+  - ecdsa -> ecdsa-sha2-nistp224, ecdsa-sha2-nistp256, ecdsa-sha2-nistp384,
+    ecdsa-sha2-nistp521
 
 Note that, this behavior is consistent with the securesystemslib, except for
 ecdsa. We do not use the scheme string as key type in in-toto-golang.
@@ -450,9 +465,19 @@ to a root in rootCertPool, possibly using any intermediates in
 intermediateCertPool
 */
 func VerifyCertificateTrust(cert *x509.Certificate, rootCertPool, intermediateCertPool *x509.CertPool) ([][]*x509.Certificate, error) {
+	return VerifyCertificateTrustAt(cert, rootCertPool, intermediateCertPool, time.Time{})
+}
+
+/*
+VerifyCertificateTrustAt works like VerifyCertificateTrust, but checks the
+validity of the certificates in the chain at the passed time instead of the
+current time, unless it is the zero time.
+*/
+func VerifyCertificateTrustAt(cert *x509.Certificate, rootCertPool, intermediateCertPool *x509.CertPool, verificationTime time.Time) ([][]*x509.Certificate, error) {
 	verifyOptions := x509.VerifyOptions{
 		Roots:         rootCertPool,
 		Intermediates: intermediateCertPool,
+		CurrentTime:   verificationTime,
 	}
 	chains, err := cert.Verify(verifyOptions)
 	if len(chains) == 0 || err != nil {
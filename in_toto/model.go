@@ -1,12 +1,15 @@
 package in_toto
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"os"
@@ -26,7 +29,9 @@ type HashObj = map[string]string
 KeyVal contains the actual values of a key, as opposed to key metadata such as
 a key identifier or key type.  For RSA keys, the key value is a pair of public
 and private keys in PEM format stored as strings.  For public keys the Private
-field may be an empty string.
+field may be an empty string.  The Certificate field holds the PEM encoded
+certificate of the key, optionally followed by intermediate certificates that
+are needed to establish trust in it.
 */
 type KeyVal struct {
 	Private     string `json:"private,omitempty"`
@@ -285,12 +290,15 @@ func validatePublicKey(key Key) error {
 /*
 Signature represents a generic in-toto signature that contains the identifier
 of the Key, which was used to create the signature and the signature data.  The
-used signature scheme is found in the corresponding Key.
+used signature scheme is found in the corresponding Key.  Signatures by
+certificates may carry the base64 encoded RFC 3161 timestamp token of the
+signature data in the Timestamp field.
 */
 type Signature struct {
 	KeyID       string `json:"keyid"`
 	Sig         string `json:"sig"`
 	Certificate string `json:"cert,omitempty"`
+	Timestamp   string `json:"timestamp,omitempty"`
 }
 
 // GetCertificate returns the parsed x509 certificate attached to the signature,
@@ -305,6 +313,53 @@ func (sig Signature) GetCertificate() (Key, error) {
 	return key, err
 }
 
+/*
+GetIntermediates returns the intermediate certificates that follow the signer
+certificate in the Certificate field of the signature, if any.
+*/
+func (sig Signature) GetIntermediates() ([]*x509.Certificate, error) {
+	chain, err := splitCertificateChain(sig.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	intermediates := []*x509.Certificate{}
+	for _, certPEM := range chain[1:] {
+		block, _ := pem.Decode([]byte(certPEM))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		intermediates = append(intermediates, cert)
+	}
+	return intermediates, nil
+}
+
+/*
+splitCertificateChain splits a chain of PEM encoded certificates into the PEM
+encodings of the single certificates, starting with the signer certificate.
+Any other PEM blocks or data between the certificates are dropped.
+*/
+func splitCertificateChain(chain string) ([]string, error) {
+	certs := []string{}
+	rest := []byte(chain)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, string(pem.EncodeToMemory(block)))
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, ErrNoPEMBlock
+	}
+	return certs, nil
+}
+
 /*
 validateSignature is a function used to check if a passed signature is valid,
 by inspecting the key ID and the signature itself.
@@ -336,7 +391,9 @@ func validateSliceOfSignatures(slice []Signature) error {
 Link represents the evidence of a supply chain step performed by a functionary.
 It should be contained in a generic Metablock object, which provides
 functionality for signing and signature verification, and reading from and
-writing to disk.
+writing to disk.  StartedOn and FinishedOn optionally record when the step
+started and finished, as (zulu) dates in ISO8601DateSchema format, so that
+the time constraints of a step can be verified, see VerifyLinkTimes.
 */
 type Link struct {
 	Type        string                 `json:"_type"`
@@ -346,6 +403,8 @@ type Link struct {
 	ByProducts  map[string]interface{} `json:"byproducts"`
 	Command     []string               `json:"command"`
 	Environment map[string]interface{} `json:"environment"`
+	StartedOn   string                 `json:"started_on,omitempty"`
+	FinishedOn  string                 `json:"finished_on,omitempty"`
 }
 
 /*
@@ -392,6 +451,16 @@ func validateLink(link Link) error {
 			err.Error())
 	}
 
+	for _, linkTime := range []string{link.StartedOn, link.FinishedOn} {
+		if linkTime == "" {
+			continue
+		}
+		if _, err := time.Parse(ISO8601DateSchema, linkTime); err != nil {
+			return fmt.Errorf("invalid time of link '%s': %s", link.Name,
+				err.Error())
+		}
+	}
+
 	return nil
 }
 
@@ -508,7 +577,14 @@ During final product verification in-toto looks for corresponding Link
 metadata, which is used as signed evidence that the step was performed
 according to the supply chain definition.  Materials and products used/produced
 by the step are constrained by the artifact rules in the step's
-ExpectedMaterials and ExpectedProducts fields.
+ExpectedMaterials and ExpectedProducts fields.  MaxLinkAge optionally limits
+how long ago, as a duration such as "72h", links of the step may have finished,
+and After optionally lists the names of steps whose links must have finished
+before links of the step started.  Both require links that record their start
+and finish times.  ExpectedEnvironment optionally maps keys of values in the
+Environment of links of the step, such as "os" or "variables.CI", to the
+values they must have, which may be glob patterns, whose `*` also matches `/`,
+or regular expressions with the "regex:" prefix, see VerifyLinkEnvironments.
 */
 type Step struct {
 	Type                   string                  `json:"_type"`
@@ -516,38 +592,80 @@ type Step struct {
 	CertificateConstraints []CertificateConstraint `json:"cert_constraints,omitempty"`
 	ExpectedCommand        []string                `json:"expected_command"`
 	Threshold              int                     `json:"threshold"`
+	MaxLinkAge             string                  `json:"max_link_age,omitempty"`
+	After                  []string                `json:"after,omitempty"`
+	ExpectedEnvironment    map[string]string       `json:"expected_environment,omitempty"`
 	SupplyChainItem
 }
 
+// CertConstraintsError is returned when a certificate matches none of the
+// certificate constraints of a step. It holds the result of every constraint,
+// to explain which attributes of the certificate did not match.
+type CertConstraintsError struct {
+	Results []ConstraintResult
+}
+
+// Error returns the error of the only constraint of the step, or the errors of
+// all of its constraints.
+func (e *CertConstraintsError) Error() string {
+	if len(e.Results) == 1 {
+		return e.Results[0].Err().Error()
+	}
+
+	errs := make([]string, 0, len(e.Results))
+	for i, result := range e.Results {
+		errs = append(errs, fmt.Sprintf("constraint %d: %s", i+1, result.Err()))
+	}
+	return fmt.Sprintf("cert matches none of %d constraints: %s", len(e.Results), strings.Join(errs, "; "))
+}
+
 // CheckCertConstraints returns true if the provided certificate matches at least one
 // of the constraints for this step.
 func (s Step) CheckCertConstraints(key Key, rootCAIDs []string, rootCertPool, intermediateCertPool *x509.CertPool) error {
+	return s.CheckCertConstraintsAt(key, rootCAIDs, rootCertPool, intermediateCertPool, time.Time{})
+}
+
+// CheckCertConstraintsAt works like CheckCertConstraints, but verifies the chain of trust of the
+// certificate at the passed time, e.g. the time of a trusted timestamp of a signature. The zero
+// time stands for the current time. If the certificate matches none of the constraints, a
+// *CertConstraintsError with the result of every constraint is returned.
+func (s Step) CheckCertConstraintsAt(key Key, rootCAIDs []string, rootCertPool, intermediateCertPool *x509.CertPool, verificationTime time.Time) error {
+	results, err := s.EvaluateCertConstraints(key, rootCAIDs, rootCertPool, intermediateCertPool, verificationTime)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if result.Passed() {
+			return nil
+		}
+	}
+	return &CertConstraintsError{Results: results}
+}
+
+// EvaluateCertConstraints checks the provided certificate against every constraint of this step
+// and returns their results, in the order of the constraints. An error is only returned if the
+// step has no constraints or the key has no valid certificate.
+func (s Step) EvaluateCertConstraints(key Key, rootCAIDs []string, rootCertPool, intermediateCertPool *x509.CertPool, verificationTime time.Time) ([]ConstraintResult, error) {
 	if len(s.CertificateConstraints) == 0 {
-		return fmt.Errorf("no constraints found")
+		return nil, fmt.Errorf("no constraints found")
 	}
 
 	_, possibleCert, err := decodeAndParse([]byte(key.KeyVal.Certificate))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	cert, ok := possibleCert.(*x509.Certificate)
 	if !ok {
-		return fmt.Errorf("not a valid certificate")
+		return nil, fmt.Errorf("not a valid certificate")
 	}
 
+	results := make([]ConstraintResult, 0, len(s.CertificateConstraints))
 	for _, constraint := range s.CertificateConstraints {
-		err = constraint.Check(cert, rootCAIDs, rootCertPool, intermediateCertPool)
-		if err == nil {
-			return nil
-		}
+		results = append(results, constraint.Evaluate(cert, rootCAIDs, rootCertPool, intermediateCertPool, verificationTime))
 	}
-	if err != nil {
-		return err
-	}
-
-	// this should not be reachable since there is at least one constraint, and the for loop only saw err != nil
-	return fmt.Errorf("unknown certificate constraint error")
+	return results, nil
 }
 
 /*
@@ -567,6 +685,17 @@ func validateStep(step Step) error {
 			return err
 		}
 	}
+	if step.MaxLinkAge != "" {
+		maxLinkAge, err := time.ParseDuration(step.MaxLinkAge)
+		if err != nil {
+			return fmt.Errorf("invalid max link age for step '%s': %s",
+				step.Name, err.Error())
+		}
+		if maxLinkAge <= 0 {
+			return fmt.Errorf("max link age for step '%s' must be positive",
+				step.Name)
+		}
+	}
 	return nil
 }
 
@@ -580,20 +709,26 @@ const ISO8601DateSchema = "2006-01-02T15:04:05Z"
 Layout represents the definition of a software supply chain.  It lists the
 sequence of steps required in the software supply chain and the functionaries
 authorized to perform these steps.  Functionaries are identified by their
-public keys.  In addition, the layout may list a sequence of inspections that
-are executed during in-toto supply chain verification.  A layout should be
+public keys, or by certificates issued by the RootCas and IntermediateCas,
+which may be revoked by the CRLs.  The certificates of trusted time stamping
+authorities in TimestampAuthorities allow checking the certificates of
+timestamped link signatures at the time of signing.  In addition, the layout
+may list a sequence of inspections that are executed during in-toto supply
+chain verification.  A layout should be
 contained in a generic Metablock object, which provides functionality for
 signing and signature verification, and reading from and writing to disk.
 */
 type Layout struct {
-	Type            string         `json:"_type"`
-	Steps           []Step         `json:"steps"`
-	Inspect         []Inspection   `json:"inspect"`
-	Keys            map[string]Key `json:"keys"`
-	RootCas         map[string]Key `json:"rootcas,omitempty"`
-	IntermediateCas map[string]Key `json:"intermediatecas,omitempty"`
-	Expires         string         `json:"expires"`
-	Readme          string         `json:"readme"`
+	Type                 string         `json:"_type"`
+	Steps                []Step         `json:"steps"`
+	Inspect              []Inspection   `json:"inspect"`
+	Keys                 map[string]Key `json:"keys"`
+	RootCas              map[string]Key `json:"rootcas,omitempty"`
+	IntermediateCas      map[string]Key `json:"intermediatecas,omitempty"`
+	CRLs                 map[string]CRL `json:"crls,omitempty"`
+	TimestampAuthorities map[string]Key `json:"timestamp_authorities,omitempty"`
+	Expires              string         `json:"expires"`
+	Readme               string         `json:"readme"`
 }
 
 // Go does not allow to pass `[]T` (slice with certain type) to a function
@@ -665,6 +800,21 @@ func validateLayout(layout Layout) error {
 		return err
 	}
 
+	if err := validateLayoutKeys(layout.TimestampAuthorities); err != nil {
+		return err
+	}
+
+	for caID, crl := range layout.CRLs {
+		_, isRoot := layout.RootCas[caID]
+		_, isIntermediate := layout.IntermediateCas[caID]
+		if !isRoot && !isIntermediate {
+			return fmt.Errorf("CRL for unknown CA '%s'", caID)
+		}
+		if crl.CRL == "" && crl.URI == "" {
+			return fmt.Errorf("CRL for CA '%s' must be embedded or referenced", caID)
+		}
+	}
+
 	var namesSeen = make(map[string]bool)
 	for _, step := range layout.Steps {
 		if namesSeen[step.Name] {
@@ -677,6 +827,17 @@ func validateLayout(layout Layout) error {
 			return err
 		}
 	}
+	for _, step := range layout.Steps {
+		for _, name := range step.After {
+			if name == step.Name {
+				return fmt.Errorf("step '%s' cannot be after itself", step.Name)
+			}
+			if !namesSeen[name] {
+				return fmt.Errorf("step '%s' must be after unknown step '%s'",
+					step.Name, name)
+			}
+		}
+	}
 	for _, inspection := range layout.Inspect {
 		if namesSeen[inspection.Name] {
 			return fmt.Errorf("non unique step or inspection name found")
@@ -721,7 +882,15 @@ func LoadMetadata(path string) (Metadata, error) {
 			return nil, ErrInvalidPayloadType
 		}
 
-		return loadEnvelope(dsseEnv)
+		env, err := loadEnvelope(dsseEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := env.loadExtensions(jsonBytes); err != nil {
+			return nil, err
+		}
+		return env, nil
 	}
 
 	mb := &Metablock{}
@@ -738,7 +907,7 @@ func LoadMetadata(path string) (Metadata, error) {
 		return nil, err
 	}
 
-	payload, err := loadPayload(*rawData["signed"])
+	payload, err := loadMetablockPayload(*rawData["signed"])
 	if err != nil {
 		return nil, err
 	}
@@ -748,6 +917,98 @@ func LoadMetadata(path string) (Metadata, error) {
 	return mb, nil
 }
 
+// ErrPayloadMismatch indicates that metadata to be merged carry different payloads
+var ErrPayloadMismatch = errors.New("payloads of metadata to merge do not match")
+
+// ErrMetadataTypeMismatch indicates that envelopes and metablocks were mixed
+var ErrMetadataTypeMismatch = errors.New("cannot merge signatures of envelopes and metablocks")
+
+/*
+MergeSignatures combines the signatures of the passed metadata, which must all
+be either Envelopes or Metablocks and carry identical payloads, into a new
+Metadata object of the same kind.  Signatures that are already present, i.e.
+signatures with the same key id and signature data, are only included once.
+Signatures are not verified, such that several signatures of a key may be
+included, of which any valid one verifies.  The passed metadata are not
+modified.
+*/
+func MergeSignatures(metadata ...Metadata) (Metadata, error) {
+	if len(metadata) == 0 {
+		return nil, fmt.Errorf("no metadata to merge")
+	}
+
+	switch metadata[0].(type) {
+	case *Envelope:
+		envs := []*Envelope{}
+		for _, m := range metadata {
+			env, ok := m.(*Envelope)
+			if !ok {
+				return nil, ErrMetadataTypeMismatch
+			}
+			envs = append(envs, env)
+		}
+		return mergeEnvelopes(envs)
+
+	case *Metablock:
+		mbs := []*Metablock{}
+		for _, m := range metadata {
+			mb, ok := m.(*Metablock)
+			if !ok {
+				return nil, ErrMetadataTypeMismatch
+			}
+			mbs = append(mbs, mb)
+		}
+		return mergeMetablocks(mbs)
+	}
+
+	return nil, fmt.Errorf("unsupported metadata type %T", metadata[0])
+}
+
+/*
+containsSignature returns true if sigs already contains a signature with the
+key id and signature data of sig.
+*/
+func containsSignature(sigs []Signature, sig Signature) bool {
+	for _, s := range sigs {
+		if s.KeyID == sig.KeyID && s.Sig == sig.Sig {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+mergeMetablocks returns a new Metablock that contains the signed object of the
+first passed metablock and the signatures of all passed metablocks.  Signed
+objects are compared by their canonical JSON representation, which is what the
+signatures are created over.
+*/
+func mergeMetablocks(mbs []*Metablock) (*Metablock, error) {
+	firstPayload, err := mbs[0].GetSignableRepresentation()
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &Metablock{Signed: mbs[0].Signed, Signatures: []Signature{}}
+	for _, mb := range mbs {
+		payload, err := mb.GetSignableRepresentation()
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(payload, firstPayload) {
+			return nil, ErrPayloadMismatch
+		}
+
+		for _, sig := range mb.Signatures {
+			if !containsSignature(merged.Signatures, sig) {
+				merged.Signatures = append(merged.Signatures, sig)
+			}
+		}
+	}
+
+	return merged, nil
+}
+
 /*
 Metablock is a generic container for signable in-toto objects such as Layout
 or Link.  It has two fields, one that contains the signable object and one that
@@ -845,7 +1106,7 @@ func (mb *Metablock) Load(path string) error {
 		return err
 	}
 
-	payload, err := loadPayload(*rawMb["signed"])
+	payload, err := loadMetablockPayload(*rawMb["signed"])
 	if err != nil {
 		return err
 	}
@@ -901,8 +1162,7 @@ the passed Key, the object in Signed cannot be canonicalized, or the Signature
 is invalid.
 */
 func (mb *Metablock) VerifySignature(key Key) error {
-	sig, err := mb.GetSignatureForKeyID(key.KeyID)
-	if err != nil {
+	if _, err := mb.GetSignatureForKeyID(key.KeyID); err != nil {
 		return err
 	}
 
@@ -916,17 +1176,50 @@ func (mb *Metablock) VerifySignature(key Key) error {
 		return err
 	}
 
-	sigBytes, err := hex.DecodeString(sig.Sig)
-	if err != nil {
-		return err
+	// Merged metablocks may carry several signatures of a key, any valid one suffices
+	for _, sig := range mb.Signatures {
+		if sig.KeyID != key.KeyID {
+			continue
+		}
+
+		var sigBytes []byte
+		sigBytes, err = hex.DecodeString(sig.Sig)
+		if err != nil {
+			continue
+		}
+
+		err = verifier.Verify(context.Background(), payload, sigBytes)
+		if err == nil {
+			return nil
+		}
 	}
 
-	err = verifier.Verify(context.Background(), payload, sigBytes)
-	if err != nil {
-		return err
+	return err
+}
+
+/*
+AddTimestamp requests a timestamp token for the signature of the passed key ID
+from the passed Timestamper and stores it in the Timestamp field of the
+signature.  Only signatures with certificates can carry timestamps.
+*/
+func (mb *Metablock) AddTimestamp(keyID string, timestamper Timestamper) error {
+	for i, s := range mb.Signatures {
+		if s.KeyID != keyID {
+			continue
+		}
+
+		if s.Certificate == "" {
+			return fmt.Errorf("signature of key '%s' has no certificate to timestamp", keyID)
+		}
+		token, err := timestamper([]byte(s.Sig))
+		if err != nil {
+			return err
+		}
+		mb.Signatures[i].Timestamp = base64.StdEncoding.EncodeToString(token)
+		return nil
 	}
 
-	return nil
+	return fmt.Errorf("no signature found for key '%s'", keyID)
 }
 
 // GetSignatureForKeyID returns the signature that was created by the provided keyID, if it exists.
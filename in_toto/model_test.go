@@ -424,6 +424,17 @@ func TestValidateLink(t *testing.T) {
 		"'foo.tar.gz', sha256 hash value: invalid hex string: !@#$%" {
 		t.Error("validateLink error - invalid hashes not detected")
 	}
+
+	testLink = Link{
+		Type:       "link",
+		Name:       "test_time",
+		StartedOn:  "2024-01-01T00:00:00Z",
+		FinishedOn: "yesterday",
+	}
+	err = validateLink(testLink)
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid time of link 'test_time'") {
+		t.Error("validateLink error - invalid time not detected")
+	}
 }
 
 func TestValidateLayout(t *testing.T) {
@@ -656,6 +667,30 @@ func TestValidateLayout(t *testing.T) {
 			},
 			"empty field in key: keytype",
 		},
+		"step after unknown step": {
+			Layout{
+				Type:    "layout",
+				Expires: "2020-02-27T18:03:43Z",
+				Steps: []Step{{
+					Type:            "step",
+					After:           []string{"bar"},
+					SupplyChainItem: SupplyChainItem{Name: "foo"},
+				}},
+			},
+			"step 'foo' must be after unknown step 'bar'",
+		},
+		"step after itself": {
+			Layout{
+				Type:    "layout",
+				Expires: "2020-02-27T18:03:43Z",
+				Steps: []Step{{
+					Type:            "step",
+					After:           []string{"foo"},
+					SupplyChainItem: SupplyChainItem{Name: "foo"},
+				}},
+			},
+			"step 'foo' cannot be after itself",
+		},
 	}
 
 	for name, tc := range cases {
@@ -702,6 +737,22 @@ func TestValidateStep(t *testing.T) {
 	if err.Error() != "step name cannot be empty" {
 		t.Error("validateStep error - empty name not detected")
 	}
+
+	for _, maxLinkAge := range []string{"30d", "-1h", "0s"} {
+		testStep = Step{
+			Type:            "step",
+			MaxLinkAge:      maxLinkAge,
+			SupplyChainItem: SupplyChainItem{Name: "foo"},
+		}
+		if err := validateStep(testStep); err == nil {
+			t.Errorf("validateStep error - invalid max link age '%s' not detected", maxLinkAge)
+		}
+	}
+
+	testStep.MaxLinkAge = "720h"
+	if err := validateStep(testStep); err != nil {
+		t.Errorf("validateStep returned '%s' for valid max link age", err)
+	}
 }
 
 func TestValidateInspection(t *testing.T) {
@@ -1623,6 +1674,22 @@ func TestStepCheckCertConstraints(t *testing.T) {
 	step.CertificateConstraints[0].CommonName = "bad common name"
 	err = step.CheckCertConstraints(key, rootCAIDs, rootPool, intermediatePool)
 	assert.NotNil(t, err, "expected error when checking constraint without match")
+
+	// Test that the error holds the result of every constraint
+	step.CertificateConstraints = append(step.CertificateConstraints, CertificateConstraint{
+		CommonName: "*", Organizations: []string{"other"}, Roots: []string{"*"},
+	})
+	err = step.CheckCertConstraints(key, rootCAIDs, rootPool, intermediatePool)
+	var constraintsErr *CertConstraintsError
+	if assert.ErrorAs(t, err, &constraintsErr, "expected constraints error") {
+		assert.Len(t, constraintsErr.Results, 2)
+		assert.Contains(t, err.Error(), "constraint 1: ")
+		assert.Contains(t, err.Error(), "constraint 2: ")
+	}
+
+	results, err := step.EvaluateCertConstraints(key, rootCAIDs, rootPool, intermediatePool, time.Time{})
+	assert.Nil(t, err, "unexpected error when evaluating constraints")
+	assert.Len(t, results, 2)
 }
 
 func TestRootCAIDs(t *testing.T) {
@@ -1752,3 +1819,106 @@ func TestLoadMetadata(t *testing.T) {
 		}
 	}
 }
+
+func TestMergeSignatures(t *testing.T) {
+	var alice, bob Key
+	if err := alice.LoadKeyDefaults("carol"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.LoadKeyDefaults("dan"); err != nil {
+		t.Fatal(err)
+	}
+
+	link := Link{Type: "link", Name: "merge", Materials: map[string]HashObj{}, Products: map[string]HashObj{}}
+	otherLink := Link{Type: "link", Name: "other", Materials: map[string]HashObj{}, Products: map[string]HashObj{}}
+
+	newEnvelope := func(payload any, key Key) *Envelope {
+		env := &Envelope{}
+		if err := env.SetPayload(payload); err != nil {
+			t.Fatal(err)
+		}
+		if err := env.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+		return env
+	}
+	newMetablock := func(payload any, key Key) *Metablock {
+		mb := &Metablock{Signed: payload}
+		if err := mb.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+		return mb
+	}
+
+	t.Run("envelopes", func(t *testing.T) {
+		a := newEnvelope(link, alice)
+		b := newEnvelope(link, bob)
+		merged, err := MergeSignatures(a, b, a)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Len(t, merged.Sigs(), 2)
+		assert.Nil(t, merged.VerifySignature(alice))
+		assert.Nil(t, merged.VerifySignature(bob))
+		assert.Equal(t, link, merged.GetPayload())
+		// The inputs are not modified
+		assert.Len(t, a.Sigs(), 1)
+	})
+
+	t.Run("metablocks", func(t *testing.T) {
+		a := newMetablock(link, alice)
+		b := newMetablock(link, bob)
+		merged, err := MergeSignatures(a, b)
+		if !assert.Nil(t, err) {
+			return
+		}
+
+		assert.Len(t, merged.Sigs(), 2)
+		assert.Nil(t, merged.VerifySignature(alice))
+		assert.Nil(t, merged.VerifySignature(bob))
+		assert.Len(t, a.Sigs(), 1)
+	})
+
+	t.Run("invalid signature merged first", func(t *testing.T) {
+		bad := newMetablock(link, alice)
+		bad.Signatures[0].Sig = strings.Repeat("0", len(bad.Signatures[0].Sig))
+		merged, err := MergeSignatures(bad, newMetablock(link, alice))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Len(t, merged.Sigs(), 2)
+		assert.Nil(t, merged.VerifySignature(alice))
+		assert.NotNil(t, bad.VerifySignature(alice))
+
+		badEnv := newEnvelope(link, alice)
+		badEnv.envelope.Signatures[0].Sig = "AAAA"
+		merged, err = MergeSignatures(badEnv, newEnvelope(link, alice))
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Len(t, merged.Sigs(), 2)
+		assert.Nil(t, merged.VerifySignature(alice))
+	})
+
+	t.Run("payload mismatch", func(t *testing.T) {
+		_, err := MergeSignatures(newEnvelope(link, alice), newEnvelope(otherLink, bob))
+		assert.ErrorIs(t, err, ErrPayloadMismatch)
+
+		_, err = MergeSignatures(newMetablock(link, alice), newMetablock(otherLink, bob))
+		assert.ErrorIs(t, err, ErrPayloadMismatch)
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		_, err := MergeSignatures(newEnvelope(link, alice), newMetablock(link, bob))
+		assert.ErrorIs(t, err, ErrMetadataTypeMismatch)
+
+		_, err = MergeSignatures(newMetablock(link, alice), newEnvelope(link, bob))
+		assert.ErrorIs(t, err, ErrMetadataTypeMismatch)
+	})
+
+	t.Run("no metadata", func(t *testing.T) {
+		_, err := MergeSignatures()
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,202 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	linkv0 "github.com/in-toto/attestation/go/predicates/link/v0"
+	vsa1 "github.com/in-toto/attestation/go/predicates/vsa/v1"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	slsa01 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.1"
+	slsa02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ErrUnknownPredicateType indicates that no decoder is registered for a predicate type
+var ErrUnknownPredicateType = errors.New("unknown predicate type")
+
+// ErrPredicateTypeRegistered indicates that a predicate type already has a decoder
+var ErrPredicateTypeRegistered = errors.New("predicate type is already registered")
+
+// ErrUnknownStatementType indicates that a payload is not an ITE-6 Statement
+var ErrUnknownStatementType = errors.New("unknown statement type")
+
+/*
+PredicateDecoder decodes the JSON encoded predicate of a Statement into a typed
+value.
+*/
+type PredicateDecoder func(predicate []byte) (any, error)
+
+/*
+ParsedStatement is an ITE-6 Statement, whose predicate was decoded by the
+PredicateDecoder registered for its predicate type.
+*/
+type ParsedStatement struct {
+	Type          string
+	Subject       []*ita1.ResourceDescriptor
+	PredicateType string
+	Predicate     any
+}
+
+// protoPredicateOptions ignores unknown fields when decoding protobuf messages.
+var protoPredicateOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+
+var (
+	predicateDecodersMu sync.RWMutex
+	predicateDecoders   = map[string]PredicateDecoder{
+		PredicateLinkV1: func(predicate []byte) (any, error) {
+			var link Link
+			if err := json.Unmarshal(predicate, &link); err != nil {
+				return nil, err
+			}
+			return link, nil
+		},
+		PredicateLinkV03: func(predicate []byte) (any, error) {
+			link := &linkv0.Link{}
+			if err := protoPredicateOptions.Unmarshal(predicate, link); err != nil {
+				return nil, err
+			}
+			return link, nil
+		},
+		PredicateVSAV1: func(predicate []byte) (any, error) {
+			summary := &vsa1.VerificationSummary{}
+			if err := protoPredicateOptions.Unmarshal(predicate, summary); err != nil {
+				return nil, err
+			}
+			return summary, nil
+		},
+		slsa01.PredicateSLSAProvenance: func(predicate []byte) (any, error) {
+			var provenance slsa01.ProvenancePredicate
+			if err := json.Unmarshal(predicate, &provenance); err != nil {
+				return nil, err
+			}
+			return provenance, nil
+		},
+		slsa02.PredicateSLSAProvenance: func(predicate []byte) (any, error) {
+			var provenance slsa02.ProvenancePredicate
+			if err := json.Unmarshal(predicate, &provenance); err != nil {
+				return nil, err
+			}
+			return provenance, nil
+		},
+		slsa1.PredicateSLSAProvenance: func(predicate []byte) (any, error) {
+			var provenance slsa1.ProvenancePredicate
+			if err := json.Unmarshal(predicate, &provenance); err != nil {
+				return nil, err
+			}
+			return provenance, nil
+		},
+		PredicateSPDX: func(predicate []byte) (any, error) {
+			return DecodeSPDXPredicate(predicate)
+		},
+		PredicateCycloneDX: func(predicate []byte) (any, error) {
+			return DecodeCycloneDXPredicate(predicate)
+		},
+	}
+)
+
+/*
+RegisterPredicateType registers a decoder for the passed predicate type, which
+is used by ParseStatement.  Decoders are built in for the link, SLSA
+provenance, SLSA VSA, SPDX and CycloneDX predicates known to this package.
+Registering a predicate type twice results in an ErrPredicateTypeRegistered.
+*/
+func RegisterPredicateType(predicateType string, decoder PredicateDecoder) error {
+	predicateDecodersMu.Lock()
+	defer predicateDecodersMu.Unlock()
+
+	if _, ok := predicateDecoders[predicateType]; ok {
+		return fmt.Errorf("%w: %s", ErrPredicateTypeRegistered, predicateType)
+	}
+	predicateDecoders[predicateType] = decoder
+	return nil
+}
+
+/*
+parseStatementHeader parses an ITE-6 v0.1 or v1 Statement and returns it with
+the still encoded predicate.
+*/
+func parseStatementHeader(payload []byte) (*ParsedStatement, json.RawMessage, error) {
+	var statement struct {
+		Type          string            `json:"_type"`
+		Subject       []json.RawMessage `json:"subject"`
+		PredicateType string            `json:"predicateType"`
+		Predicate     json.RawMessage   `json:"predicate"`
+	}
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return nil, nil, fmt.Errorf("error decoding statement: %w", err)
+	}
+	if statement.Type != StatementInTotoV01 && statement.Type != StatementInTotoV1 {
+		return nil, nil, fmt.Errorf("%w: '%s'", ErrUnknownStatementType, statement.Type)
+	}
+
+	subjects := []*ita1.ResourceDescriptor{}
+	for _, subjectBytes := range statement.Subject {
+		subject := &ita1.ResourceDescriptor{}
+		if err := protoPredicateOptions.Unmarshal(subjectBytes, subject); err != nil {
+			return nil, nil, fmt.Errorf("error decoding statement subject: %w", err)
+		}
+		subjects = append(subjects, subject)
+	}
+
+	return &ParsedStatement{
+		Type:          statement.Type,
+		Subject:       subjects,
+		PredicateType: statement.PredicateType,
+	}, statement.Predicate, nil
+}
+
+// predicateDecoder returns the decoder registered for the passed predicate type.
+func predicateDecoder(predicateType string) (PredicateDecoder, bool) {
+	predicateDecodersMu.RLock()
+	defer predicateDecodersMu.RUnlock()
+	decoder, ok := predicateDecoders[predicateType]
+	return decoder, ok
+}
+
+/*
+decodeStatement parses an ITE-6 v0.1 or v1 Statement, whose predicate is
+decoded if a decoder is registered for the predicate type and the predicate is
+valid for it, and is the JSON encoded json.RawMessage otherwise.  Unlike
+ParseStatement, it is lenient about predicates, such that a single unexpected
+predicate does not prevent loading metadata.
+*/
+func decodeStatement(payload []byte) (*ParsedStatement, error) {
+	statement, predicate, err := parseStatementHeader(payload)
+	if err != nil {
+		return nil, err
+	}
+	statement.Predicate = predicate
+	if decoder, ok := predicateDecoder(statement.PredicateType); ok {
+		if decoded, err := decoder(predicate); err == nil {
+			statement.Predicate = decoded
+		}
+	}
+	return statement, nil
+}
+
+/*
+ParseStatement parses an ITE-6 v0.1 or v1 Statement and decodes its predicate
+with the decoder registered for its predicate type.  Predicate types without
+registered decoder result in an ErrUnknownPredicateType.
+*/
+func ParseStatement(payload []byte) (*ParsedStatement, error) {
+	statement, predicate, err := parseStatementHeader(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, ok := predicateDecoder(statement.PredicateType)
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownPredicateType, statement.PredicateType)
+	}
+
+	if statement.Predicate, err = decoder(predicate); err != nil {
+		return nil, fmt.Errorf("error decoding predicate of type '%s': %w", statement.PredicateType, err)
+	}
+
+	return statement, nil
+}
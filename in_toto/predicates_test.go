@@ -0,0 +1,126 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	linkv0 "github.com/in-toto/attestation/go/predicates/link/v0"
+	vsa1 "github.com/in-toto/attestation/go/predicates/vsa/v1"
+	slsa01 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.1"
+	slsa02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStatement(predicateType string, predicate string) []byte {
+	return []byte(fmt.Sprintf(`{
+		"_type": "https://in-toto.io/Statement/v1",
+		"subject": [{"name": "app", "digest": {"sha256": "%s"}}],
+		"predicateType": "%s",
+		"predicate": %s
+	}`, testAppDigest, predicateType, predicate))
+}
+
+func TestParseStatement(t *testing.T) {
+	tables := []struct {
+		predicateType string
+		predicate     string
+		check         func(t *testing.T, predicate any)
+	}{
+		{PredicateLinkV1, `{"_type": "link", "name": "build"}`, func(t *testing.T, predicate any) {
+			assert.Equal(t, "build", predicate.(Link).Name)
+		}},
+		{PredicateLinkV03, `{"name": "build", "unknown": true}`, func(t *testing.T, predicate any) {
+			assert.Equal(t, "build", predicate.(*linkv0.Link).GetName())
+		}},
+		{PredicateVSAV1, `{"verificationResult": "PASSED"}`, func(t *testing.T, predicate any) {
+			assert.Equal(t, VerificationResultPassed, predicate.(*vsa1.VerificationSummary).GetVerificationResult())
+		}},
+		{slsa01.PredicateSLSAProvenance, `{"builder": {"id": "builder"}}`, func(t *testing.T, predicate any) {
+			assert.Equal(t, "builder", predicate.(slsa01.ProvenancePredicate).Builder.ID)
+		}},
+		{slsa02.PredicateSLSAProvenance, `{"builder": {"id": "builder"}}`, func(t *testing.T, predicate any) {
+			assert.Equal(t, "builder", predicate.(slsa02.ProvenancePredicate).Builder.ID)
+		}},
+		{slsa1.PredicateSLSAProvenance, `{"runDetails": {"builder": {"id": "builder"}}}`, func(t *testing.T, predicate any) {
+			assert.Equal(t, "builder", predicate.(slsa1.ProvenancePredicate).RunDetails.Builder.ID)
+		}},
+		{PredicateSPDX, testSPDXDocument, func(t *testing.T, predicate any) {
+			assert.Len(t, predicate.(SPDXDocument).Packages, 2)
+		}},
+		{PredicateCycloneDX, testCycloneDXBOM, func(t *testing.T, predicate any) {
+			assert.Len(t, predicate.(CycloneDXBOM).Components, 1)
+		}},
+	}
+
+	for _, table := range tables {
+		t.Run(table.predicateType, func(t *testing.T) {
+			statement, err := ParseStatement(testStatement(table.predicateType, table.predicate))
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, StatementInTotoV1, statement.Type)
+			assert.Equal(t, table.predicateType, statement.PredicateType)
+			if assert.Len(t, statement.Subject, 1) {
+				assert.Equal(t, "app", statement.Subject[0].GetName())
+				assert.Equal(t, testAppDigest, statement.Subject[0].GetDigest()["sha256"])
+			}
+			table.check(t, statement.Predicate)
+		})
+	}
+}
+
+func TestParseStatementErrors(t *testing.T) {
+	tables := []struct {
+		name    string
+		payload []byte
+		err     error
+	}{
+		{"unknown predicate type", testStatement("https://example.com/unknown", `{}`), ErrUnknownPredicateType},
+		{"unknown statement type", []byte(`{"_type": "link"}`), ErrUnknownStatementType},
+		{"invalid predicate", testStatement(PredicateSPDX, `{"spdxVersion": "SPDX-3.0"}`), ErrUnsupportedSBOM},
+		{"invalid json", []byte(`{`), nil},
+	}
+	for _, table := range tables {
+		_, err := ParseStatement(table.payload)
+		assert.NotNil(t, err, table.name)
+		if table.err != nil {
+			assert.ErrorIs(t, err, table.err, table.name)
+		}
+	}
+}
+
+func TestRegisterPredicateType(t *testing.T) {
+	type testPredicate struct {
+		Result string `json:"result"`
+	}
+	predicateType := "https://example.com/test-result/v1"
+	decoder := func(predicate []byte) (any, error) {
+		var decoded testPredicate
+		if err := json.Unmarshal(predicate, &decoded); err != nil {
+			return nil, err
+		}
+		if decoded.Result == "" {
+			return nil, errors.New("missing result")
+		}
+		return decoded, nil
+	}
+
+	assert.Nil(t, RegisterPredicateType(predicateType, decoder))
+	defer func() {
+		predicateDecodersMu.Lock()
+		delete(predicateDecoders, predicateType)
+		predicateDecodersMu.Unlock()
+	}()
+	assert.ErrorIs(t, RegisterPredicateType(predicateType, decoder), ErrPredicateTypeRegistered)
+	assert.ErrorIs(t, RegisterPredicateType(PredicateSPDX, decoder), ErrPredicateTypeRegistered)
+
+	statement, err := ParseStatement(testStatement(predicateType, `{"result": "PASSED"}`))
+	if assert.Nil(t, err) {
+		assert.Equal(t, testPredicate{Result: "PASSED"}, statement.Predicate)
+	}
+	_, err = ParseStatement(testStatement(predicateType, `{}`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,205 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	slsa01 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.1"
+	slsa02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+)
+
+// ErrMissingBuilderID indicates that provenance was requested without builder ID
+var ErrMissingBuilderID = errors.New("provenance requires a builder id")
+
+// ErrMissingBuildType indicates that provenance was requested without build type
+var ErrMissingBuildType = errors.New("provenance requires a build type")
+
+// ErrNoProvenanceSubjects indicates that provenance was requested for a link without products
+var ErrNoProvenanceSubjects = errors.New("provenance requires at least one product as subject")
+
+/*
+ProvenanceOptions configures the SLSA v1 provenance created from a Link.  The
+BuilderID identifies the entity that ran the step and the BuildType the
+template, i.e. a URI, according to which the step was run.  Both are required.
+StartedOn and FinishedOn are optional timestamps of the step.
+*/
+type ProvenanceOptions struct {
+	BuilderID  string
+	BuildType  string
+	StartedOn  *time.Time
+	FinishedOn *time.Time
+}
+
+// sortedArtifactPaths returns the paths of the passed artifacts in order.
+func sortedArtifactPaths(artifacts map[string]HashObj) []string {
+	paths := make([]string, 0, len(artifacts))
+	for path := range artifacts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+/*
+LinkToProvenanceV1 creates an ITE-6 v1 Statement with a SLSA v1 provenance
+predicate from the passed Link.  The products of the link are the subjects of
+the Statement, its materials are the resolved dependencies and its command is
+the only external parameter of the build.  As Statements require at least one
+subject, links without products result in an ErrNoProvenanceSubjects.
+*/
+func LinkToProvenanceV1(link Link, opts ProvenanceOptions) (ProvenanceStatementSLSA1, error) {
+	if opts.BuilderID == "" {
+		return ProvenanceStatementSLSA1{}, ErrMissingBuilderID
+	}
+	if opts.BuildType == "" {
+		return ProvenanceStatementSLSA1{}, ErrMissingBuildType
+	}
+	if len(link.Products) == 0 {
+		return ProvenanceStatementSLSA1{}, fmt.Errorf("%w: link '%s'", ErrNoProvenanceSubjects, link.Name)
+	}
+
+	subjects := []Subject{}
+	for _, path := range sortedArtifactPaths(link.Products) {
+		subjects = append(subjects, Subject{Name: path, Digest: common.DigestSet(link.Products[path])})
+	}
+
+	dependencies := []slsa1.ResourceDescriptor{}
+	for _, path := range sortedArtifactPaths(link.Materials) {
+		dependencies = append(dependencies, slsa1.ResourceDescriptor{Name: path, Digest: common.DigestSet(link.Materials[path])})
+	}
+
+	command := link.Command
+	if command == nil {
+		command = []string{}
+	}
+
+	return ProvenanceStatementSLSA1{
+		StatementHeader: StatementHeader{
+			Type:          StatementInTotoV1,
+			PredicateType: slsa1.PredicateSLSAProvenance,
+			Subject:       subjects,
+		},
+		Predicate: slsa1.ProvenancePredicate{
+			BuildDefinition: slsa1.ProvenanceBuildDefinition{
+				BuildType:            opts.BuildType,
+				ExternalParameters:   map[string]any{"command": command},
+				ResolvedDependencies: dependencies,
+			},
+			RunDetails: slsa1.ProvenanceRunDetails{
+				Builder: slsa1.Builder{ID: opts.BuilderID},
+				BuildMetadata: slsa1.BuildMetadata{
+					StartedOn:  opts.StartedOn,
+					FinishedOn: opts.FinishedOn,
+				},
+			},
+		},
+	}, nil
+}
+
+/*
+NewProvenanceEnvelope creates a DSSE envelope with the SLSA v1 provenance
+Statement created from the passed Link by LinkToProvenanceV1, and signs it
+with the passed key, unless the key is the zero value.
+*/
+func NewProvenanceEnvelope(link Link, opts ProvenanceOptions, key Key) (*Envelope, error) {
+	statement, err := LinkToProvenanceV1(link, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStatementEnvelope(statement, key)
+}
+
+// ErrUnsupportedProvenanceConversion indicates an unsupported conversion
+var ErrUnsupportedProvenanceConversion = errors.New("unsupported provenance conversion")
+
+/*
+ConvertProvenance converts the SLSA provenance in the passed DSSE envelope to
+the passed SLSA predicate type.  SLSA v0.1 provenance can be converted to v0.2
+and v1, v0.2 to v1, and v1 to v0.2.  The subjects are kept, and so is the
+Statement type, except for conversions to v1, which always result in an ITE-6
+v1 Statement, as required by SLSA v1.
+As the signatures of the passed envelope are not valid for the converted
+provenance, the returned envelope is signed with the passed key, unless the
+key is the zero value.  The fields of the passed provenance, which could not be
+mapped, are returned in JSON notation.  For conversions from v0.1 to v1 they
+are named as in the intermediate v0.2 predicate.
+*/
+func ConvertProvenance(env *Envelope, predicateType string, key Key) (*Envelope, []string, error) {
+	payloadBytes, err := env.envelope.DecodeB64Payload()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var statement struct {
+		Type          string          `json:"_type"`
+		Subject       json.RawMessage `json:"subject"`
+		PredicateType string          `json:"predicateType"`
+		Predicate     json.RawMessage `json:"predicate"`
+	}
+	if err := json.Unmarshal(payloadBytes, &statement); err != nil {
+		return nil, nil, fmt.Errorf("error decoding statement: %w", err)
+	}
+
+	var predicate any
+	unmapped := []string{}
+	switch statement.PredicateType {
+	case slsa01.PredicateSLSAProvenance:
+		var v01Predicate slsa01.ProvenancePredicate
+		if err := json.Unmarshal(statement.Predicate, &v01Predicate); err != nil {
+			return nil, nil, fmt.Errorf("error decoding provenance predicate: %w", err)
+		}
+		v02Predicate, v02Unmapped := slsa02.FromV01(v01Predicate)
+		unmapped = append(unmapped, v02Unmapped...)
+		switch predicateType {
+		case slsa02.PredicateSLSAProvenance:
+			predicate = v02Predicate
+		case slsa1.PredicateSLSAProvenance:
+			v1Predicate, v1Unmapped := slsa1.FromV02(v02Predicate)
+			unmapped = append(unmapped, v1Unmapped...)
+			predicate = v1Predicate
+		}
+
+	case slsa02.PredicateSLSAProvenance:
+		if predicateType == slsa1.PredicateSLSAProvenance {
+			var v02Predicate slsa02.ProvenancePredicate
+			if err := json.Unmarshal(statement.Predicate, &v02Predicate); err != nil {
+				return nil, nil, fmt.Errorf("error decoding provenance predicate: %w", err)
+			}
+			predicate, unmapped = slsa1.FromV02(v02Predicate)
+		}
+
+	case slsa1.PredicateSLSAProvenance:
+		if predicateType == slsa02.PredicateSLSAProvenance {
+			var v1Predicate slsa1.ProvenancePredicate
+			if err := json.Unmarshal(statement.Predicate, &v1Predicate); err != nil {
+				return nil, nil, fmt.Errorf("error decoding provenance predicate: %w", err)
+			}
+			predicate, unmapped = slsa1.ToV02(v1Predicate)
+		}
+	}
+
+	if predicate == nil {
+		return nil, nil, fmt.Errorf("%w: from '%s' to '%s'", ErrUnsupportedProvenanceConversion, statement.PredicateType, predicateType)
+	}
+	if predicateType == slsa1.PredicateSLSAProvenance {
+		statement.Type = StatementInTotoV1
+	}
+
+	converted, err := newStatementEnvelope(map[string]any{
+		"_type":         statement.Type,
+		"subject":       statement.Subject,
+		"predicateType": predicateType,
+		"predicate":     predicate,
+	}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return converted, unmapped, nil
+}
@@ -0,0 +1,275 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	slsa01 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.1"
+	slsa02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+)
+
+// ErrNotProvenance indicates that a Statement does not carry SLSA provenance
+var ErrNotProvenance = errors.New("statement is not slsa provenance")
+
+// ErrProvenancePolicy indicates that provenance does not satisfy a policy
+var ErrProvenancePolicy = errors.New("provenance does not satisfy policy")
+
+/*
+SourceAnnotation is the annotation of resolved dependencies of SLSA v1
+provenance, which marks the source of the build when it is set to true.
+*/
+const SourceAnnotation = "source"
+
+/*
+ProvenancePolicy lists the expectations that SLSA provenance must meet to pass
+VerifyProvenance.  Empty fields are not checked.
+
+BuilderIDs and BuildTypes list the accepted builder IDs and build types.
+SourceRepository and SourceRef are compared with the source of the build,
+which is a URI of the form '[git+]<repository>[@<ref>]'.  For SLSA v1, where
+the source is one of the resolved dependencies, the dependencies annotated
+with SourceAnnotation are the candidates for the source, or all dependencies
+if none is annotated, and one of them must match.
+AllowedExternalParameters lists the names of the external parameters a build
+may have been invoked with.  If it is nil, any parameters are allowed.
+ArtifactDigest is the digest of an artifact, which must be a subject of the
+provenance.
+*/
+type ProvenancePolicy struct {
+	BuilderIDs                []string
+	BuildTypes                []string
+	SourceRepository          string
+	SourceRef                 string
+	AllowedExternalParameters []string
+	ArtifactDigest            HashObj
+}
+
+/*
+provenanceClaims are the parts of SLSA provenance checked by a
+ProvenancePolicy, independent of the predicate version.
+*/
+type provenanceClaims struct {
+	builderID  string
+	buildType  string
+	sources    []string
+	parameters any
+	subjects   []statementResource
+}
+
+/*
+loadProvenanceClaims decodes an ITE-6 Statement with a SLSA v0.1, v0.2 or v1
+provenance predicate.  The source of the build is the material the recipe is
+defined in for v0.1, the config source for v0.2, and for v1 any resolved
+dependency annotated as source, or any resolved dependency if none is
+annotated.  The external parameters are the recipe arguments for v0.1
+and the invocation parameters for v0.2.
+*/
+func loadProvenanceClaims(payloadBytes []byte) (provenanceClaims, error) {
+	var statement linkStatement
+	if err := json.Unmarshal(payloadBytes, &statement); err != nil {
+		return provenanceClaims{}, fmt.Errorf("error decoding statement: %w", err)
+	}
+	if statement.Type != StatementInTotoV01 && statement.Type != StatementInTotoV1 {
+		return provenanceClaims{}, fmt.Errorf("%w: unknown statement type '%s'", ErrNotProvenance, statement.Type)
+	}
+
+	claims := provenanceClaims{subjects: statement.Subject}
+	switch statement.PredicateType {
+	case slsa01.PredicateSLSAProvenance:
+		var predicate slsa01.ProvenancePredicate
+		if err := json.Unmarshal(statement.Predicate, &predicate); err != nil {
+			return provenanceClaims{}, fmt.Errorf("error decoding provenance predicate: %w", err)
+		}
+		claims.builderID = predicate.Builder.ID
+		claims.buildType = predicate.Recipe.Type
+		claims.parameters = predicate.Recipe.Arguments
+		index := predicate.Recipe.DefinedInMaterial
+		if index != nil && *index >= 0 && *index < len(predicate.Materials) {
+			claims.sources = []string{predicate.Materials[*index].URI}
+		}
+
+	case slsa02.PredicateSLSAProvenance:
+		var predicate slsa02.ProvenancePredicate
+		if err := json.Unmarshal(statement.Predicate, &predicate); err != nil {
+			return provenanceClaims{}, fmt.Errorf("error decoding provenance predicate: %w", err)
+		}
+		claims.builderID = predicate.Builder.ID
+		claims.buildType = predicate.BuildType
+		claims.parameters = predicate.Invocation.Parameters
+		if predicate.Invocation.ConfigSource.URI != "" {
+			claims.sources = []string{predicate.Invocation.ConfigSource.URI}
+		}
+
+	case slsa1.PredicateSLSAProvenance:
+		var predicate slsa1.ProvenancePredicate
+		if err := json.Unmarshal(statement.Predicate, &predicate); err != nil {
+			return provenanceClaims{}, fmt.Errorf("error decoding provenance predicate: %w", err)
+		}
+		claims.builderID = predicate.RunDetails.Builder.ID
+		claims.buildType = predicate.BuildDefinition.BuildType
+		claims.parameters = predicate.BuildDefinition.ExternalParameters
+		claims.sources = sourceDependencyURIs(predicate.BuildDefinition.ResolvedDependencies)
+
+	default:
+		return provenanceClaims{}, fmt.Errorf("%w: unknown predicate type '%s'", ErrNotProvenance, statement.PredicateType)
+	}
+
+	return claims, nil
+}
+
+/*
+sourceDependencyURIs returns the URIs of the passed resolved dependencies that
+are annotated as source with SourceAnnotation, or the URIs of all passed
+dependencies if none is annotated.
+*/
+func sourceDependencyURIs(dependencies []slsa1.ResourceDescriptor) []string {
+	annotated := []string{}
+	all := []string{}
+	for _, dependency := range dependencies {
+		if dependency.URI == "" {
+			continue
+		}
+		all = append(all, dependency.URI)
+		if isSource, _ := dependency.Annotations[SourceAnnotation].(bool); isSource {
+			annotated = append(annotated, dependency.URI)
+		}
+	}
+	if len(annotated) > 0 {
+		return annotated
+	}
+	return all
+}
+
+/*
+splitSourceURI splits a source URI of the form '[git+]<repository>[@<ref>]'
+into repository and ref.  An '@' in the authority of the URI, i.e. as
+separator of user information, is not treated as ref separator.
+*/
+func splitSourceURI(uri string) (string, string) {
+	uri = strings.TrimPrefix(uri, "git+")
+	start := 0
+	if i := strings.Index(uri, "://"); i >= 0 {
+		start = i + len("://")
+		if j := strings.Index(uri[start:], "/"); j >= 0 {
+			start += j
+		}
+	}
+	if i := strings.LastIndex(uri[start:], "@"); i >= 0 {
+		return uri[:start+i], uri[start+i+1:]
+	}
+	return uri, ""
+}
+
+/*
+checkProvenanceClaims checks the passed provenance claims against the passed
+policy and returns an ErrProvenancePolicy, with the first unmet expectation,
+if the policy is not satisfied.
+*/
+func checkProvenanceClaims(claims provenanceClaims, policy ProvenancePolicy) error {
+	contains := func(values []string, value string) bool {
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(policy.BuilderIDs) > 0 && !contains(policy.BuilderIDs, claims.builderID) {
+		return fmt.Errorf("%w: builder id '%s' is not one of %v", ErrProvenancePolicy, claims.builderID, policy.BuilderIDs)
+	}
+	if len(policy.BuildTypes) > 0 && !contains(policy.BuildTypes, claims.buildType) {
+		return fmt.Errorf("%w: build type '%s' is not one of %v", ErrProvenancePolicy, claims.buildType, policy.BuildTypes)
+	}
+
+	if policy.SourceRepository != "" || policy.SourceRef != "" {
+		matched := false
+		for _, source := range claims.sources {
+			repository, ref := splitSourceURI(source)
+			if policy.SourceRepository != "" && repository != strings.TrimPrefix(policy.SourceRepository, "git+") {
+				continue
+			}
+			if policy.SourceRef != "" && ref != policy.SourceRef {
+				continue
+			}
+			matched = true
+			break
+		}
+		if !matched {
+			return fmt.Errorf("%w: no source of %q matches repository '%s' and ref '%s'", ErrProvenancePolicy,
+				claims.sources, policy.SourceRepository, policy.SourceRef)
+		}
+	}
+
+	if policy.AllowedExternalParameters != nil && claims.parameters != nil {
+		parameters, ok := claims.parameters.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: external parameters must be an object", ErrProvenancePolicy)
+		}
+		names := make([]string, 0, len(parameters))
+		for name := range parameters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if !contains(policy.AllowedExternalParameters, name) {
+				return fmt.Errorf("%w: external parameter '%s' is not allowed", ErrProvenancePolicy, name)
+			}
+		}
+	}
+
+	if len(policy.ArtifactDigest) > 0 {
+		matched := false
+		for _, subject := range claims.subjects {
+			if matchesDigest(policy.ArtifactDigest, subject.Digest) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%w: no subject matches artifact digest %v", ErrProvenancePolicy, policy.ArtifactDigest)
+		}
+	}
+
+	return nil
+}
+
+/*
+VerifyProvenance verifies a DSSE envelope with SLSA v0.1, v0.2 or v1
+provenance.  The envelope must carry a valid signature for at least one of the
+passed keys, and the provenance must satisfy the passed policy.
+*/
+func VerifyProvenance(env *Envelope, keys map[string]Key, policy ProvenancePolicy) error {
+	if len(keys) < 1 {
+		return fmt.Errorf("provenance verification requires at least one key")
+	}
+
+	verified := false
+	var verifyErr error
+	for _, key := range keys {
+		if err := env.VerifySignature(key); err != nil {
+			verifyErr = err
+			continue
+		}
+		verified = true
+		break
+	}
+	if !verified {
+		return fmt.Errorf("provenance is not signed by any of the passed keys: %w", verifyErr)
+	}
+
+	payloadBytes, err := env.envelope.DecodeB64Payload()
+	if err != nil {
+		return err
+	}
+	claims, err := loadProvenanceClaims(payloadBytes)
+	if err != nil {
+		return err
+	}
+
+	return checkProvenanceClaims(claims, policy)
+}
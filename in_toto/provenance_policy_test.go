@@ -0,0 +1,127 @@
+package in_toto
+
+import (
+	"testing"
+
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyProvenance(t *testing.T) {
+	var key, pub, otherPub Key
+	assert.Nil(t, key.LoadKeyDefaults("carol"))
+	assert.Nil(t, pub.LoadKeyDefaults("carol.pub"))
+	assert.Nil(t, otherPub.LoadKeyDefaults("dan.pub"))
+
+	source := "git+https://example.com/repo@refs/heads/main"
+	subject := []map[string]any{{"name": "app", "digest": map[string]string{"sha256": testAppDigest}}}
+	predicates := []struct {
+		predicateType string
+		predicate     map[string]any
+	}{
+		{"https://slsa.dev/provenance/v0.1", map[string]any{
+			"builder":   map[string]any{"id": "https://example.com/builder"},
+			"recipe":    map[string]any{"type": "https://example.com/type", "definedInMaterial": 0, "arguments": map[string]any{"target": "app"}},
+			"materials": []map[string]any{{"uri": source}},
+		}},
+		{"https://slsa.dev/provenance/v0.2", map[string]any{
+			"builder":    map[string]any{"id": "https://example.com/builder"},
+			"buildType":  "https://example.com/type",
+			"invocation": map[string]any{"configSource": map[string]any{"uri": source}, "parameters": map[string]any{"target": "app"}},
+		}},
+		{"https://slsa.dev/provenance/v1", map[string]any{
+			"buildDefinition": map[string]any{
+				"buildType":            "https://example.com/type",
+				"externalParameters":   map[string]any{"target": "app"},
+				"resolvedDependencies": []map[string]any{{"uri": "https://example.com/dependency@v1"}, {"uri": source}},
+			},
+			"runDetails": map[string]any{"builder": map[string]any{"id": "https://example.com/builder"}},
+		}},
+	}
+
+	policy := ProvenancePolicy{
+		BuilderIDs:                []string{"https://example.com/other", "https://example.com/builder"},
+		BuildTypes:                []string{"https://example.com/type"},
+		SourceRepository:          "git+https://example.com/repo",
+		SourceRef:                 "refs/heads/main",
+		AllowedExternalParameters: []string{"target"},
+		ArtifactDigest:            HashObj{"sha256": testAppDigest},
+	}
+
+	for _, p := range predicates {
+		env := &Envelope{}
+		assert.Nil(t, env.SetPayload(map[string]any{
+			"_type":         StatementInTotoV1,
+			"subject":       subject,
+			"predicateType": p.predicateType,
+			"predicate":     p.predicate,
+		}))
+		assert.Nil(t, env.Sign(key))
+
+		assert.Nil(t, VerifyProvenance(env, map[string]Key{pub.KeyID: pub}, policy), p.predicateType)
+		assert.Nil(t, VerifyProvenance(env, map[string]Key{pub.KeyID: pub}, ProvenancePolicy{}), p.predicateType)
+
+		violations := []ProvenancePolicy{
+			{BuilderIDs: []string{"https://example.com/other"}},
+			{BuildTypes: []string{"https://example.com/other"}},
+			{SourceRepository: "https://example.com/other"},
+			{SourceRef: "refs/heads/dev"},
+			{AllowedExternalParameters: []string{}},
+			{ArtifactDigest: HashObj{"sha256": testSourceDigest}},
+			{ArtifactDigest: HashObj{"sha512": testAppDigest}},
+		}
+		for _, violation := range violations {
+			err := VerifyProvenance(env, map[string]Key{pub.KeyID: pub}, violation)
+			assert.ErrorIs(t, err, ErrProvenancePolicy, p.predicateType)
+		}
+
+		assert.NotNil(t, VerifyProvenance(env, map[string]Key{otherPub.KeyID: otherPub}, policy), p.predicateType)
+		assert.NotNil(t, VerifyProvenance(env, map[string]Key{}, policy), p.predicateType)
+	}
+
+	env := &Envelope{}
+	assert.Nil(t, env.SetPayload(map[string]any{
+		"_type":         StatementInTotoV1,
+		"subject":       subject,
+		"predicateType": PredicateLinkV03,
+		"predicate":     map[string]any{"name": "build"},
+	}))
+	assert.Nil(t, env.Sign(key))
+	assert.ErrorIs(t, VerifyProvenance(env, map[string]Key{pub.KeyID: pub}, policy), ErrNotProvenance)
+}
+
+func TestSourceDependencyURIs(t *testing.T) {
+	tool := slsa1.ResourceDescriptor{URI: "https://example.com/tool@v1"}
+	source := slsa1.ResourceDescriptor{URI: "git+https://example.com/repo@refs/heads/main",
+		Annotations: map[string]interface{}{SourceAnnotation: true}}
+	unannotated := slsa1.ResourceDescriptor{URI: "git+https://example.com/repo@refs/heads/main"}
+
+	assert.Equal(t, []string{source.URI}, sourceDependencyURIs([]slsa1.ResourceDescriptor{tool, source}))
+	assert.Equal(t, []string{tool.URI, unannotated.URI}, sourceDependencyURIs([]slsa1.ResourceDescriptor{tool, unannotated}))
+	assert.Empty(t, sourceDependencyURIs(nil))
+
+	// A dependency that is not annotated as source does not match, if another one is
+	claims := provenanceClaims{sources: sourceDependencyURIs([]slsa1.ResourceDescriptor{
+		{URI: "git+https://example.com/repo@refs/heads/dev", Annotations: map[string]interface{}{SourceAnnotation: true}},
+		unannotated,
+	})}
+	err := checkProvenanceClaims(claims, ProvenancePolicy{SourceRepository: "https://example.com/repo", SourceRef: "refs/heads/main"})
+	assert.ErrorIs(t, err, ErrProvenancePolicy)
+}
+
+func TestSplitSourceURI(t *testing.T) {
+	tables := []struct {
+		uri, repository, ref string
+	}{
+		{"git+https://example.com/repo@refs/heads/main", "https://example.com/repo", "refs/heads/main"},
+		{"https://example.com/repo", "https://example.com/repo", ""},
+		{"git+ssh://git@example.com/repo@v1.0", "ssh://git@example.com/repo", "v1.0"},
+		{"ssh://git@example.com/repo", "ssh://git@example.com/repo", ""},
+		{"", "", ""},
+	}
+	for _, table := range tables {
+		repository, ref := splitSourceURI(table.uri)
+		assert.Equal(t, table.repository, repository, table.uri)
+		assert.Equal(t, table.ref, ref, table.uri)
+	}
+}
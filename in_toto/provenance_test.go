@@ -0,0 +1,208 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkToProvenanceV1(t *testing.T) {
+	startedOn := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	finishedOn := startedOn.Add(time.Minute)
+	opts := ProvenanceOptions{
+		BuilderID:  "https://example.com/builder",
+		BuildType:  "https://example.com/buildtype/v1",
+		StartedOn:  &startedOn,
+		FinishedOn: &finishedOn,
+	}
+	link := Link{
+		Type:      "link",
+		Name:      "build",
+		Materials: map[string]HashObj{"src": {"sha256": testSourceDigest}},
+		Products:  map[string]HashObj{"b": {"sha256": testAppDigest}, "a": {"sha256": testSourceDigest}},
+		Command:   []string{"make", "all"},
+	}
+
+	statement, err := LinkToProvenanceV1(link, opts)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, StatementInTotoV1, statement.Type)
+	assert.Equal(t, slsa1.PredicateSLSAProvenance, statement.PredicateType)
+	assert.Equal(t, []Subject{
+		{Name: "a", Digest: map[string]string{"sha256": testSourceDigest}},
+		{Name: "b", Digest: map[string]string{"sha256": testAppDigest}},
+	}, statement.Subject)
+	assert.Equal(t, opts.BuildType, statement.Predicate.BuildDefinition.BuildType)
+	assert.Equal(t, map[string]any{"command": []string{"make", "all"}}, statement.Predicate.BuildDefinition.ExternalParameters)
+	assert.Equal(t, []slsa1.ResourceDescriptor{{Name: "src", Digest: map[string]string{"sha256": testSourceDigest}}},
+		statement.Predicate.BuildDefinition.ResolvedDependencies)
+	assert.Equal(t, opts.BuilderID, statement.Predicate.RunDetails.Builder.ID)
+	assert.Equal(t, &startedOn, statement.Predicate.RunDetails.BuildMetadata.StartedOn)
+	assert.Equal(t, &finishedOn, statement.Predicate.RunDetails.BuildMetadata.FinishedOn)
+
+	_, err = LinkToProvenanceV1(link, ProvenanceOptions{BuildType: opts.BuildType})
+	assert.ErrorIs(t, err, ErrMissingBuilderID)
+	_, err = LinkToProvenanceV1(link, ProvenanceOptions{BuilderID: opts.BuilderID})
+	assert.ErrorIs(t, err, ErrMissingBuildType)
+
+	link.Products = map[string]HashObj{}
+	_, err = LinkToProvenanceV1(link, opts)
+	assert.ErrorIs(t, err, ErrNoProvenanceSubjects)
+}
+
+func TestNewProvenanceEnvelope(t *testing.T) {
+	var key, pub Key
+	assert.Nil(t, key.LoadKeyDefaults("carol"))
+	assert.Nil(t, pub.LoadKeyDefaults("carol.pub"))
+
+	link := Link{
+		Type:      "link",
+		Name:      "build",
+		Materials: map[string]HashObj{"src": {"sha256": testSourceDigest}},
+		Products:  map[string]HashObj{"app": {"sha256": testAppDigest}},
+	}
+	env, err := NewProvenanceEnvelope(link, ProvenanceOptions{BuilderID: "builder", BuildType: "type"}, key)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// The provenance can be used as link for the step
+	path := filepath.Join(t.TempDir(), "build.link")
+	assert.Nil(t, env.Dump(path))
+	loaded, err := LoadMetadata(path)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, loaded.VerifySignature(pub))
+	loadedLink, ok := loaded.GetPayload().(Link)
+	assert.True(t, ok, "payload must be link")
+	assert.Equal(t, link.Materials, loadedLink.Materials)
+	assert.Equal(t, link.Products, loadedLink.Products)
+}
+
+func TestConvertProvenance(t *testing.T) {
+	var key, pub Key
+	assert.Nil(t, key.LoadKeyDefaults("carol"))
+	assert.Nil(t, pub.LoadKeyDefaults("carol.pub"))
+
+	env := &Envelope{}
+	assert.Nil(t, env.SetPayload(map[string]any{
+		"_type":         StatementInTotoV01,
+		"subject":       []map[string]any{{"name": "app", "digest": map[string]string{"sha256": testAppDigest}}},
+		"predicateType": "https://slsa.dev/provenance/v0.1",
+		"predicate": map[string]any{
+			"builder":   map[string]any{"id": "https://example.com/builder"},
+			"recipe":    map[string]any{"type": "https://example.com/type", "definedInMaterial": 0, "arguments": map[string]any{"target": "app"}},
+			"metadata":  map[string]any{"reproducible": true},
+			"materials": []map[string]any{{"uri": "git+https://example.com/repo@refs/heads/main"}},
+		},
+	}))
+
+	policy := ProvenancePolicy{
+		BuilderIDs:       []string{"https://example.com/builder"},
+		BuildTypes:       []string{"https://example.com/type"},
+		SourceRepository: "https://example.com/repo",
+		SourceRef:        "refs/heads/main",
+		ArtifactDigest:   HashObj{"sha256": testAppDigest},
+	}
+
+	statementType := func(env *Envelope) string {
+		var header StatementHeader
+		payload, err := env.envelope.DecodeB64Payload()
+		assert.Nil(t, err)
+		assert.Nil(t, json.Unmarshal(payload, &header))
+		return header.Type
+	}
+
+	v1Env, unmapped, err := ConvertProvenance(env, slsa1.PredicateSLSAProvenance, key)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"metadata.reproducible"}, unmapped)
+	assert.Equal(t, StatementInTotoV1, statementType(v1Env))
+	assert.Nil(t, VerifyProvenance(v1Env, map[string]Key{pub.KeyID: pub}, policy))
+
+	v02Env, unmapped, err := ConvertProvenance(v1Env, "https://slsa.dev/provenance/v0.2", key)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Empty(t, unmapped)
+	assert.Equal(t, StatementInTotoV1, statementType(v02Env))
+	assert.Nil(t, VerifyProvenance(v02Env, map[string]Key{pub.KeyID: pub}, policy))
+
+	// v0.2 provenance in a v0.1 Statement results in a v1 Statement
+	v02Env, _, err = ConvertProvenance(env, "https://slsa.dev/provenance/v0.2", key)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, StatementInTotoV01, statementType(v02Env))
+	v1Env, _, err = ConvertProvenance(v02Env, slsa1.PredicateSLSAProvenance, key)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, StatementInTotoV1, statementType(v1Env))
+
+	_, _, err = ConvertProvenance(v1Env, "https://slsa.dev/provenance/v0.1", key)
+	assert.ErrorIs(t, err, ErrUnsupportedProvenanceConversion)
+}
+
+func TestInTotoRunWithProvenance(t *testing.T) {
+	var key Key
+	assert.Nil(t, key.LoadKeyDefaults("carol"))
+
+	metadata, provenance, err := InTotoRunWithProvenance("build", "", nil, nil, nil, key, []string{"sha256"}, nil, nil, RunOptions{})
+	assert.Nil(t, err)
+	assert.NotNil(t, metadata)
+	assert.Nil(t, provenance, "expected no provenance without options")
+
+	metadata, provenance, err = InTotoRunWithProvenance("build", "", nil, []string{"foo.tar.gz"}, nil, key, []string{"sha256"}, nil, nil, RunOptions{
+		RecordTimes: true,
+		Provenance:  &ProvenanceOptions{BuilderID: "builder", BuildType: "type"},
+	})
+	if !assert.Nil(t, err) || !assert.NotNil(t, provenance) {
+		return
+	}
+	assert.Nil(t, provenance.VerifySignature(key))
+
+	decodeStatement := func(env *Envelope) ProvenanceStatementSLSA1 {
+		var statement ProvenanceStatementSLSA1
+		payload, err := env.envelope.DecodeB64Payload()
+		assert.Nil(t, err)
+		assert.Nil(t, json.Unmarshal(payload, &statement))
+		return statement
+	}
+
+	link := metadata.GetPayload().(Link)
+	statement := decodeStatement(provenance)
+	buildMetadata := statement.Predicate.RunDetails.BuildMetadata
+	assert.Equal(t, link.StartedOn, buildMetadata.StartedOn.Format(ISO8601DateSchema))
+	assert.Equal(t, link.FinishedOn, buildMetadata.FinishedOn.Format(ISO8601DateSchema))
+
+	// The start time of record stop provenance is taken from the start link
+	prelim, err := InTotoRecordStartWithOptions("build", nil, key, []string{"sha256"}, nil, nil, RunOptions{RecordTimes: true})
+	if !assert.Nil(t, err) {
+		return
+	}
+	metadata, provenance, err = InTotoRecordStopWithProvenance(prelim, []string{"foo.tar.gz"}, key, []string{"sha256"}, nil, nil, RunOptions{
+		RecordTimes: true,
+		Provenance:  &ProvenanceOptions{BuilderID: "builder", BuildType: "type"},
+	})
+	if !assert.Nil(t, err) || !assert.NotNil(t, provenance) {
+		return
+	}
+	statement = decodeStatement(provenance)
+	assert.Equal(t, prelim.GetPayload().(Link).StartedOn,
+		statement.Predicate.RunDetails.BuildMetadata.StartedOn.Format(ISO8601DateSchema))
+	assert.Equal(t, metadata.GetPayload().(Link).FinishedOn,
+		statement.Predicate.RunDetails.BuildMetadata.FinishedOn.Format(ISO8601DateSchema))
+
+	_, _, err = InTotoRunWithProvenance("build", "", nil, nil, nil, key, []string{"sha256"}, nil, nil, RunOptions{
+		Provenance: &ProvenanceOptions{BuildType: "type"},
+	})
+	assert.ErrorIs(t, err, ErrMissingBuilderID)
+}
@@ -0,0 +1,208 @@
+package in_toto
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCertificateRevoked indicates that a certificate is listed in a CRL of its issuer
+var ErrCertificateRevoked = errors.New("certificate is revoked")
+
+// ErrMissingCRL indicates that no CRL is available for a CA that requires one
+var ErrMissingCRL = errors.New("missing certificate revocation list")
+
+// ErrStaleCRL indicates that the only CRLs of a CA were due for update before the verification time
+var ErrStaleCRL = errors.New("stale certificate revocation list")
+
+// pemTypeCRL is the PEM block type of certificate revocation lists
+const pemTypeCRL = "X509 CRL"
+
+/*
+CRL is the certificate revocation list of a root or intermediate CA of a
+layout.  The list is either embedded as PEM in the CRL field, or referenced by
+the URI field, in which case it must be passed to verification, e.g. using
+'--crl' of 'in-toto verify'.  In both cases, certificates issued by the CA
+are only accepted if a valid CRL of the CA is available and does not list
+them.
+*/
+type CRL struct {
+	CRL string `json:"crl,omitempty"`
+	URI string `json:"uri,omitempty"`
+}
+
+/*
+CRLSet holds the certificate revocation lists used to check certificate chains,
+and the CAs that must have one.
+*/
+type CRLSet struct {
+	crls     []*x509.RevocationList
+	required []*x509.Certificate
+}
+
+/*
+parseCRLs parses the PEM encoded certificate revocation lists in the passed
+bytes, or a single DER encoded one if they contain no PEM block.
+*/
+func parseCRLs(data []byte) ([]*x509.RevocationList, error) {
+	crls := []*x509.RevocationList{}
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != pemTypeCRL {
+			return nil, fmt.Errorf("unexpected PEM block type '%s', expected '%s'", block.Type, pemTypeCRL)
+		}
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		crls = append(crls, crl)
+	}
+
+	if len(crls) == 0 {
+		crl, err := x509.ParseRevocationList(data)
+		if err != nil {
+			return nil, err
+		}
+		crls = append(crls, crl)
+	}
+	return crls, nil
+}
+
+/*
+LoadLayoutCRLs loads the certificate revocation lists embedded in the layout,
+together with the passed PEM or DER encoded ones.  Every CA that has an entry
+in the CRLs of the layout must be one of its root or intermediate CAs and is
+required to have a CRL when checking chains.  Embedded CRLs must be signed by
+their CA, the signatures of passed CRLs are checked when they are used.
+*/
+func LoadLayoutCRLs(layout Layout, crlPems [][]byte) (*CRLSet, error) {
+	set := &CRLSet{}
+	for caID, entry := range layout.CRLs {
+		ca, ok := layout.RootCas[caID]
+		if !ok {
+			ca, ok = layout.IntermediateCas[caID]
+		}
+		if !ok {
+			return nil, fmt.Errorf("CRL for unknown CA '%s'", caID)
+		}
+
+		_, possibleCert, err := decodeAndParse([]byte(ca.KeyVal.Certificate))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate of CA '%s': %w", caID, err)
+		}
+		caCert, ok := possibleCert.(*x509.Certificate)
+		if !ok {
+			return nil, fmt.Errorf("CA '%s' has no valid certificate", caID)
+		}
+		set.required = append(set.required, caCert)
+
+		if entry.CRL == "" {
+			continue
+		}
+		crls, err := parseCRLs([]byte(entry.CRL))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load CRL of CA '%s': %w", caID, err)
+		}
+		for _, crl := range crls {
+			if err := crl.CheckSignatureFrom(caCert); err != nil {
+				return nil, fmt.Errorf("CRL of CA '%s' is not signed by the CA: %w", caID, err)
+			}
+		}
+		set.crls = append(set.crls, crls...)
+	}
+
+	for _, crlPem := range crlPems {
+		crls, err := parseCRLs(crlPem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load provided CRL: %w", err)
+		}
+		set.crls = append(set.crls, crls...)
+	}
+
+	return set, nil
+}
+
+/*
+CheckChains checks every certificate of the passed certificate chains, as
+returned by VerifyCertificateTrust, against the CRLs of its issuer in the chain.
+An ErrCertificateRevoked is returned if a CRL signed by the issuer lists the
+certificate, and an ErrMissingCRL if the issuer is required to have a CRL but
+none is available.  CRLs whose next update is before the passed verification
+time, or the current time if it is zero, still revoke certificates, but an
+ErrStaleCRL is returned if the issuer has no other CRL.
+*/
+func (s *CRLSet) CheckChains(chains [][]*x509.Certificate, verificationTime time.Time) error {
+	if verificationTime.IsZero() {
+		verificationTime = time.Now()
+	}
+
+	for _, chain := range chains {
+		for i := 0; i+1 < len(chain); i++ {
+			cert, issuer := chain[i], chain[i+1]
+
+			found, current := false, false
+			for _, crl := range s.crls {
+				if !bytes.Equal(crl.RawIssuer, issuer.RawSubject) || crl.CheckSignatureFrom(issuer) != nil {
+					continue
+				}
+				found = true
+				// CRLs without next update are never due
+				current = current || crl.NextUpdate.IsZero() || !crl.NextUpdate.Before(verificationTime)
+				for _, entry := range crl.RevokedCertificateEntries {
+					if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+						return fmt.Errorf("%w: '%s' with serial number %s, revoked by '%s'",
+							ErrCertificateRevoked, cert.Subject, cert.SerialNumber, issuer.Subject)
+					}
+				}
+			}
+
+			if found && !current {
+				return fmt.Errorf("%w: for CA '%s', next update was due before %s",
+					ErrStaleCRL, issuer.Subject, verificationTime.UTC().Format(time.RFC3339))
+			}
+			if !found && s.isRequired(issuer) {
+				return fmt.Errorf("%w: for CA '%s'", ErrMissingCRL, issuer.Subject)
+			}
+		}
+	}
+	return nil
+}
+
+// isRequired returns true if the passed CA is required to have a CRL.
+func (s *CRLSet) isRequired(ca *x509.Certificate) bool {
+	for _, required := range s.required {
+		if required.Equal(ca) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+checkCertRevocation builds the chains of trust of the certificate of the passed
+key at the passed time and checks them against the passed CRLs.
+*/
+func checkCertRevocation(key Key, rootCertPool, intermediateCertPool *x509.CertPool, crls *CRLSet, verificationTime time.Time) error {
+	_, possibleCert, err := decodeAndParse([]byte(key.KeyVal.Certificate))
+	if err != nil {
+		return err
+	}
+	cert, ok := possibleCert.(*x509.Certificate)
+	if !ok {
+		return fmt.Errorf("not a valid certificate")
+	}
+
+	chains, err := VerifyCertificateTrustAt(cert, rootCertPool, intermediateCertPool, verificationTime)
+	if err != nil {
+		return err
+	}
+	return crls.CheckChains(chains, verificationTime)
+}
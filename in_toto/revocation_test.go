@@ -0,0 +1,116 @@
+package in_toto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestCRL(t *testing.T, issuer *x509.Certificate, issuerKey crypto.PrivateKey, revoked ...*x509.Certificate) []byte {
+	return createTestCRLUntil(t, issuer, issuerKey, time.Now().Add(time.Hour), revoked...)
+}
+
+func createTestCRLUntil(t *testing.T, issuer *x509.Certificate, issuerKey crypto.PrivateKey, nextUpdate time.Time, revoked ...*x509.Certificate) []byte {
+	entries := []x509.RevocationListEntry{}
+	for _, cert := range revoked {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: cert.SerialNumber, RevocationTime: time.Now()})
+	}
+	template := &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                nextUpdate.Add(-2 * time.Hour),
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, template, issuer, issuerKey.(crypto.Signer))
+	if err != nil {
+		t.Fatalf("failed to create test CRL: %v", err)
+	}
+	return crl
+}
+
+func TestCRLRevocation(t *testing.T) {
+	root, _, rootKey, err := createSelfSignedCA(&x509.Certificate{Subject: pkix.Name{CommonName: "Root CA"}, MaxPathLen: 1}, x509.Ed25519, time.Hour)
+	assert.Nil(t, err)
+	intermediate, intermediatePem, intermediateKey, err := createCA(&x509.Certificate{Subject: pkix.Name{CommonName: "Intermediate CA"}}, root, rootKey, x509.Ed25519, time.Hour)
+	assert.Nil(t, err)
+	leaf, _, _, err := createEndEntityCert(&x509.Certificate{Subject: pkix.Name{CommonName: "step1.example.com"}}, intermediate, intermediateKey, x509.Ed25519, time.Hour)
+	assert.Nil(t, err)
+	other, _, _, err := createEndEntityCert(&x509.Certificate{Subject: pkix.Name{CommonName: "step2.example.com"}}, intermediate, intermediateKey, x509.Ed25519, time.Hour)
+	assert.Nil(t, err)
+
+	rootPool := x509.NewCertPool()
+	rootPool.AddCert(root)
+	intermediatePool := x509.NewCertPool()
+	intermediatePool.AddCert(intermediate)
+	chains, err := VerifyCertificateTrust(leaf, rootPool, intermediatePool)
+	assert.Nil(t, err)
+
+	var intermediateCAKey Key
+	err = intermediateCAKey.LoadKeyReader(bytes.NewReader(intermediatePem), "ed25519", []string{"sha512"})
+	assert.Nil(t, err)
+
+	revokedCRL := createTestCRL(t, intermediate, intermediateKey, leaf)
+	otherCRL := createTestCRL(t, intermediate, intermediateKey, other)
+	forgedCRL := createTestCRL(t, root, rootKey, leaf)
+	staleCRL := createTestCRLUntil(t, intermediate, intermediateKey, time.Now().Add(-time.Minute), other)
+	staleRevokedCRL := createTestCRLUntil(t, intermediate, intermediateKey, time.Now().Add(-time.Minute), leaf)
+
+	tables := []struct {
+		name       string
+		layoutCRLs map[string]CRL
+		crlPems    [][]byte
+		loadErr    bool
+		checkErr   error
+	}{
+		{"no CRLs", nil, nil, false, nil},
+		{"passed PEM CRL revokes", nil, [][]byte{generatePEMBlock(revokedCRL, pemTypeCRL)}, false, ErrCertificateRevoked},
+		{"passed DER CRL revokes", nil, [][]byte{revokedCRL}, false, ErrCertificateRevoked},
+		{"passed CRL does not revoke", nil, [][]byte{otherCRL}, false, nil},
+		{"passed CRL of other issuer", nil, [][]byte{forgedCRL}, false, nil},
+		{"invalid passed CRL", nil, [][]byte{[]byte("123123123")}, true, nil},
+		{"embedded CRL revokes", map[string]CRL{intermediateCAKey.KeyID: {CRL: string(generatePEMBlock(revokedCRL, pemTypeCRL))}}, nil, false, ErrCertificateRevoked},
+		{"embedded CRL does not revoke", map[string]CRL{intermediateCAKey.KeyID: {CRL: string(generatePEMBlock(otherCRL, pemTypeCRL))}}, nil, false, nil},
+		{"embedded CRL not signed by CA", map[string]CRL{intermediateCAKey.KeyID: {CRL: string(generatePEMBlock(forgedCRL, pemTypeCRL))}}, nil, true, nil},
+		{"referenced CRL missing", map[string]CRL{intermediateCAKey.KeyID: {URI: "https://example.com/intermediate.crl"}}, nil, false, ErrMissingCRL},
+		{"referenced CRL passed", map[string]CRL{intermediateCAKey.KeyID: {URI: "https://example.com/intermediate.crl"}}, [][]byte{otherCRL}, false, nil},
+		{"passed CRL is stale", nil, [][]byte{staleCRL}, false, ErrStaleCRL},
+		{"stale CRL with current CRL", nil, [][]byte{staleCRL, otherCRL}, false, nil},
+		{"stale CRL revokes", nil, [][]byte{staleRevokedCRL}, false, ErrCertificateRevoked},
+		{"embedded CRL is stale", map[string]CRL{intermediateCAKey.KeyID: {CRL: string(generatePEMBlock(staleCRL, pemTypeCRL))}}, nil, false, ErrStaleCRL},
+		{"CRL of unknown CA", map[string]CRL{"unknown": {URI: "https://example.com/unknown.crl"}}, nil, true, nil},
+	}
+
+	for _, table := range tables {
+		t.Run(table.name, func(t *testing.T) {
+			layout := Layout{
+				IntermediateCas: map[string]Key{intermediateCAKey.KeyID: intermediateCAKey},
+				CRLs:            table.layoutCRLs,
+			}
+			crls, err := LoadLayoutCRLs(layout, table.crlPems)
+			if table.loadErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+
+			err = crls.CheckChains(chains, time.Time{})
+			if table.checkErr == nil {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, table.checkErr)
+			}
+		})
+	}
+
+	// CRLs are current at past verification times, e.g. of trusted timestamps
+	crls, err := LoadLayoutCRLs(Layout{}, [][]byte{staleCRL})
+	assert.Nil(t, err)
+	assert.Nil(t, crls.CheckChains(chains, time.Now().Add(-2*time.Minute)))
+}
@@ -0,0 +1,123 @@
+package in_toto
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+)
+
+/*
+rsaSignerVerifier is a dsse.SignerVerifier that signs and verifies using the
+RSASSA-PSS and RSASSA-PKCS1-v1_5 schemes of the securesystemslib with any of
+the SHA-2 hash functions.
+*/
+type rsaSignerVerifier struct {
+	keyID   string
+	hash    crypto.Hash
+	pss     bool
+	private *rsa.PrivateKey
+	public  *rsa.PublicKey
+}
+
+/*
+getRSASchemeHash returns the hash function and whether RSASSA-PSS padding is
+used for the passed RSA scheme. It returns an ErrSchemeKeyTypeMismatch if the
+scheme is not an RSA scheme.
+*/
+func getRSASchemeHash(scheme string) (crypto.Hash, bool, error) {
+	var pss bool
+	var hashName string
+	switch {
+	case strings.HasPrefix(scheme, "rsassa-pss-"):
+		pss = true
+		hashName = strings.TrimPrefix(scheme, "rsassa-pss-")
+	case strings.HasPrefix(scheme, "rsa-pkcs1v15-"):
+		hashName = strings.TrimPrefix(scheme, "rsa-pkcs1v15-")
+	default:
+		return 0, false, fmt.Errorf("%w: %s", ErrSchemeKeyTypeMismatch, scheme)
+	}
+
+	switch hashName {
+	case "sha224":
+		return crypto.SHA224, pss, nil
+	case "sha256":
+		return crypto.SHA256, pss, nil
+	case "sha384":
+		return crypto.SHA384, pss, nil
+	case "sha512":
+		return crypto.SHA512, pss, nil
+	}
+	return 0, false, fmt.Errorf("%w: %s", ErrSchemeKeyTypeMismatch, scheme)
+}
+
+// newRSASignerVerifierFromKey creates an rsaSignerVerifier for the RSA key.
+func newRSASignerVerifierFromKey(key Key) (*rsaSignerVerifier, error) {
+	hash, pss, err := getRSASchemeHash(key.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	public, private, err := key.cryptoKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	sv := &rsaSignerVerifier{keyID: key.KeyID, hash: hash, pss: pss}
+	var ok bool
+	if sv.public, ok = public.(*rsa.PublicKey); !ok {
+		return nil, ErrKeyKeyTypeMismatch
+	}
+	if private != nil {
+		if sv.private, ok = private.(*rsa.PrivateKey); !ok {
+			return nil, ErrKeyKeyTypeMismatch
+		}
+	}
+	return sv, nil
+}
+
+// digest hashes data with the hash function of the scheme.
+func (sv *rsaSignerVerifier) digest(data []byte) []byte {
+	return hashToHex(sv.hash.New(), data)
+}
+
+// Sign creates a signature for data.
+func (sv *rsaSignerVerifier) Sign(_ context.Context, data []byte) ([]byte, error) {
+	if sv.private == nil {
+		return nil, fmt.Errorf("%w: signing requires a private key", ErrInvalidKey)
+	}
+
+	if sv.pss {
+		// The salt length equals the digest length, as in the securesystemslib
+		return rsa.SignPSS(rand.Reader, sv.private, sv.hash, sv.digest(data),
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: sv.hash})
+	}
+	return rsa.SignPKCS1v15(rand.Reader, sv.private, sv.hash, sv.digest(data))
+}
+
+// Verify verifies sig against data.
+func (sv *rsaSignerVerifier) Verify(_ context.Context, data []byte, sig []byte) error {
+	var err error
+	if sv.pss {
+		err = rsa.VerifyPSS(sv.public, sv.hash, sv.digest(data), sig,
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: sv.hash})
+	} else {
+		err = rsa.VerifyPKCS1v15(sv.public, sv.hash, sv.digest(data), sig)
+	}
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// KeyID returns the identifier of the key.
+func (sv *rsaSignerVerifier) KeyID() (string, error) {
+	return sv.keyID, nil
+}
+
+// Public returns the public key.
+func (sv *rsaSignerVerifier) Public() crypto.PublicKey {
+	return sv.public
+}
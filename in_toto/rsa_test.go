@@ -0,0 +1,61 @@
+package in_toto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRSASchemes signs and verifies a Metablock and a DSSE envelope with each
+// supported RSA scheme.
+func TestRSASchemes(t *testing.T) {
+	for _, scheme := range getSupportedRSASchemes() {
+		var priv, pub Key
+		if err := priv.LoadKey("dan", scheme, []string{"sha256", "sha512"}); err != nil {
+			t.Fatalf("failed to load private key with scheme %s: %s", scheme, err)
+		}
+		if err := pub.LoadKey("dan.pub", scheme, []string{"sha256", "sha512"}); err != nil {
+			t.Fatalf("failed to load public key with scheme %s: %s", scheme, err)
+		}
+		assert.Equal(t, priv.KeyID, pub.KeyID, scheme)
+
+		mb := &Metablock{Signed: Link{Type: "link", Name: "rsa"}}
+		assert.Nil(t, mb.Sign(priv), scheme)
+		assert.Nil(t, mb.VerifySignature(pub), scheme)
+
+		env := &Envelope{}
+		assert.Nil(t, env.SetPayload(Link{Type: "link", Name: "rsa"}), scheme)
+		assert.Nil(t, env.Sign(priv), scheme)
+		assert.Nil(t, env.VerifySignature(pub), scheme)
+
+		// A signature must not verify under a different scheme of the same key
+		for _, otherScheme := range getSupportedRSASchemes() {
+			if otherScheme == scheme {
+				continue
+			}
+			var other Key
+			assert.Nil(t, other.LoadKey("dan.pub", otherScheme, []string{"sha256", "sha512"}))
+			// Key IDs differ per scheme, so reuse the signature's key id
+			other.KeyID = pub.KeyID
+			if err := mb.VerifySignature(other); err == nil {
+				t.Errorf("signature created with %s verified with %s", scheme, otherScheme)
+			}
+		}
+	}
+}
+
+func TestGetRSASchemeHashErrors(t *testing.T) {
+	for _, scheme := range []string{"rsassa-pss-md5", "rsa-pkcs1v15-sha1", "ecdsa-sha2-nistp256", ""} {
+		if _, _, err := getRSASchemeHash(scheme); !errors.Is(err, ErrSchemeKeyTypeMismatch) {
+			t.Errorf("expected ErrSchemeKeyTypeMismatch for %s, got %s", scheme, err)
+		}
+	}
+}
+
+func TestRSASignWithPublicKey(t *testing.T) {
+	var pub Key
+	assert.Nil(t, pub.LoadKey("dan.pub", rsapkcs1v15sha256, []string{"sha256", "sha512"}))
+	mb := &Metablock{Signed: Link{Type: "link", Name: "rsa"}}
+	assert.ErrorIs(t, mb.Sign(pub), ErrInvalidKey)
+}
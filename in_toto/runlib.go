@@ -2,8 +2,12 @@ package in_toto
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"os/exec"
@@ -11,6 +15,8 @@ import (
 	"reflect"
 	"strings"
 	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/shibumi/go-pathspec"
 )
@@ -23,6 +29,15 @@ var ErrUnsupportedHashAlgorithm = errors.New("unsupported hash algorithm detecte
 
 var ErrEmptyCommandArgs = errors.New("the command args are empty")
 
+// ErrCommandTimeout signals that a command was killed, because it exceeded its timeout
+var ErrCommandTimeout = errors.New("command timed out")
+
+// ErrResourceLimitsUnsupported signals that resource limits of commands are not supported on the current platform
+var ErrResourceLimitsUnsupported = errors.New("command resource limits are not supported on this platform")
+
+// ErrCommandInitNotRegistered signals that MaybeRunCommandInit is not called by the current program
+var ErrCommandInitNotRegistered = errors.New("command init process is not registered, MaybeRunCommandInit must be called at the start of main")
+
 // visitedSymlinks is a hashset that contains all paths that we have visited.
 var visitedSymlinks Set
 
@@ -258,6 +273,126 @@ func waitErrToExitCode(err error) int {
 	return retVal
 }
 
+/*
+TruncationMarkerFormat is the format of the marker appended to captured command
+output that exceeds the maximum output size.  It is formatted with the number
+of bytes that were not captured.
+*/
+const TruncationMarkerFormat = "\n[truncated %d bytes]"
+
+/*
+CommandOptions holds the optional settings of RunCommandWithOptions.  The
+output of the command is streamed to Stdout and Stderr while it runs, if they
+are set, e.g. to os.Stdout and os.Stderr to show build logs.  MaxOutputSize
+limits the number of bytes of stdout and stderr each, which are captured in
+the byproducts, unless it is 0, and must not be negative.  Truncated output ends with a marker in
+TruncationMarkerFormat.  DigestOutput captures the sha256 digests of the
+complete stdout and stderr instead of their contents.
+
+The remaining options restrict the execution of the command, e.g. of
+inspections defined in layouts of third parties.  The command is killed, with
+the processes it started on Unix, if it runs longer than Timeout, unless it is
+0.  Stdin is read as standard input of the command, which otherwise reads from
+the null device.  CleanEnv runs the command with an empty environment, to which
+only the variables of the current environment whose names match PassEnv are
+passed.  PassEnv are names or glob patterns, with the syntax of path.Match,
+matched case-insensitively.  Limits restricts the resources of the command, see
+ResourceLimits.  Sandbox runs the command in new Linux namespaces without
+network access, as an unprivileged user, with read-only mounts besides a
+writable scratch directory, see SandboxScratchDir.  If the sandbox cannot be
+set up, the command is not run.  Limits and Sandbox require the current
+program to call MaybeRunCommandInit.
+*/
+type CommandOptions struct {
+	Stdout        io.Writer
+	Stderr        io.Writer
+	MaxOutputSize int
+	DigestOutput  bool
+	Timeout       time.Duration
+	Stdin         io.Reader
+	CleanEnv      bool
+	PassEnv       []string
+	Limits        ResourceLimits
+	Sandbox       bool
+}
+
+/*
+ResourceLimits are resource limits of a command, which are only supported on
+Linux, where they are set as rlimits before the command is executed, see
+MaybeRunCommandInit.
+CPUTime limits the CPU time, rounded up to full seconds, Memory the size of the
+virtual memory in bytes, and OpenFiles the number of open file descriptors.
+Limits that are 0 are not set.
+*/
+type ResourceLimits struct {
+	CPUTime   time.Duration `json:"cpu_time,omitempty"`
+	Memory    uint64        `json:"memory,omitempty"`
+	OpenFiles uint64        `json:"open_files,omitempty"`
+}
+
+// isZero returns true if none of the limits is set.
+func (l ResourceLimits) isZero() bool {
+	return l == ResourceLimits{}
+}
+
+/*
+outputCapture is an io.Writer that captures command output up to a maximum
+size, and hashes all of it.
+*/
+type outputCapture struct {
+	maxSize   int
+	buf       bytes.Buffer
+	truncated int
+	hash      hash.Hash
+}
+
+// newOutputCapture initializes an outputCapture that captures up to maxSize
+// bytes, or all output if maxSize is 0.
+func newOutputCapture(maxSize int) *outputCapture {
+	return &outputCapture{maxSize: maxSize, hash: sha256.New()}
+}
+
+// Write hashes the passed output and captures as much of it as fits.
+func (c *outputCapture) Write(p []byte) (int, error) {
+	c.hash.Write(p)
+
+	captured := p
+	if c.maxSize > 0 {
+		if remaining := c.maxSize - c.buf.Len(); remaining < len(p) {
+			captured = p[:max(remaining, 0)]
+		}
+	}
+	c.buf.Write(captured)
+	c.truncated += len(p) - len(captured)
+
+	return len(p), nil
+}
+
+/*
+byProduct returns the captured output, which ends with a truncation marker if
+output was dropped, or the sha256 digest of the complete output in the format
+of a HashObj, if digestOutput is set.
+*/
+func (c *outputCapture) byProduct(digestOutput bool) interface{} {
+	if digestOutput {
+		return map[string]interface{}{"sha256": hex.EncodeToString(c.hash.Sum(nil))}
+	}
+	if c.truncated == 0 {
+		return c.buf.String()
+	}
+
+	// do not end the output in the middle of a multi-byte character
+	output := c.buf.Bytes()
+	for i := 0; i < utf8.UTFMax-1 && len(output) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(output); r != utf8.RuneError || size != 1 {
+			break
+		}
+		output = output[:len(output)-1]
+	}
+	truncated := c.truncated + c.buf.Len() - len(output)
+	return string(output) + fmt.Sprintf(TruncationMarkerFormat, truncated)
+}
+
 /*
 RunCommand executes the passed command in a subprocess.  The first element of
 cmdArgs is used as executable and the rest as command arguments.  It captures
@@ -269,48 +404,251 @@ and returns stdout, stderr and exit code.  The format of the returned map is:
 		"stderr": "<standard error>"
 	}
 
-If the command cannot be executed or no pipes for stdout or stderr can be
-created the first return value is nil and the second return value is the error.
+If the command cannot be executed the first return value is nil and the second
+return value is the error.
 NOTE: Since stdout and stderr are captured, they cannot be seen during the
-command execution.
+command execution.  Use RunCommandWithOptions to stream them.
 */
 func RunCommand(cmdArgs []string, runDir string) (map[string]interface{}, error) {
+	return RunCommandWithOptions(cmdArgs, runDir, CommandOptions{})
+}
+
+/*
+RunCommandWithOptions provides the same functionality as RunCommand, but takes
+its optional settings as CommandOptions.  Stdout and stderr are read
+concurrently, such that a command filling one of them cannot block.  If the
+command exceeds its timeout, the first return value is nil and the second
+return value wraps ErrCommandTimeout.  If DigestOutput is set, the format of
+the returned map is:
+
+	{
+		"return-value": <exit code>,
+		"stdout": {"sha256": "<digest of standard output>"},
+		"stderr": {"sha256": "<digest of standard error>"}
+	}
+*/
+func RunCommandWithOptions(cmdArgs []string, runDir string, options CommandOptions) (map[string]interface{}, error) {
 	if len(cmdArgs) == 0 {
 		return nil, ErrEmptyCommandArgs
 	}
 
-	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+	if options.MaxOutputSize < 0 {
+		return nil, fmt.Errorf("invalid max output size %d, must not be negative", options.MaxOutputSize)
+	}
+
+	ctx := context.Background()
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...)
+	if options.Timeout > 0 {
+		killProcessGroupOnCancel(cmd)
+		// do not wait for output of orphaned processes that inherited stdout or stderr
+		cmd.WaitDelay = commandWaitDelay
+	}
 
 	if runDir != "" {
 		cmd.Dir = runDir
 	}
 
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
+	if options.CleanEnv {
+		env, err := filterEnvironment(os.Environ(), options.PassEnv)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Env = env
 	}
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, err
+
+	cmd.Stdin = options.Stdin
+
+	// The command init process sets up the sandbox and limits before the
+	// command is executed
+	var cmdInit *commandInit
+	if options.Sandbox || !options.Limits.isZero() {
+		var err error
+		if cmdInit, err = setupCommandInit(cmd, runDir, options); err != nil {
+			return nil, err
+		}
+	}
+
+	// exec copies output to writers other than files in separate goroutines,
+	// and Wait waits for the copying to finish
+	stdout := newOutputCapture(options.MaxOutputSize)
+	stderr := newOutputCapture(options.MaxOutputSize)
+	cmd.Stdout = stdout
+	if options.Stdout != nil {
+		cmd.Stdout = io.MultiWriter(stdout, options.Stdout)
+	}
+	cmd.Stderr = stderr
+	if options.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(stderr, options.Stderr)
 	}
 
 	if err := cmd.Start(); err != nil {
+		if cmdInit != nil {
+			return nil, cmdInit.started(err)
+		}
 		return nil, err
 	}
 
-	// TODO: duplicate stdout, stderr
-	stdout, _ := io.ReadAll(stdoutPipe)
-	stderr, _ := io.ReadAll(stderrPipe)
+	if cmdInit != nil {
+		if err := cmdInit.started(nil); err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return nil, err
+		}
+	}
 
-	retVal := waitErrToExitCode(cmd.Wait())
+	// commands that exit right before the deadline did not time out
+	waitErr := cmd.Wait()
+	if waitErr != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w after %s: %s", ErrCommandTimeout, options.Timeout, cmdArgs)
+	}
+	retVal := waitErrToExitCode(waitErr)
 
 	return map[string]interface{}{
 		"return-value": float64(retVal),
-		"stdout":       string(stdout),
-		"stderr":       string(stderr),
+		"stdout":       stdout.byProduct(options.DigestOutput),
+		"stderr":       stderr.byProduct(options.DigestOutput),
 	}, nil
 }
 
+// commandWaitDelay is the time to wait for the output of a killed command to be closed.
+const commandWaitDelay = time.Second
+
+/*
+filterEnvironment returns the entries of the passed environment, in the format
+of os.Environ, whose variable names match one of the passed patterns.  The
+returned slice is not nil, such that it results in an empty environment if no
+name matches.
+*/
+func filterEnvironment(environ []string, patterns []string) ([]string, error) {
+	filtered := []string{}
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		matched, err := matchVariableName(patterns, name)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered, nil
+}
+
+/*
+RunOptions holds the optional settings of InTotoRunWithOptions,
+InTotoRecordStartWithOptions and InTotoRecordStopWithOptions.
+LineNormalization enables line ending normalization when hashing artifacts,
+FollowSymlinkDirs follows symlinks to directories when recording artifacts, and
+UseDSSE wraps the link in a DSSE envelope instead of a Metablock.  RecordTimes
+records the times at which the step started and finished in the link, see
+StartedOn and FinishedOn of Link.  Environment selects the parts of the
+environment recorded in the link when the step starts, see CaptureEnvironment.
+Command configures how the output of the command is streamed and captured, see
+RunCommandWithOptions.  Provenance configures the SLSA v1 provenance created by
+InTotoRunWithProvenance and InTotoRecordStopWithProvenance.  Its StartedOn and
+FinishedOn default to the times at which the step started and finished.
+AttestationFormat is the format of the links created by InTotoRun and
+InTotoRecordStop, AttestationFormatLink if empty.  AttestationFormatStatementV1
+creates a DSSE envelope with an ITE-6 v1 Statement regardless of UseDSSE, see
+NewLinkStatementEnvelope, and cannot be used with RecordTimes.  Preliminary
+links created by InTotoRecordStart are always in the link format.
+*/
+type RunOptions struct {
+	LineNormalization bool
+	FollowSymlinkDirs bool
+	UseDSSE           bool
+	RecordTimes       bool
+	Environment       EnvironmentOptions
+	Command           CommandOptions
+	Provenance        *ProvenanceOptions
+	AttestationFormat string
+}
+
+/*
+checkAttestationFormat checks the attestation format of the passed options,
+before any link is created.
+*/
+func checkAttestationFormat(options RunOptions) error {
+	switch options.AttestationFormat {
+	case "", AttestationFormatLink:
+		return nil
+	case AttestationFormatStatementV1:
+		// the link predicate of the statement has no start and finish times
+		if options.RecordTimes {
+			return fmt.Errorf("start and finish times cannot be recorded in the %s attestation format", AttestationFormatStatementV1)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown attestation format '%s'", options.AttestationFormat)
+}
+
+/*
+newLinkMetadata wraps the passed link in the metadata of the attestation format
+and signature wrapper of the passed options, and signs it with the passed key,
+unless the key is the zero value.
+*/
+func newLinkMetadata(link Link, key Key, options RunOptions) (Metadata, error) {
+	if options.AttestationFormat == AttestationFormatStatementV1 {
+		return NewLinkStatementEnvelope(link, key)
+	}
+
+	if options.UseDSSE {
+		env := &Envelope{}
+		if err := env.SetPayload(link); err != nil {
+			return nil, err
+		}
+
+		if !reflect.ValueOf(key).IsZero() {
+			if err := env.Sign(key); err != nil {
+				return nil, err
+			}
+		}
+
+		return env, nil
+	}
+
+	linkMb := &Metablock{Signed: link, Signatures: []Signature{}}
+	if !reflect.ValueOf(key).IsZero() {
+		if err := linkMb.Sign(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return linkMb, nil
+}
+
+// linkTimeNow returns the current time with the precision of link start and finish times.
+func linkTimeNow() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+/*
+newStepProvenance creates the provenance configured in the passed options for
+the passed link, signed with the passed key, or returns nil if no provenance is
+configured.  Unset provenance times are set to the passed start and finish
+times, unless those are nil.
+*/
+func newStepProvenance(link Link, options RunOptions, key Key, startedOn, finishedOn *time.Time) (*Envelope, error) {
+	if options.Provenance == nil {
+		return nil, nil
+	}
+
+	provenanceOptions := *options.Provenance
+	if provenanceOptions.StartedOn == nil {
+		provenanceOptions.StartedOn = startedOn
+	}
+	if provenanceOptions.FinishedOn == nil {
+		provenanceOptions.FinishedOn = finishedOn
+	}
+	return NewProvenanceEnvelope(link, provenanceOptions, key)
+}
+
 /*
 InTotoRun executes commands, e.g. for software supply chain steps or
 inspections of an in-toto layout, and creates and returns corresponding link
@@ -320,24 +658,57 @@ Metablock object.  If command execution or artifact recording fails the first
 return value is an empty Metablock and the second return value is the error.
 */
 func InTotoRun(name string, runDir string, materialPaths []string, productPaths []string, cmdArgs []string, key Key, hashAlgorithms []string, gitignorePatterns []string, lStripPaths []string, lineNormalization bool, followSymlinkDirs bool, useDSSE bool) (Metadata, error) {
-	materials, err := RecordArtifacts(materialPaths, hashAlgorithms, gitignorePatterns, lStripPaths, lineNormalization, followSymlinkDirs)
+	return InTotoRunWithOptions(name, runDir, materialPaths, productPaths, cmdArgs, key, hashAlgorithms, gitignorePatterns, lStripPaths,
+		RunOptions{LineNormalization: lineNormalization, FollowSymlinkDirs: followSymlinkDirs, UseDSSE: useDSSE})
+}
+
+/*
+InTotoRunWithOptions provides the same functionality as InTotoRun, but takes
+its optional settings as RunOptions.
+*/
+func InTotoRunWithOptions(name string, runDir string, materialPaths []string, productPaths []string, cmdArgs []string, key Key, hashAlgorithms []string, gitignorePatterns []string, lStripPaths []string, options RunOptions) (Metadata, error) {
+	metadata, _, err := InTotoRunWithProvenance(name, runDir, materialPaths, productPaths, cmdArgs, key, hashAlgorithms, gitignorePatterns, lStripPaths, options)
+	return metadata, err
+}
+
+/*
+InTotoRunWithProvenance provides the same functionality as
+InTotoRunWithOptions, and additionally returns the SLSA v1 provenance of the
+step configured by the Provenance of the passed options, signed with the
+passed key, see NewProvenanceEnvelope.  If no provenance is configured, the
+second return value is nil.
+*/
+func InTotoRunWithProvenance(name string, runDir string, materialPaths []string, productPaths []string, cmdArgs []string, key Key, hashAlgorithms []string, gitignorePatterns []string, lStripPaths []string, options RunOptions) (Metadata, *Envelope, error) {
+	if err := checkAttestationFormat(options); err != nil {
+		return nil, nil, err
+	}
+
+	startedOn := linkTimeNow()
+
+	environment, err := CaptureEnvironment(options.Environment)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	materials, err := RecordArtifacts(materialPaths, hashAlgorithms, gitignorePatterns, lStripPaths, options.LineNormalization, options.FollowSymlinkDirs)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// make sure that we only run RunCommand if cmdArgs is not nil or empty
 	byProducts := map[string]interface{}{}
 	if len(cmdArgs) != 0 {
-		byProducts, err = RunCommand(cmdArgs, runDir)
+		byProducts, err = RunCommandWithOptions(cmdArgs, runDir, options.Command)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	products, err := RecordArtifacts(productPaths, hashAlgorithms, gitignorePatterns, lStripPaths, lineNormalization, followSymlinkDirs)
+	products, err := RecordArtifacts(productPaths, hashAlgorithms, gitignorePatterns, lStripPaths, options.LineNormalization, options.FollowSymlinkDirs)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	finishedOn := linkTimeNow()
 
 	link := Link{
 		Type:        "link",
@@ -346,32 +717,25 @@ func InTotoRun(name string, runDir string, materialPaths []string, productPaths
 		Products:    products,
 		ByProducts:  byProducts,
 		Command:     cmdArgs,
-		Environment: map[string]interface{}{},
+		Environment: environment,
 	}
 
-	if useDSSE {
-		env := &Envelope{}
-		if err := env.SetPayload(link); err != nil {
-			return nil, err
-		}
-
-		if !reflect.ValueOf(key).IsZero() {
-			if err := env.Sign(key); err != nil {
-				return nil, err
-			}
-		}
+	if options.RecordTimes {
+		link.StartedOn = startedOn.Format(ISO8601DateSchema)
+		link.FinishedOn = finishedOn.Format(ISO8601DateSchema)
+	}
 
-		return env, nil
+	provenance, err := newStepProvenance(link, options, key, &startedOn, &finishedOn)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	linkMb := &Metablock{Signed: link, Signatures: []Signature{}}
-	if !reflect.ValueOf(key).IsZero() {
-		if err := linkMb.Sign(key); err != nil {
-			return nil, err
-		}
+	metadata, err := newLinkMetadata(link, key, options)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return linkMb, nil
+	return metadata, provenance, nil
 }
 
 /*
@@ -381,7 +745,23 @@ by a single command.  InTotoRecordStart collects the hashes of the materials
 before any commands are run, signs the unfinished link, and returns the link.
 */
 func InTotoRecordStart(name string, materialPaths []string, key Key, hashAlgorithms, gitignorePatterns []string, lStripPaths []string, lineNormalization bool, followSymlinkDirs bool, useDSSE bool) (Metadata, error) {
-	materials, err := RecordArtifacts(materialPaths, hashAlgorithms, gitignorePatterns, lStripPaths, lineNormalization, followSymlinkDirs)
+	return InTotoRecordStartWithOptions(name, materialPaths, key, hashAlgorithms, gitignorePatterns, lStripPaths,
+		RunOptions{LineNormalization: lineNormalization, FollowSymlinkDirs: followSymlinkDirs, UseDSSE: useDSSE})
+}
+
+/*
+InTotoRecordStartWithOptions provides the same functionality as
+InTotoRecordStart, but takes its optional settings as RunOptions.
+*/
+func InTotoRecordStartWithOptions(name string, materialPaths []string, key Key, hashAlgorithms, gitignorePatterns []string, lStripPaths []string, options RunOptions) (Metadata, error) {
+	startedOn := linkTimeNow()
+
+	environment, err := CaptureEnvironment(options.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	materials, err := RecordArtifacts(materialPaths, hashAlgorithms, gitignorePatterns, lStripPaths, options.LineNormalization, options.FollowSymlinkDirs)
 	if err != nil {
 		return nil, err
 	}
@@ -393,10 +773,14 @@ func InTotoRecordStart(name string, materialPaths []string, key Key, hashAlgorit
 		Products:    map[string]HashObj{},
 		ByProducts:  map[string]interface{}{},
 		Command:     []string{},
-		Environment: map[string]interface{}{},
+		Environment: environment,
+	}
+
+	if options.RecordTimes {
+		link.StartedOn = startedOn.Format(ISO8601DateSchema)
 	}
 
-	if useDSSE {
+	if options.UseDSSE {
 		env := &Envelope{}
 		if err := env.SetPayload(link); err != nil {
 			return nil, err
@@ -430,45 +814,72 @@ commands run between InTotoRecordStart and InTotoRecordStop.  The resultant
 finished link metablock is then signed by the provided key and returned.
 */
 func InTotoRecordStop(prelimLinkEnv Metadata, productPaths []string, key Key, hashAlgorithms, gitignorePatterns []string, lStripPaths []string, lineNormalization bool, followSymlinkDirs bool, useDSSE bool) (Metadata, error) {
+	return InTotoRecordStopWithOptions(prelimLinkEnv, productPaths, key, hashAlgorithms, gitignorePatterns, lStripPaths,
+		RunOptions{LineNormalization: lineNormalization, FollowSymlinkDirs: followSymlinkDirs, UseDSSE: useDSSE})
+}
+
+/*
+InTotoRecordStopWithOptions provides the same functionality as
+InTotoRecordStop, but takes its optional settings as RunOptions.  The start
+time recorded by InTotoRecordStartWithOptions is kept in any case.
+*/
+func InTotoRecordStopWithOptions(prelimLinkEnv Metadata, productPaths []string, key Key, hashAlgorithms, gitignorePatterns []string, lStripPaths []string, options RunOptions) (Metadata, error) {
+	metadata, _, err := InTotoRecordStopWithProvenance(prelimLinkEnv, productPaths, key, hashAlgorithms, gitignorePatterns, lStripPaths, options)
+	return metadata, err
+}
+
+/*
+InTotoRecordStopWithProvenance provides the same functionality as
+InTotoRecordStopWithOptions, and additionally returns the SLSA v1 provenance
+of the step configured by the Provenance of the passed options, signed with
+the passed key.  The start time of the provenance defaults to the StartedOn of
+the preliminary link, if it has one.  If no provenance is configured, the
+second return value is nil.
+*/
+func InTotoRecordStopWithProvenance(prelimLinkEnv Metadata, productPaths []string, key Key, hashAlgorithms, gitignorePatterns []string, lStripPaths []string, options RunOptions) (Metadata, *Envelope, error) {
+	if err := checkAttestationFormat(options); err != nil {
+		return nil, nil, err
+	}
 	if err := prelimLinkEnv.VerifySignature(key); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	link, ok := prelimLinkEnv.GetPayload().(Link)
 	if !ok {
-		return nil, errors.New("invalid metadata block")
+		return nil, nil, errors.New("invalid metadata block")
 	}
 
-	products, err := RecordArtifacts(productPaths, hashAlgorithms, gitignorePatterns, lStripPaths, lineNormalization, followSymlinkDirs)
+	products, err := RecordArtifacts(productPaths, hashAlgorithms, gitignorePatterns, lStripPaths, options.LineNormalization, options.FollowSymlinkDirs)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	finishedOn := linkTimeNow()
 
 	link.Products = products
 
-	if useDSSE {
-		env := &Envelope{}
-		if err := env.SetPayload(link); err != nil {
-			return nil, err
-		}
+	if options.RecordTimes {
+		link.FinishedOn = finishedOn.Format(ISO8601DateSchema)
+	}
 
-		if !reflect.ValueOf(key).IsZero() {
-			if err := env.Sign(key); err != nil {
-				return nil, err
-			}
+	var startedOn *time.Time
+	if link.StartedOn != "" {
+		started, err := parseLinkTime(link.StartedOn)
+		if err != nil {
+			return nil, nil, err
 		}
-
-		return env, nil
+		startedOn = &started
+	}
+	provenance, err := newStepProvenance(link, options, key, startedOn, &finishedOn)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	linkMb := &Metablock{Signed: link, Signatures: []Signature{}}
-	if !reflect.ValueOf(key).IsZero() {
-		if err := linkMb.Sign(key); err != nil {
-			return linkMb, err
-		}
+	metadata, err := newLinkMetadata(link, key, options)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return linkMb, nil
+	return metadata, provenance, nil
 }
 
 /*
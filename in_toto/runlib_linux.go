@@ -0,0 +1,34 @@
+package in_toto
+
+import (
+	"syscall"
+	"time"
+)
+
+/*
+setResourceLimits sets the passed limits that are not 0 as soft and hard
+rlimits of the current process, such that it cannot raise them, and they are
+inherited by the command it executes.  The rlimits are set with the syscall
+package, which otherwise restores the open files limit of the process when
+executing a command.
+*/
+func setResourceLimits(limits ResourceLimits) error {
+	rlimits := []struct {
+		resource int
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, uint64((limits.CPUTime + time.Second - 1) / time.Second)},
+		{syscall.RLIMIT_AS, limits.Memory},
+		{syscall.RLIMIT_NOFILE, limits.OpenFiles},
+	}
+	for _, rlimit := range rlimits {
+		if rlimit.value == 0 {
+			continue
+		}
+		limit := syscall.Rlimit{Cur: rlimit.value, Max: rlimit.value}
+		if err := syscall.Setrlimit(rlimit.resource, &limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
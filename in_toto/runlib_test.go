@@ -1,6 +1,10 @@
 package in_toto
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -10,7 +14,9 @@ import (
 	"reflect"
 	"runtime"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 	"github.com/stretchr/testify/assert"
@@ -379,6 +385,84 @@ func TestRunCommand(t *testing.T) {
 	}
 }
 
+func TestRunCommandWithOptions(t *testing.T) {
+	// Stream output while capturing it
+	var stdout, stderr bytes.Buffer
+	result, err := RunCommandWithOptions([]string{"sh", "-c", "printf out; printf err >&2"}, "",
+		CommandOptions{Stdout: &stdout, Stderr: &stderr})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"return-value": float64(0), "stdout": "out", "stderr": "err"}, result)
+	assert.Equal(t, "out", stdout.String())
+	assert.Equal(t, "err", stderr.String())
+
+	// Do not block on a command filling stderr before writing to stdout
+	result, err = RunCommandWithOptions([]string{"sh", "-c", "head -c 1048576 /dev/zero >&2; printf out"}, "",
+		CommandOptions{MaxOutputSize: 16})
+	assert.Nil(t, err)
+	assert.Equal(t, "out", result["stdout"])
+	assert.Equal(t, strings.Repeat("\x00", 16)+fmt.Sprintf(TruncationMarkerFormat, 1048576-16), result["stderr"])
+
+	// Do not truncate in the middle of a multi-byte character
+	result, err = RunCommandWithOptions([]string{"sh", "-c", "printf 'a\\303\\244b'"}, "",
+		CommandOptions{MaxOutputSize: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "a"+fmt.Sprintf(TruncationMarkerFormat, 3), result["stdout"])
+
+	// Capture digests of the complete output
+	result, err = RunCommandWithOptions([]string{"sh", "-c", "printf out"}, "",
+		CommandOptions{MaxOutputSize: 1, DigestOutput: true})
+	assert.Nil(t, err)
+	digest := sha256.Sum256([]byte("out"))
+	assert.Equal(t, map[string]interface{}{"sha256": hex.EncodeToString(digest[:])}, result["stdout"])
+}
+
+func TestRunCommandPolicy(t *testing.T) {
+	if testOSisWindows() {
+		t.Skip("requires a Unix shell")
+	}
+
+	// Reject negative output sizes instead of capturing the complete output
+	_, err := RunCommandWithOptions([]string{"true"}, "", CommandOptions{MaxOutputSize: -1})
+	assert.ErrorContains(t, err, "max output size")
+
+	// Kill a command and the processes it started when it exceeds its timeout
+	start := time.Now()
+	_, err = RunCommandWithOptions([]string{"sh", "-c", "sleep 10 & sleep 10"}, "",
+		CommandOptions{Timeout: 100 * time.Millisecond})
+	assert.ErrorIs(t, err, ErrCommandTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// Read stdin from the passed reader
+	result, err := RunCommandWithOptions([]string{"cat"}, "", CommandOptions{Stdin: strings.NewReader("in")})
+	assert.Nil(t, err)
+	assert.Equal(t, "in", result["stdout"])
+
+	// Pass only allowlisted variables to a clean environment
+	t.Setenv("INTOTO_TEST_PASS", "pass")
+	t.Setenv("INTOTO_TEST_DROP", "drop")
+	result, err = RunCommandWithOptions([]string{"env"}, "",
+		CommandOptions{CleanEnv: true, PassEnv: []string{"intoto_test_pass"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "INTOTO_TEST_PASS=pass\n", result["stdout"])
+
+	_, err = RunCommandWithOptions([]string{"env"}, "", CommandOptions{CleanEnv: true, PassEnv: []string{"["}})
+	assert.NotNil(t, err, "expected error for invalid pattern")
+
+	// Set resource limits on Linux before the command is executed
+	limits := ResourceLimits{OpenFiles: 64}
+	result, err = RunCommandWithOptions([]string{"sh", "-c", "ulimit -n; ulimit -Hn"}, "", CommandOptions{Limits: limits})
+	if runtime.GOOS != "linux" {
+		assert.ErrorIs(t, err, ErrResourceLimitsUnsupported)
+		return
+	}
+	assert.Nil(t, err)
+	assert.Equal(t, "64\n64\n", result["stdout"])
+
+	// Fail on commands that cannot be executed with the limits
+	_, err = RunCommandWithOptions([]string{"./does-not-exist"}, "", CommandOptions{Limits: limits})
+	assert.NotNil(t, err)
+}
+
 func TestRunCommandErrors(t *testing.T) {
 	tables := []struct {
 		CmdArgs       []string
@@ -662,6 +746,98 @@ func TestInTotoRecord(t *testing.T) {
 	}
 }
 
+// TestRecordTimes ensures that links record their start and finish times only
+// if requested
+func TestRecordTimes(t *testing.T) {
+	var validKey Key
+	if err := validKey.LoadKey("carol", "ed25519", []string{"sha256", "sha512"}); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().UTC().Truncate(time.Second)
+	options := RunOptions{LineNormalization: testOSisWindows(), RecordTimes: true}
+
+	parseTimes := func(link Link) (time.Time, time.Time) {
+		startedOn, err := time.Parse(ISO8601DateSchema, link.StartedOn)
+		assert.Nil(t, err, "invalid start time")
+		finishedOn, err := time.Parse(ISO8601DateSchema, link.FinishedOn)
+		assert.Nil(t, err, "invalid finish time")
+		return startedOn, finishedOn
+	}
+
+	result, err := InTotoRunWithOptions("Name", "", []string{"alice.pub"}, []string{"foo.tar.gz"}, nil, validKey, []string{"sha256"}, nil, nil, options)
+	assert.Nil(t, err, "unexpected error while running")
+	startedOn, finishedOn := parseTimes(result.GetPayload().(Link))
+	assert.False(t, startedOn.Before(before), "link started before run")
+	assert.False(t, finishedOn.Before(startedOn), "link finished before it started")
+
+	result, err = InTotoRun("Name", "", []string{"alice.pub"}, []string{"foo.tar.gz"}, nil, validKey, []string{"sha256"}, nil, nil, testOSisWindows(), false, false)
+	assert.Nil(t, err, "unexpected error while running")
+	assert.Empty(t, result.GetPayload().(Link).StartedOn)
+	assert.Empty(t, result.GetPayload().(Link).FinishedOn)
+
+	for _, useDSSE := range []bool{false, true} {
+		options.UseDSSE = useDSSE
+		result, err = InTotoRecordStartWithOptions("Name", []string{"alice.pub"}, validKey, []string{"sha256"}, nil, nil, options)
+		assert.Nil(t, err, "unexpected error while running record start")
+		assert.NotEmpty(t, result.GetPayload().(Link).StartedOn)
+		assert.Empty(t, result.GetPayload().(Link).FinishedOn)
+
+		result, err = InTotoRecordStopWithOptions(result, []string{"foo.tar.gz"}, validKey, []string{"sha256"}, nil, nil, options)
+		assert.Nil(t, err, "unexpected error while running record stop")
+		startedOn, finishedOn = parseTimes(result.GetPayload().(Link))
+		assert.False(t, startedOn.Before(before), "link started before record start")
+		assert.False(t, finishedOn.Before(startedOn), "link finished before it started")
+	}
+}
+
+// TestRunAttestationFormat makes sure that links are created in the attestation
+// format passed in the run options.
+func TestRunAttestationFormat(t *testing.T) {
+	var validKey Key
+	if err := validKey.LoadKey("carol", "ed25519", []string{"sha256", "sha512"}); err != nil {
+		t.Fatal(err)
+	}
+	options := RunOptions{LineNormalization: testOSisWindows(), AttestationFormat: AttestationFormatStatementV1}
+
+	checkStatement := func(result Metadata) {
+		env, ok := result.(*Envelope)
+		if !assert.True(t, ok, "statement must be in DSSE envelope") {
+			return
+		}
+		assert.Equal(t, PayloadType, env.envelope.PayloadType)
+		payload, err := env.envelope.DecodeB64Payload()
+		assert.Nil(t, err)
+		var statement linkStatement
+		assert.Nil(t, json.Unmarshal(payload, &statement))
+		assert.Equal(t, StatementInTotoV1, statement.Type)
+		assert.Equal(t, PredicateLinkV03, statement.PredicateType)
+		assert.Nil(t, result.VerifySignature(validKey))
+		assert.Len(t, env.envelope.Signatures, 1)
+		assert.Contains(t, result.GetPayload().(Link).Products, "foo.tar.gz")
+	}
+
+	result, err := InTotoRunWithOptions("Name", "", []string{"alice.pub"}, []string{"foo.tar.gz"}, nil, validKey, []string{"sha256"}, nil, nil, options)
+	assert.Nil(t, err, "unexpected error while running")
+	checkStatement(result)
+
+	// preliminary links stay in the link format
+	result, err = InTotoRecordStartWithOptions("Name", []string{"alice.pub"}, validKey, []string{"sha256"}, nil, nil, options)
+	assert.Nil(t, err, "unexpected error while running record start")
+	_, ok := result.(*Metablock)
+	assert.True(t, ok, "preliminary link must be metablock")
+	result, err = InTotoRecordStopWithOptions(result, []string{"foo.tar.gz"}, validKey, []string{"sha256"}, nil, nil, options)
+	assert.Nil(t, err, "unexpected error while running record stop")
+	checkStatement(result)
+
+	// statements cannot carry times, and unknown formats are rejected before running
+	options.RecordTimes = true
+	_, err = InTotoRunWithOptions("Name", "", nil, nil, []string{"does-not-exist"}, validKey, []string{"sha256"}, nil, nil, options)
+	assert.ErrorContains(t, err, "times cannot be recorded")
+	options = RunOptions{AttestationFormat: "unknown"}
+	_, err = InTotoRunWithOptions("Name", "", nil, nil, []string{"does-not-exist"}, validKey, []string{"sha256"}, nil, nil, options)
+	assert.ErrorContains(t, err, "unknown attestation format")
+}
+
 // TestRecordArtifactWithBlobs ensures that we calculate the same hash for blobs
 func TestRecordArtifactWithBlobs(t *testing.T) {
 	type args struct {
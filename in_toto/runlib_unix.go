@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package in_toto
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+/*
+killProcessGroupOnCancel runs the passed command in a new process group, and
+kills the whole group when the context of the command is done, such that
+processes started by the command do not outlive it.
+*/
+func killProcessGroupOnCancel(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,8 @@
+package in_toto
+
+import "os/exec"
+
+// killProcessGroupOnCancel keeps the default of killing only the command
+// itself when its context is done, because Windows has no process groups
+// that can be killed as a whole.
+func killProcessGroupOnCancel(cmd *exec.Cmd) {}
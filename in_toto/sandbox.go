@@ -0,0 +1,9 @@
+package in_toto
+
+import "errors"
+
+// ErrSandboxUnavailable signals that a command cannot be run in a sandbox, e.g. because namespaces are not available
+var ErrSandboxUnavailable = errors.New("command sandbox is unavailable")
+
+// SandboxScratchDir is the writable scratch directory of commands run in a sandbox
+const SandboxScratchDir = "/tmp"
@@ -0,0 +1,130 @@
+package in_toto
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sandboxUID and sandboxGID are the unprivileged user and group of commands run in a sandbox
+const (
+	sandboxUID = 65534
+	sandboxGID = 65534
+)
+
+/*
+configureSandbox configures the passed command, which has not been started, to
+be run in new user, mount and network namespaces, and returns the absolute path
+of the passed run directory, which the command init process binds read-only.
+The current user is mapped to the unprivileged sandboxUID, whose only
+capability is to administer the mounts of the sandbox until the command init
+process drops it.
+*/
+func configureSandbox(cmd *exec.Cmd, runDir string) (string, error) {
+	if runDir == "" {
+		runDir = "."
+	}
+	runDir, err := filepath.Abs(runDir)
+	if err != nil {
+		return "", err
+	}
+	runDir, err = filepath.EvalSymlinks(runDir)
+	if err != nil {
+		return "", err
+	}
+	if runDir == SandboxScratchDir {
+		return "", fmt.Errorf("%w: the run directory must not be the scratch directory %s",
+			ErrSandboxUnavailable, SandboxScratchDir)
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET
+	cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: sandboxUID, HostID: os.Getuid(), Size: 1}}
+	cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: sandboxGID, HostID: os.Getgid(), Size: 1}}
+	cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+	cmd.SysProcAttr.AmbientCaps = []uintptr{unix.CAP_SYS_ADMIN}
+
+	return runDir, nil
+}
+
+/*
+setupSandboxMounts makes all mounts read-only, binds the passed run directory
+read-only and mounts a writable tmpfs at SandboxScratchDir, to which TMPDIR
+points, in the mount namespace of the current process, and changes to the run
+directory.  The network namespace has no network interfaces besides an
+unconfigured loopback interface.  The run directory is opened before the tmpfs
+is mounted, such that it can also be bound if it is inside the scratch
+directory.
+*/
+func setupSandboxMounts(runDir string) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+
+	fd, err := unix.Open(runDir, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open run directory: %w", err)
+	}
+	defer unix.Close(fd)
+
+	// mounts inherited from the parent namespace cannot be made writable again
+	if err := unix.MountSetattr(-1, "/", unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}); err != nil {
+		return fmt.Errorf("failed to make mounts read-only: %w", err)
+	}
+
+	if err := mountScratchDir(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(runDir, 0755); err != nil {
+		return fmt.Errorf("failed to create run directory mount point: %w", err)
+	}
+	if err := unix.Mount(fmt.Sprintf("/proc/self/fd/%d", fd), runDir, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to bind run directory: %w", err)
+	}
+	if err := unix.MountSetattr(-1, runDir, unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}); err != nil {
+		return fmt.Errorf("failed to make run directory read-only: %w", err)
+	}
+
+	// the scratch directory was bound read-only with the run directory
+	if strings.HasPrefix(SandboxScratchDir, strings.TrimSuffix(runDir, "/")+"/") {
+		if err := mountScratchDir(); err != nil {
+			return err
+		}
+	}
+
+	if err := unix.Chdir(runDir); err != nil {
+		return fmt.Errorf("failed to change to run directory: %w", err)
+	}
+	return nil
+}
+
+// mountScratchDir mounts an empty tmpfs at SandboxScratchDir.
+func mountScratchDir() error {
+	if err := unix.Mount("tmpfs", SandboxScratchDir, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("failed to mount scratch directory: %w", err)
+	}
+	return nil
+}
+
+/*
+dropCapabilities clears the ambient capabilities of the current thread, such
+that the command it executes has no capabilities, and prevents the command
+from gaining privileges, e.g. by executing set-user-ID programs.
+*/
+func dropCapabilities() error {
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to drop capabilities: %w", err)
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no_new_privs: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package in_toto
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommandSandbox(t *testing.T) {
+	options := CommandOptions{Sandbox: true}
+	_, err := RunCommandWithOptions([]string{"true"}, "", options)
+	if !assert.NotErrorIs(t, err, ErrCommandInitNotRegistered) {
+		return
+	}
+	if errors.Is(err, ErrSandboxUnavailable) {
+		t.Skipf("namespaces are unavailable: %s", err)
+	}
+
+	runDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(runDir, "foo"), []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		name     string
+		cmdArgs  []string
+		retVal   float64
+		stdout   string
+		contains string
+	}{
+		{"read run directory", []string{"cat", "foo"}, 0, "foo", ""},
+		{"run directory is working directory", []string{"sh", "-c", "pwd"}, 0, runDir + "\n", ""},
+		{"read-only run directory", []string{"touch", "bar"}, 1, "", "Read-only file system"},
+		{"writable scratch directory", []string{"sh", "-c", "echo bar > $TMPDIR/bar && cat $TMPDIR/bar"}, 0, "bar\n", ""},
+		// only the loopback interface
+		{"no network", []string{"sh", "-c", "grep -c : /proc/net/dev"}, 0, "1\n", ""},
+		{"unprivileged user", []string{"id", "-u"}, 0, "65534\n", ""},
+		{"no remount", []string{"mount", "-o", "remount,rw", runDir}, 32, "", ""},
+	}
+
+	for _, table := range tables {
+		t.Run(table.name, func(t *testing.T) {
+			result, err := RunCommandWithOptions(table.cmdArgs, runDir, options)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, table.retVal, result["return-value"])
+			assert.Equal(t, table.stdout, result["stdout"])
+			assert.Contains(t, result["stderr"], table.contains)
+		})
+	}
+
+	_, err = os.Stat(filepath.Join(runDir, "bar"))
+	assert.True(t, os.IsNotExist(err), "expected no file written to run directory")
+
+	// Fail to write outside of the run directory, which is not in the scratch directory
+	baseDir, err := os.MkdirTemp("/var/tmp", "in_toto_sandbox")
+	if err == nil {
+		defer os.RemoveAll(baseDir)
+		outsideRunDir := filepath.Join(baseDir, "run")
+		if err := os.Mkdir(outsideRunDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		result, err := RunCommandWithOptions([]string{"touch", "../outside"}, outsideRunDir, options)
+		if assert.Nil(t, err) {
+			assert.Equal(t, float64(1), result["return-value"])
+			assert.Contains(t, result["stderr"], "Read-only file system")
+		}
+		_, err = os.Stat(filepath.Join(baseDir, "outside"))
+		assert.True(t, os.IsNotExist(err), "expected no file written outside of run directory")
+	}
+
+	// Fail to unmount the run directory
+	result, err := RunCommandWithOptions([]string{"umount", runDir}, runDir, options)
+	if assert.Nil(t, err) {
+		assert.NotEqual(t, float64(0), result["return-value"])
+	}
+
+	// Fail on commands that cannot be executed in the sandbox
+	_, err = RunCommandWithOptions([]string{"./does-not-exist"}, runDir, options)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrSandboxUnavailable))
+
+	// Fail for the scratch directory as run directory
+	_, err = RunCommandWithOptions([]string{"true"}, SandboxScratchDir, options)
+	assert.ErrorIs(t, err, ErrSandboxUnavailable)
+
+	// Run inspections in the sandbox
+	layout := Layout{Inspect: []Inspection{{
+		SupplyChainItem: SupplyChainItem{Name: "sandboxed"},
+		Run:             []string{"sh", "-c", "touch foo"},
+	}}}
+	_, err = RunInspectionsWithOptions(layout, runDir, RunOptions{Command: options})
+	if assert.NotNil(t, err) {
+		assert.True(t, strings.Contains(err.Error(), "non-zero"), err.Error())
+	}
+}
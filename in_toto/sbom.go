@@ -0,0 +1,261 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnsupportedSBOM indicates an SBOM of an unsupported format or version
+var ErrUnsupportedSBOM = errors.New("unsupported sbom")
+
+// ErrSBOMDigestMismatch indicates that SBOM components and artifacts differ
+var ErrSBOMDigestMismatch = errors.New("sbom component digests do not match artifacts")
+
+// ErrNoSBOMDigestsCompared indicates that no SBOM component could be compared with an artifact
+var ErrNoSBOMDigestsCompared = errors.New("no sbom component digest was compared with an artifact")
+
+/*
+SBOMComponent is a package of an SPDX document or a component of a CycloneDX
+BOM.  Path is the file name of the package or component, if the SBOM records
+one, and its name otherwise.  Digest uses the hash algorithm names of in-toto
+links, e.g. 'sha256'.
+*/
+type SBOMComponent struct {
+	Name    string
+	Version string
+	PURL    string
+	Path    string
+	Digest  HashObj
+}
+
+// SPDXChecksum is a checksum of an SPDX package.
+type SPDXChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+// SPDXExternalRef is an external reference of an SPDX package, e.g. its PURL.
+type SPDXExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+// SPDXPackage is a package of an SPDX document.
+type SPDXPackage struct {
+	SPDXID          string            `json:"SPDXID"`
+	Name            string            `json:"name"`
+	VersionInfo     string            `json:"versionInfo,omitempty"`
+	PackageFileName string            `json:"packageFileName,omitempty"`
+	Checksums       []SPDXChecksum    `json:"checksums,omitempty"`
+	ExternalRefs    []SPDXExternalRef `json:"externalRefs,omitempty"`
+}
+
+/*
+SPDXDocument is the subset of an SPDX 2.3 JSON document needed to cross-check
+its packages with in-toto artifacts.
+*/
+type SPDXDocument struct {
+	SPDXVersion       string        `json:"spdxVersion"`
+	SPDXID            string        `json:"SPDXID"`
+	Name              string        `json:"name"`
+	DocumentNamespace string        `json:"documentNamespace"`
+	Packages          []SPDXPackage `json:"packages,omitempty"`
+}
+
+// CycloneDXHash is a hash of a CycloneDX component.
+type CycloneDXHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// CycloneDXComponent is a, possibly nested, component of a CycloneDX BOM.
+type CycloneDXComponent struct {
+	Type       string               `json:"type"`
+	BOMRef     string               `json:"bom-ref,omitempty"`
+	Name       string               `json:"name"`
+	Version    string               `json:"version,omitempty"`
+	PURL       string               `json:"purl,omitempty"`
+	Hashes     []CycloneDXHash      `json:"hashes,omitempty"`
+	Components []CycloneDXComponent `json:"components,omitempty"`
+}
+
+/*
+CycloneDXBOM is the subset of a CycloneDX 1.5 JSON BOM needed to cross-check
+its components with in-toto artifacts.
+*/
+type CycloneDXBOM struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber,omitempty"`
+	Components   []CycloneDXComponent `json:"components,omitempty"`
+}
+
+/*
+DecodeSPDXPredicate decodes an SPDX 2.x JSON document, e.g. the predicate of a
+Statement with predicate type PredicateSPDX.
+*/
+func DecodeSPDXPredicate(predicate []byte) (SPDXDocument, error) {
+	var document SPDXDocument
+	if err := json.Unmarshal(predicate, &document); err != nil {
+		return SPDXDocument{}, fmt.Errorf("error decoding spdx document: %w", err)
+	}
+	if !strings.HasPrefix(document.SPDXVersion, "SPDX-2.") {
+		return SPDXDocument{}, fmt.Errorf("%w: spdx version '%s'", ErrUnsupportedSBOM, document.SPDXVersion)
+	}
+	return document, nil
+}
+
+/*
+DecodeCycloneDXPredicate decodes a CycloneDX 1.x JSON BOM, e.g. the predicate
+of a Statement with predicate type PredicateCycloneDX.
+*/
+func DecodeCycloneDXPredicate(predicate []byte) (CycloneDXBOM, error) {
+	var bom CycloneDXBOM
+	if err := json.Unmarshal(predicate, &bom); err != nil {
+		return CycloneDXBOM{}, fmt.Errorf("error decoding cyclonedx bom: %w", err)
+	}
+	if bom.BOMFormat != "CycloneDX" {
+		return CycloneDXBOM{}, fmt.Errorf("%w: bom format '%s'", ErrUnsupportedSBOM, bom.BOMFormat)
+	}
+	if !strings.HasPrefix(bom.SpecVersion, "1.") {
+		return CycloneDXBOM{}, fmt.Errorf("%w: cyclonedx version '%s'", ErrUnsupportedSBOM, bom.SpecVersion)
+	}
+	return bom, nil
+}
+
+/*
+SBOMComponents returns the packages of the SPDX document.  The PURL of a package
+is taken from its first external reference of type 'purl'.
+*/
+func (d SPDXDocument) SBOMComponents() []SBOMComponent {
+	components := []SBOMComponent{}
+	for _, pkg := range d.Packages {
+		component := SBOMComponent{
+			Name:    pkg.Name,
+			Version: pkg.VersionInfo,
+			Path:    pkg.PackageFileName,
+			Digest:  HashObj{},
+		}
+		if component.Path == "" {
+			component.Path = pkg.Name
+		}
+		for _, checksum := range pkg.Checksums {
+			component.Digest[NormalizeDigestAlgorithm(checksum.Algorithm)] = strings.ToLower(checksum.ChecksumValue)
+		}
+		for _, ref := range pkg.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				component.PURL = ref.ReferenceLocator
+				break
+			}
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+/*
+SBOMComponents returns the components of the CycloneDX BOM, including nested
+components, in depth-first order.
+*/
+func (b CycloneDXBOM) SBOMComponents() []SBOMComponent {
+	components := []SBOMComponent{}
+	var walk func([]CycloneDXComponent)
+	walk = func(cdxComponents []CycloneDXComponent) {
+		for _, cdxComponent := range cdxComponents {
+			component := SBOMComponent{
+				Name:    cdxComponent.Name,
+				Version: cdxComponent.Version,
+				PURL:    cdxComponent.PURL,
+				Path:    cdxComponent.Name,
+				Digest:  HashObj{},
+			}
+			for _, hash := range cdxComponent.Hashes {
+				component.Digest[NormalizeDigestAlgorithm(hash.Algorithm)] = strings.ToLower(hash.Content)
+			}
+			components = append(components, component)
+			walk(cdxComponent.Components)
+		}
+	}
+	walk(b.Components)
+	return components
+}
+
+/*
+DecodeSBOMComponents decodes the passed SPDX or CycloneDX predicate, depending on
+the passed predicate type, and returns its components.
+*/
+func DecodeSBOMComponents(predicateType string, predicate []byte) ([]SBOMComponent, error) {
+	switch predicateType {
+	case PredicateSPDX:
+		document, err := DecodeSPDXPredicate(predicate)
+		if err != nil {
+			return nil, err
+		}
+		return document.SBOMComponents(), nil
+	case PredicateCycloneDX:
+		bom, err := DecodeCycloneDXPredicate(predicate)
+		if err != nil {
+			return nil, err
+		}
+		return bom.SBOMComponents(), nil
+	}
+	return nil, fmt.Errorf("%w: predicate type '%s'", ErrUnsupportedSBOM, predicateType)
+}
+
+/*
+CompareSBOMDigests compares the digests of the passed SBOM components with
+the digests of the artifacts of the same path, e.g. the materials or products
+of a link.  It returns the sorted paths of all components, whose digests do
+not match, and the sorted paths of all components without artifact of the
+same path, which are not compared.  Digests only match if they have at least
+one hash algorithm in common, and agree on all common algorithms.  Components
+without digest are not compared either.
+*/
+func CompareSBOMDigests(components []SBOMComponent, artifacts map[string]HashObj) (mismatched []string, unmatched []string) {
+	mismatched = []string{}
+	unmatched = []string{}
+	for _, component := range components {
+		artifact, ok := artifacts[component.Path]
+		if !ok {
+			unmatched = append(unmatched, component.Path)
+			continue
+		}
+		if len(component.Digest) == 0 {
+			continue
+		}
+		if !matchesDigest(component.Digest, artifact) {
+			mismatched = append(mismatched, component.Path)
+		}
+	}
+	sort.Strings(mismatched)
+	sort.Strings(unmatched)
+	return mismatched, unmatched
+}
+
+/*
+VerifySBOMDigests returns an ErrSBOMDigestMismatch, if CompareSBOMDigests
+reports any mismatches for the passed components and artifacts, and an
+ErrNoSBOMDigestsCompared, if no component with digest has an artifact of the
+same path, e.g. because the SBOM names artifacts differently, such that nothing
+was verified.
+*/
+func VerifySBOMDigests(components []SBOMComponent, artifacts map[string]HashObj) error {
+	mismatched, unmatched := CompareSBOMDigests(components, artifacts)
+	if len(mismatched) > 0 {
+		return fmt.Errorf("%w: %s", ErrSBOMDigestMismatch, strings.Join(mismatched, ", "))
+	}
+
+	for _, component := range components {
+		if _, ok := artifacts[component.Path]; ok && len(component.Digest) > 0 {
+			return nil
+		}
+	}
+	if len(unmatched) > 0 {
+		return fmt.Errorf("%w: no artifacts for %s", ErrNoSBOMDigestsCompared, strings.Join(unmatched, ", "))
+	}
+	return ErrNoSBOMDigestsCompared
+}
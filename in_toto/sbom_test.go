@@ -0,0 +1,118 @@
+package in_toto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSPDXDocument = `{
+	"spdxVersion": "SPDX-2.3",
+	"SPDXID": "SPDXRef-DOCUMENT",
+	"name": "app",
+	"documentNamespace": "https://example.com/app",
+	"packages": [
+		{
+			"SPDXID": "SPDXRef-app",
+			"name": "app",
+			"versionInfo": "1.0.0",
+			"packageFileName": "app.tar.gz",
+			"checksums": [{"algorithm": "SHA256", "checksumValue": "60303AE22B998861BCE3B28F33EEC1BE758A213C86C93C076DBE9F558C11C752"}],
+			"externalRefs": [{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:generic/app@1.0.0"}]
+		},
+		{"SPDXID": "SPDXRef-lib", "name": "lib"}
+	]
+}`
+
+const testCycloneDXBOM = `{
+	"bomFormat": "CycloneDX",
+	"specVersion": "1.5",
+	"components": [
+		{
+			"type": "application",
+			"name": "app",
+			"version": "1.0.0",
+			"purl": "pkg:generic/app@1.0.0",
+			"hashes": [{"alg": "SHA-256", "content": "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"}],
+			"components": [
+				{"type": "library", "name": "lib", "hashes": [{"alg": "SHA-256", "content": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}]}
+			]
+		}
+	]
+}`
+
+func TestDecodeSBOMComponents(t *testing.T) {
+	spdxComponents, err := DecodeSBOMComponents(PredicateSPDX, []byte(testSPDXDocument))
+	if assert.Nil(t, err) {
+		assert.Equal(t, []SBOMComponent{
+			{Name: "app", Version: "1.0.0", PURL: "pkg:generic/app@1.0.0", Path: "app.tar.gz", Digest: HashObj{"sha256": testAppDigest}},
+			{Name: "lib", Path: "lib", Digest: HashObj{}},
+		}, spdxComponents)
+	}
+
+	cdxComponents, err := DecodeSBOMComponents(PredicateCycloneDX, []byte(testCycloneDXBOM))
+	if assert.Nil(t, err) {
+		assert.Equal(t, []SBOMComponent{
+			{Name: "app", Version: "1.0.0", PURL: "pkg:generic/app@1.0.0", Path: "app", Digest: HashObj{"sha256": testAppDigest}},
+			{Name: "lib", Path: "lib", Digest: HashObj{"sha256": testSourceDigest}},
+		}, cdxComponents)
+	}
+
+	invalid := []struct {
+		predicateType string
+		predicate     string
+	}{
+		{PredicateSPDX, `{"spdxVersion": "SPDX-3.0"}`},
+		{PredicateSPDX, `[]`},
+		{PredicateCycloneDX, `{"bomFormat": "SPDX", "specVersion": "1.5"}`},
+		{PredicateCycloneDX, `{"bomFormat": "CycloneDX", "specVersion": "2.0"}`},
+		{PredicateLinkV1, `{}`},
+	}
+	for _, table := range invalid {
+		_, err := DecodeSBOMComponents(table.predicateType, []byte(table.predicate))
+		assert.NotNil(t, err, table.predicate)
+	}
+}
+
+func TestVerifySBOMDigests(t *testing.T) {
+	components := []SBOMComponent{
+		{Path: "app", Digest: HashObj{"sha256": testAppDigest}},
+		{Path: "lib", Digest: HashObj{"sha256": testSourceDigest}},
+		{Path: "docs", Digest: HashObj{}},
+	}
+
+	products := map[string]HashObj{
+		"app":  {"sha256": testAppDigest, "sha512": "abc"},
+		"lib":  {"sha256": testSourceDigest},
+		"docs": {"sha256": testSourceDigest},
+	}
+	assert.Nil(t, VerifySBOMDigests(components, products))
+
+	// Components without artifact are reported, but do not fail if others were compared
+	delete(products, "lib")
+	mismatched, unmatched := CompareSBOMDigests(components, products)
+	assert.Empty(t, mismatched)
+	assert.Equal(t, []string{"lib"}, unmatched)
+	assert.Nil(t, VerifySBOMDigests(components, products))
+
+	// Nothing is compared, if SBOM and artifacts share no paths
+	disjoint := map[string]HashObj{
+		"dist/app": {"sha256": testAppDigest},
+		"dist/lib": {"sha256": testSourceDigest},
+	}
+	mismatched, unmatched = CompareSBOMDigests(components, disjoint)
+	assert.Empty(t, mismatched)
+	assert.Equal(t, []string{"app", "docs", "lib"}, unmatched)
+	assert.ErrorIs(t, VerifySBOMDigests(components, disjoint), ErrNoSBOMDigestsCompared)
+	assert.ErrorIs(t, VerifySBOMDigests(components, map[string]HashObj{}), ErrNoSBOMDigestsCompared)
+
+	// Components without digest are not compared either
+	assert.ErrorIs(t, VerifySBOMDigests(components[2:], products), ErrNoSBOMDigestsCompared)
+
+	products["lib"] = HashObj{"sha256": testAppDigest}
+	products["app"] = HashObj{"sha512": "abc"}
+	mismatched, unmatched = CompareSBOMDigests(components, products)
+	assert.Equal(t, []string{"app", "lib"}, mismatched)
+	assert.Empty(t, unmatched)
+	assert.ErrorIs(t, VerifySBOMDigests(components, products), ErrSBOMDigestMismatch)
+}
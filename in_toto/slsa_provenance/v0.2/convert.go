@@ -0,0 +1,52 @@
+package v02
+
+import (
+	"fmt"
+
+	v01 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.1"
+)
+
+// FromV01 converts a SLSA v0.1 provenance predicate to a v0.2 predicate.
+// The material the v0.1 recipe is defined in becomes the config source of the
+// invocation, and the recipe arguments and environment become the invocation
+// parameters and environment.
+// The returned slice lists the fields of the v0.1 predicate, in JSON notation,
+// that could not be mapped to the v0.2 predicate.
+func FromV01(predicate v01.ProvenancePredicate) (ProvenancePredicate, []string) {
+	unmapped := []string{}
+
+	converted := ProvenancePredicate{
+		Builder:   predicate.Builder,
+		BuildType: predicate.Recipe.Type,
+		Invocation: ProvenanceInvocation{
+			ConfigSource: ConfigSource{EntryPoint: predicate.Recipe.EntryPoint},
+			Parameters:   predicate.Recipe.Arguments,
+			Environment:  predicate.Recipe.Environment,
+		},
+		Materials: predicate.Materials,
+	}
+
+	if index := predicate.Recipe.DefinedInMaterial; index != nil {
+		if *index >= 0 && *index < len(predicate.Materials) {
+			converted.Invocation.ConfigSource.URI = predicate.Materials[*index].URI
+			converted.Invocation.ConfigSource.Digest = predicate.Materials[*index].Digest
+		} else {
+			unmapped = append(unmapped, fmt.Sprintf("recipe.definedInMaterial (no material %d)", *index))
+		}
+	}
+
+	if predicate.Metadata != nil {
+		converted.Metadata = &ProvenanceMetadata{
+			BuildStartedOn:  predicate.Metadata.BuildStartedOn,
+			BuildFinishedOn: predicate.Metadata.BuildFinishedOn,
+			Completeness: ProvenanceComplete{
+				Parameters:  predicate.Metadata.Completeness.Arguments,
+				Environment: predicate.Metadata.Completeness.Environment,
+				Materials:   predicate.Metadata.Completeness.Materials,
+			},
+			Reproducible: predicate.Metadata.Reproducible,
+		}
+	}
+
+	return converted, unmapped
+}
@@ -0,0 +1,60 @@
+package v02
+
+import (
+	"testing"
+	"time"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	v01 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromV01(t *testing.T) {
+	var testTime = time.Unix(1597826280, 0)
+	index := 0
+	material := common.ProvenanceMaterial{
+		URI:    "git+https://github.com/curl/curl-docker@master",
+		Digest: common.DigestSet{"sha1": "d6525c840a62b398424a78d792f457477135d0cf"},
+	}
+	predicate := v01.ProvenancePredicate{
+		Builder: common.ProvenanceBuilder{ID: "https://github.com/Attestations/GitHubHostedActions@v1"},
+		Recipe: v01.ProvenanceRecipe{
+			Type:              "https://github.com/Attestations/GitHubActionsWorkflow@v1",
+			DefinedInMaterial: &index,
+			EntryPoint:        "build.yaml:maketgz",
+			Arguments:         map[string]interface{}{"target": "app"},
+			Environment:       map[string]interface{}{"arch": "amd64"},
+		},
+		Metadata: &v01.ProvenanceMetadata{
+			BuildStartedOn: &testTime,
+			Completeness:   v01.ProvenanceComplete{Arguments: true, Materials: true},
+			Reproducible:   true,
+		},
+		Materials: []common.ProvenanceMaterial{material},
+	}
+
+	want := ProvenancePredicate{
+		Builder:   predicate.Builder,
+		BuildType: predicate.Recipe.Type,
+		Invocation: ProvenanceInvocation{
+			ConfigSource: ConfigSource{URI: material.URI, Digest: material.Digest, EntryPoint: "build.yaml:maketgz"},
+			Parameters:   map[string]interface{}{"target": "app"},
+			Environment:  map[string]interface{}{"arch": "amd64"},
+		},
+		Metadata: &ProvenanceMetadata{
+			BuildStartedOn: &testTime,
+			Completeness:   ProvenanceComplete{Parameters: true, Materials: true},
+			Reproducible:   true,
+		},
+		Materials: []common.ProvenanceMaterial{material},
+	}
+
+	got, unmapped := FromV01(predicate)
+	assert.Equal(t, want, got)
+	assert.Empty(t, unmapped)
+
+	index = 1
+	got, unmapped = FromV01(predicate)
+	assert.Equal(t, ConfigSource{EntryPoint: "build.yaml:maketgz"}, got.Invocation.ConfigSource)
+	assert.Len(t, unmapped, 1)
+}
@@ -0,0 +1,190 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	v02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+)
+
+const (
+	// ExternalParameterConfigSource is the external parameter, which holds
+	// the invocation config source of a v0.2 predicate converted by FromV02.
+	ExternalParameterConfigSource = "configSource"
+	// ExternalParameterParameters is the external parameter, which holds the
+	// invocation parameters of a v0.2 predicate converted by FromV02.
+	ExternalParameterParameters = "parameters"
+)
+
+// FromV02 converts a SLSA v0.2 provenance predicate to a v1 predicate.
+// The invocation config source and parameters become the external
+// parameters ExternalParameterConfigSource and ExternalParameterParameters,
+// the invocation environment becomes the internal parameters, and the
+// materials become the resolved dependencies.
+// The returned slice lists the fields of the v0.2 predicate, in JSON notation,
+// that could not be mapped to the v1 predicate.
+func FromV02(predicate v02.ProvenancePredicate) (ProvenancePredicate, []string) {
+	unmapped := []string{}
+
+	externalParameters := map[string]interface{}{}
+	configSource := predicate.Invocation.ConfigSource
+	if configSource.URI != "" || len(configSource.Digest) > 0 || configSource.EntryPoint != "" {
+		externalParameters[ExternalParameterConfigSource] = configSource
+	}
+	if predicate.Invocation.Parameters != nil {
+		externalParameters[ExternalParameterParameters] = predicate.Invocation.Parameters
+	}
+
+	dependencies := []ResourceDescriptor{}
+	for _, material := range predicate.Materials {
+		dependencies = append(dependencies, ResourceDescriptor{URI: material.URI, Digest: material.Digest})
+	}
+
+	converted := ProvenancePredicate{
+		BuildDefinition: ProvenanceBuildDefinition{
+			BuildType:            predicate.BuildType,
+			ExternalParameters:   externalParameters,
+			InternalParameters:   predicate.Invocation.Environment,
+			ResolvedDependencies: dependencies,
+		},
+		RunDetails: ProvenanceRunDetails{
+			Builder: Builder{ID: predicate.Builder.ID},
+		},
+	}
+
+	if predicate.BuildConfig != nil {
+		unmapped = append(unmapped, "buildConfig")
+	}
+
+	if predicate.Metadata != nil {
+		converted.RunDetails.BuildMetadata = BuildMetadata{
+			InvocationID: predicate.Metadata.BuildInvocationID,
+			StartedOn:    predicate.Metadata.BuildStartedOn,
+			FinishedOn:   predicate.Metadata.BuildFinishedOn,
+		}
+		if predicate.Metadata.Completeness.Parameters {
+			unmapped = append(unmapped, "metadata.completeness.parameters")
+		}
+		if predicate.Metadata.Completeness.Environment {
+			unmapped = append(unmapped, "metadata.completeness.environment")
+		}
+		if predicate.Metadata.Completeness.Materials {
+			unmapped = append(unmapped, "metadata.completeness.materials")
+		}
+		if predicate.Metadata.Reproducible {
+			unmapped = append(unmapped, "metadata.reproducible")
+		}
+	}
+
+	return converted, unmapped
+}
+
+// ToV02 converts a SLSA v1 provenance predicate to a v0.2 predicate.
+// External parameters created by FromV02 are mapped back to the invocation
+// config source and parameters, other external parameters become the
+// invocation parameters as a whole.  The internal parameters become the
+// invocation environment, and the resolved dependencies become the
+// materials, using the name of a dependency as URI if it has no URI.
+// The returned slice lists the fields of the v1 predicate, in JSON notation,
+// that could not be mapped to the v0.2 predicate.
+func ToV02(predicate ProvenancePredicate) (v02.ProvenancePredicate, []string) {
+	unmapped := []string{}
+
+	converted := v02.ProvenancePredicate{
+		Builder:   common.ProvenanceBuilder{ID: predicate.RunDetails.Builder.ID},
+		BuildType: predicate.BuildDefinition.BuildType,
+		Invocation: v02.ProvenanceInvocation{
+			Environment: predicate.BuildDefinition.InternalParameters,
+		},
+	}
+
+	if configSource, parameters, ok := fromV02ExternalParameters(predicate.BuildDefinition.ExternalParameters); ok {
+		converted.Invocation.ConfigSource = configSource
+		converted.Invocation.Parameters = parameters
+	} else {
+		converted.Invocation.Parameters = predicate.BuildDefinition.ExternalParameters
+	}
+
+	for i, dependency := range predicate.BuildDefinition.ResolvedDependencies {
+		field := fmt.Sprintf("buildDefinition.resolvedDependencies[%d]", i)
+		uri := dependency.URI
+		if uri == "" {
+			uri = dependency.Name
+		} else if dependency.Name != "" {
+			unmapped = append(unmapped, field+".name")
+		}
+		converted.Materials = append(converted.Materials, common.ProvenanceMaterial{URI: uri, Digest: dependency.Digest})
+		unmapped = append(unmapped, unmappedResourceDescriptorFields(field, dependency)...)
+	}
+
+	builder := predicate.RunDetails.Builder
+	if len(builder.Version) > 0 {
+		unmapped = append(unmapped, "runDetails.builder.version")
+	}
+	if len(builder.BuilderDependencies) > 0 {
+		unmapped = append(unmapped, "runDetails.builder.builderDependencies")
+	}
+	if len(predicate.RunDetails.Byproducts) > 0 {
+		unmapped = append(unmapped, "runDetails.byproducts")
+	}
+
+	metadata := predicate.RunDetails.BuildMetadata
+	if metadata.InvocationID != "" || metadata.StartedOn != nil || metadata.FinishedOn != nil {
+		converted.Metadata = &v02.ProvenanceMetadata{
+			BuildInvocationID: metadata.InvocationID,
+			BuildStartedOn:    metadata.StartedOn,
+			BuildFinishedOn:   metadata.FinishedOn,
+		}
+	}
+
+	return converted, unmapped
+}
+
+// fromV02ExternalParameters returns the invocation config source and
+// parameters, if the passed external parameters were created by FromV02.
+func fromV02ExternalParameters(externalParameters interface{}) (v02.ConfigSource, interface{}, bool) {
+	var configSource v02.ConfigSource
+	parameters, ok := externalParameters.(map[string]interface{})
+	if !ok || len(parameters) == 0 {
+		return configSource, nil, false
+	}
+
+	for name := range parameters {
+		if name != ExternalParameterConfigSource && name != ExternalParameterParameters {
+			return configSource, nil, false
+		}
+	}
+
+	if source, ok := parameters[ExternalParameterConfigSource]; ok {
+		// Round trip through JSON, as decoded provenance holds generic maps
+		sourceBytes, err := json.Marshal(source)
+		if err != nil {
+			return configSource, nil, false
+		}
+		if err := json.Unmarshal(sourceBytes, &configSource); err != nil {
+			return configSource, nil, false
+		}
+	}
+
+	return configSource, parameters[ExternalParameterParameters], true
+}
+
+// unmappedResourceDescriptorFields lists the fields of a resource descriptor,
+// which cannot be mapped to a v0.2 material.
+func unmappedResourceDescriptorFields(field string, descriptor ResourceDescriptor) []string {
+	unmapped := []string{}
+	if descriptor.DownloadLocation != "" {
+		unmapped = append(unmapped, field+".downloadLocation")
+	}
+	if descriptor.MediaType != "" {
+		unmapped = append(unmapped, field+".mediaType")
+	}
+	if len(descriptor.Content) > 0 {
+		unmapped = append(unmapped, field+".content")
+	}
+	if len(descriptor.Annotations) > 0 {
+		unmapped = append(unmapped, field+".annotations")
+	}
+	return unmapped
+}
@@ -0,0 +1,105 @@
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	v02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromV02(t *testing.T) {
+	var testTime = time.Unix(1597826280, 0)
+	configSource := v02.ConfigSource{
+		URI:        "git+https://github.com/curl/curl-docker@master",
+		Digest:     common.DigestSet{"sha1": "d6525c840a62b398424a78d792f457477135d0cf"},
+		EntryPoint: "build.yaml:maketgz",
+	}
+	predicate := v02.ProvenancePredicate{
+		Builder:   common.ProvenanceBuilder{ID: "https://github.com/Attestations/GitHubHostedActions@v1"},
+		BuildType: "https://github.com/Attestations/GitHubActionsWorkflow@v1",
+		Invocation: v02.ProvenanceInvocation{
+			ConfigSource: configSource,
+			Parameters:   map[string]interface{}{"target": "app"},
+			Environment:  map[string]interface{}{"arch": "amd64"},
+		},
+		BuildConfig: map[string]interface{}{"steps": []interface{}{}},
+		Metadata: &v02.ProvenanceMetadata{
+			BuildInvocationID: "42",
+			BuildStartedOn:    &testTime,
+			Completeness:      v02.ProvenanceComplete{Materials: true},
+		},
+		Materials: []common.ProvenanceMaterial{{URI: configSource.URI, Digest: configSource.Digest}},
+	}
+
+	want := ProvenancePredicate{
+		BuildDefinition: ProvenanceBuildDefinition{
+			BuildType: predicate.BuildType,
+			ExternalParameters: map[string]interface{}{
+				ExternalParameterConfigSource: configSource,
+				ExternalParameterParameters:   map[string]interface{}{"target": "app"},
+			},
+			InternalParameters:   map[string]interface{}{"arch": "amd64"},
+			ResolvedDependencies: []ResourceDescriptor{{URI: configSource.URI, Digest: configSource.Digest}},
+		},
+		RunDetails: ProvenanceRunDetails{
+			Builder:       Builder{ID: predicate.Builder.ID},
+			BuildMetadata: BuildMetadata{InvocationID: "42", StartedOn: &testTime},
+		},
+	}
+
+	got, unmapped := FromV02(predicate)
+	assert.Equal(t, want, got)
+	assert.Equal(t, []string{"buildConfig", "metadata.completeness.materials"}, unmapped)
+
+	// Downgrading restores the invocation, also after a JSON round trip
+	gotBytes, err := json.Marshal(got)
+	assert.Nil(t, err)
+	var decoded ProvenancePredicate
+	assert.Nil(t, json.Unmarshal(gotBytes, &decoded))
+
+	downgraded, unmapped := ToV02(decoded)
+	assert.Empty(t, unmapped)
+	assert.Equal(t, predicate.Builder, downgraded.Builder)
+	assert.Equal(t, predicate.BuildType, downgraded.BuildType)
+	assert.Equal(t, predicate.Invocation, downgraded.Invocation)
+	assert.Equal(t, predicate.Materials, downgraded.Materials)
+	assert.Equal(t, &v02.ProvenanceMetadata{BuildInvocationID: "42", BuildStartedOn: decoded.RunDetails.BuildMetadata.StartedOn},
+		downgraded.Metadata)
+}
+
+func TestToV02(t *testing.T) {
+	predicate := ProvenancePredicate{
+		BuildDefinition: ProvenanceBuildDefinition{
+			BuildType:          "https://example.com/type",
+			ExternalParameters: map[string]interface{}{"command": []interface{}{"make"}},
+			ResolvedDependencies: []ResourceDescriptor{
+				{Name: "src", Digest: common.DigestSet{"sha256": "abc"}},
+				{URI: "https://example.com/dep", Name: "dep", MediaType: "text/plain"},
+			},
+		},
+		RunDetails: ProvenanceRunDetails{
+			Builder:    Builder{ID: "https://example.com/builder", Version: map[string]string{"builder": "1"}},
+			Byproducts: []ResourceDescriptor{{Name: "log"}},
+		},
+	}
+
+	got, unmapped := ToV02(predicate)
+	assert.Equal(t, "https://example.com/builder", got.Builder.ID)
+	assert.Equal(t, "https://example.com/type", got.BuildType)
+	assert.Equal(t, map[string]interface{}{"command": []interface{}{"make"}}, got.Invocation.Parameters)
+	assert.Equal(t, v02.ConfigSource{}, got.Invocation.ConfigSource)
+	assert.Equal(t, []common.ProvenanceMaterial{
+		{URI: "src", Digest: common.DigestSet{"sha256": "abc"}},
+		{URI: "https://example.com/dep"},
+	}, got.Materials)
+	assert.Nil(t, got.Metadata)
+	assert.Equal(t, []string{
+		"buildDefinition.resolvedDependencies[1].name",
+		"buildDefinition.resolvedDependencies[1].mediaType",
+		"runDetails.builder.version",
+		"runDetails.byproducts",
+	}, unmapped)
+}
@@ -0,0 +1,302 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	linkv0 "github.com/in-toto/attestation/go/predicates/link/v0"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/common"
+	slsa01 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.1"
+	slsa02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// AttestationFormatLink is the attestation format of in-toto links.
+	AttestationFormatLink = "link"
+	// AttestationFormatStatementV1 is the attestation format of ITE-6 v1
+	// Statements with a PredicateLinkV03 predicate.
+	AttestationFormatStatementV1 = "statement-v1"
+)
+
+// ErrUnsupportedPredicate indicates that a Statement cannot be used as link
+var ErrUnsupportedPredicate = errors.New("statement predicate type cannot be used as link")
+
+// statementResource is a subject or resource descriptor of a Statement.
+type statementResource struct {
+	Name   string           `json:"name"`
+	URI    string           `json:"uri"`
+	Digest common.DigestSet `json:"digest"`
+}
+
+// linkStatement contains the parts of an ITE-6 Statement, which are needed to
+// use it as link.
+type linkStatement struct {
+	Type          string              `json:"_type"`
+	Subject       []statementResource `json:"subject"`
+	PredicateType string              `json:"predicateType"`
+	Predicate     json.RawMessage     `json:"predicate"`
+}
+
+// linkPredicateV03 is an in-toto link predicate of type PredicateLinkV03.
+type linkPredicateV03 struct {
+	Name        string              `json:"name"`
+	Command     []string            `json:"command"`
+	Materials   []statementResource `json:"materials"`
+	ByProducts  map[string]any      `json:"byproducts"`
+	Environment map[string]any      `json:"environment"`
+}
+
+// provenancePredicate contains the materials of SLSA v0.1 and v0.2 provenance.
+type provenancePredicate struct {
+	Materials []statementResource `json:"materials"`
+}
+
+// provenancePredicateV1 contains the resolved dependencies of SLSA v1 provenance.
+type provenancePredicateV1 struct {
+	BuildDefinition struct {
+		ResolvedDependencies []statementResource `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+}
+
+/*
+addStatementResources adds the passed resources to the passed artifacts, using
+the name of a resource or, if it has no name, its URI as artifact path.  It
+returns an error if a resource has neither, or if it conflicts with an
+artifact of the same path.
+*/
+func addStatementResources(artifacts map[string]HashObj, resources []statementResource) error {
+	for _, resource := range resources {
+		path := resource.Name
+		if path == "" {
+			path = resource.URI
+		}
+		if path == "" {
+			return fmt.Errorf("statement resource without name or uri")
+		}
+
+		digests, ok := artifacts[path]
+		if !ok {
+			digests = HashObj{}
+			artifacts[path] = digests
+		}
+		for algorithm, digest := range resource.Digest {
+			if existing, ok := digests[algorithm]; ok && existing != digest {
+				return fmt.Errorf("conflicting %s digests for artifact '%s'", algorithm, path)
+			}
+			digests[algorithm] = digest
+		}
+	}
+	return nil
+}
+
+/*
+linkFromStatement creates a Link from an ITE-6 Statement, whose predicate is
+an in-toto link or SLSA provenance, so that the Statement can be used as
+evidence for a step during verification.  The subjects of the Statement are
+used as products.  Materials are taken from the materials of link, SLSA v0.1
+and SLSA v0.2 predicates, and from the resolved dependencies of SLSA v1
+predicates.  Other predicate types result in an ErrUnsupportedPredicate.
+*/
+func linkFromStatement(payloadBytes []byte) (Link, error) {
+	var statement linkStatement
+	if err := json.Unmarshal(payloadBytes, &statement); err != nil {
+		return Link{}, fmt.Errorf("error decoding statement: %w", err)
+	}
+
+	link := Link{
+		Type:        "link",
+		Materials:   map[string]HashObj{},
+		Products:    map[string]HashObj{},
+		ByProducts:  map[string]any{},
+		Command:     []string{},
+		Environment: map[string]any{},
+	}
+
+	var materials []statementResource
+	switch statement.PredicateType {
+	case PredicateLinkV1:
+		var predicate Link
+		if err := json.Unmarshal(statement.Predicate, &predicate); err != nil {
+			return Link{}, fmt.Errorf("error decoding link predicate: %w", err)
+		}
+		link.Name = predicate.Name
+		for path, digests := range predicate.Materials {
+			materials = append(materials, statementResource{Name: path, Digest: digests})
+		}
+		for path, digests := range predicate.Products {
+			link.Products[path] = digests
+		}
+		if predicate.Command != nil {
+			link.Command = predicate.Command
+		}
+		if predicate.ByProducts != nil {
+			link.ByProducts = predicate.ByProducts
+		}
+		if predicate.Environment != nil {
+			link.Environment = predicate.Environment
+		}
+		link.StartedOn = predicate.StartedOn
+		link.FinishedOn = predicate.FinishedOn
+
+	case PredicateLinkV03:
+		var predicate linkPredicateV03
+		if err := json.Unmarshal(statement.Predicate, &predicate); err != nil {
+			return Link{}, fmt.Errorf("error decoding link predicate: %w", err)
+		}
+		link.Name = predicate.Name
+		materials = predicate.Materials
+		if predicate.Command != nil {
+			link.Command = predicate.Command
+		}
+		if predicate.ByProducts != nil {
+			link.ByProducts = predicate.ByProducts
+		}
+		if predicate.Environment != nil {
+			link.Environment = predicate.Environment
+		}
+
+	case slsa01.PredicateSLSAProvenance, slsa02.PredicateSLSAProvenance:
+		var predicate provenancePredicate
+		if err := json.Unmarshal(statement.Predicate, &predicate); err != nil {
+			return Link{}, fmt.Errorf("error decoding provenance predicate: %w", err)
+		}
+		materials = predicate.Materials
+
+	case slsa1.PredicateSLSAProvenance:
+		var predicate provenancePredicateV1
+		if err := json.Unmarshal(statement.Predicate, &predicate); err != nil {
+			return Link{}, fmt.Errorf("error decoding provenance predicate: %w", err)
+		}
+		materials = predicate.BuildDefinition.ResolvedDependencies
+
+	default:
+		return Link{}, fmt.Errorf("%w: %s", ErrUnsupportedPredicate, statement.PredicateType)
+	}
+
+	if err := addStatementResources(link.Materials, materials); err != nil {
+		return Link{}, err
+	}
+	if err := addStatementResources(link.Products, statement.Subject); err != nil {
+		return Link{}, err
+	}
+
+	return link, nil
+}
+
+/*
+artifactsToResourceDescriptors converts link artifacts to resource descriptors
+sorted by artifact path.
+*/
+func artifactsToResourceDescriptors(artifacts map[string]HashObj) []*ita1.ResourceDescriptor {
+	descriptors := []*ita1.ResourceDescriptor{}
+	for _, path := range sortedArtifactPaths(artifacts) {
+		descriptors = append(descriptors, &ita1.ResourceDescriptor{
+			Name:   path,
+			Digest: artifacts[path],
+		})
+	}
+	return descriptors
+}
+
+/*
+LinkToStatementV1 creates an ITE-6 v1 Statement from the passed Link.  The
+products of the link are the subjects of the Statement and the remaining parts
+of the link are its PredicateLinkV03 predicate.  As Statements require at least
+one subject, links without products cannot be converted.  The predicate has no
+start and finish times, thus links that record them cannot be converted either.
+*/
+func LinkToStatementV1(link Link) (*ita1.Statement, error) {
+	if link.StartedOn != "" || link.FinishedOn != "" {
+		return nil, fmt.Errorf("link '%s' records start and finish times, which the %s predicate cannot carry", link.Name, PredicateLinkV03)
+	}
+
+	byProducts, err := structpb.NewStruct(link.ByProducts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid link byproducts: %w", err)
+	}
+	environment, err := structpb.NewStruct(link.Environment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid link environment: %w", err)
+	}
+
+	predicateBytes, err := protojson.Marshal(&linkv0.Link{
+		Name:        link.Name,
+		Command:     link.Command,
+		Materials:   artifactsToResourceDescriptors(link.Materials),
+		Byproducts:  byProducts,
+		Environment: environment,
+	})
+	if err != nil {
+		return nil, err
+	}
+	predicate := &structpb.Struct{}
+	if err := protojson.Unmarshal(predicateBytes, predicate); err != nil {
+		return nil, err
+	}
+
+	statement := &ita1.Statement{
+		Type:          ita1.StatementTypeUri,
+		Subject:       artifactsToResourceDescriptors(link.Products),
+		PredicateType: PredicateLinkV03,
+		Predicate:     predicate,
+	}
+	if err := statement.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid statement for link '%s': %w", link.Name, err)
+	}
+
+	return statement, nil
+}
+
+/*
+NewLinkStatementEnvelope creates a DSSE envelope with an ITE-6 v1 Statement
+created from the passed Link by LinkToStatementV1, and signs it with the passed
+key, unless the key is the zero value.  As for loaded Statements, the payload of
+the envelope is the Link derived from the Statement.
+*/
+func NewLinkStatementEnvelope(link Link, key Key) (*Envelope, error) {
+	statement, err := LinkToStatementV1(link)
+	if err != nil {
+		return nil, err
+	}
+
+	statementBytes, err := protojson.Marshal(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStatementEnvelope(json.RawMessage(statementBytes), key)
+}
+
+/*
+newStatementEnvelope creates a DSSE envelope with the passed Statement, which
+must be usable as link, and signs it with the passed key, unless the key is the
+zero value.
+*/
+func newStatementEnvelope(statement any, key Key) (*Envelope, error) {
+	env := &Envelope{}
+	if err := env.SetPayload(statement); err != nil {
+		return nil, err
+	}
+
+	payloadBytes, err := env.envelope.DecodeB64Payload()
+	if err != nil {
+		return nil, err
+	}
+	if env.payload, err = linkFromStatement(payloadBytes); err != nil {
+		return nil, err
+	}
+
+	if !reflect.ValueOf(key).IsZero() {
+		if err := env.Sign(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return env, nil
+}
@@ -0,0 +1,272 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testSourceDigest = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	testAppDigest    = "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"
+)
+
+func TestLinkFromStatement(t *testing.T) {
+	subject := []map[string]any{{"name": "app", "digest": map[string]string{"sha256": testAppDigest}}}
+	sourceResource := []map[string]any{{"uri": "git+https://example.com/src", "digest": map[string]string{"sha256": testSourceDigest}}}
+	expectedProducts := map[string]HashObj{"app": {"sha256": testAppDigest}}
+	expectedMaterials := map[string]HashObj{"git+https://example.com/src": {"sha256": testSourceDigest}}
+
+	tables := []struct {
+		name              string
+		statementType     string
+		predicateType     string
+		predicate         map[string]any
+		expectedName      string
+		expectedCommand   []string
+		expectedMaterials map[string]HashObj
+	}{
+		{
+			"link v1 predicate", StatementInTotoV01, PredicateLinkV1,
+			map[string]any{
+				"_type": "link", "name": "build", "command": []string{"make"},
+				"materials": map[string]any{"src": map[string]string{"sha256": testSourceDigest}},
+				"products":  map[string]any{},
+			},
+			"build", []string{"make"}, map[string]HashObj{"src": {"sha256": testSourceDigest}},
+		},
+		{
+			"link v0.3 predicate", StatementInTotoV1, PredicateLinkV03,
+			map[string]any{"name": "build", "command": []string{"make"}, "materials": sourceResource},
+			"build", []string{"make"}, expectedMaterials,
+		},
+		{
+			"slsa v0.2 provenance", StatementInTotoV01, "https://slsa.dev/provenance/v0.2",
+			map[string]any{"builder": map[string]string{"id": "builder"}, "materials": sourceResource},
+			"", []string{}, expectedMaterials,
+		},
+		{
+			"slsa v1 provenance", StatementInTotoV1, "https://slsa.dev/provenance/v1",
+			map[string]any{"buildDefinition": map[string]any{"buildType": "make", "resolvedDependencies": sourceResource}},
+			"", []string{}, expectedMaterials,
+		},
+	}
+
+	for _, table := range tables {
+		payload, err := json.Marshal(map[string]any{
+			"_type":         table.statementType,
+			"subject":       subject,
+			"predicateType": table.predicateType,
+			"predicate":     table.predicate,
+		})
+		assert.Nil(t, err, table.name)
+
+		result, err := loadPayload(payload)
+		if !assert.Nil(t, err, table.name) {
+			continue
+		}
+		link, ok := result.(Link)
+		if !assert.True(t, ok, table.name) {
+			continue
+		}
+		assert.Equal(t, "link", link.Type, table.name)
+		assert.Equal(t, table.expectedName, link.Name, table.name)
+		assert.Equal(t, table.expectedCommand, link.Command, table.name)
+		assert.Equal(t, table.expectedMaterials, link.Materials, table.name)
+		assert.Equal(t, expectedProducts, link.Products, table.name)
+	}
+}
+
+func TestLinkFromStatementErrors(t *testing.T) {
+	tables := []struct {
+		name      string
+		statement string
+		err       error
+	}{
+		{"unsupported predicate", `{"_type": "https://in-toto.io/Statement/v1", "subject": [], "predicateType": "https://spdx.dev/Document", "predicate": {}}`, ErrUnsupportedPredicate},
+		{"resource without name", `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"digest": {"sha256": "abc"}}], "predicateType": "https://slsa.dev/provenance/v1", "predicate": {}}`, nil},
+		{"conflicting digests", `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "a", "digest": {"sha256": "abc"}}, {"name": "a", "digest": {"sha256": "def"}}], "predicateType": "https://slsa.dev/provenance/v1", "predicate": {}}`, nil},
+	}
+
+	for _, table := range tables {
+		_, err := linkFromStatement([]byte(table.statement))
+		if err == nil {
+			t.Errorf("expected error for %s", table.name)
+		}
+		if table.err != nil && !errors.Is(err, table.err) {
+			t.Errorf("expected %s for %s, got %s", table.err, table.name, err)
+		}
+	}
+}
+
+func TestLoadGenericStatement(t *testing.T) {
+	statement := `{"_type": "https://in-toto.io/Statement/v1", "subject": [{"name": "foo", "digest": {"sha256": "abc"}}], "predicateType": "https://spdx.dev/Document", "predicate": {"spdxVersion": "SPDX-2.3"}}`
+
+	payload, err := loadPayload([]byte(statement))
+	if !assert.Nil(t, err) {
+		return
+	}
+	generic, ok := payload.(*ParsedStatement)
+	if !assert.True(t, ok, "expected parsed statement, got %T", payload) {
+		return
+	}
+	assert.Equal(t, "https://spdx.dev/Document", generic.PredicateType)
+	if assert.Len(t, generic.Subject, 1) {
+		assert.Equal(t, "foo", generic.Subject[0].GetName())
+		assert.Equal(t, map[string]string{"sha256": "abc"}, generic.Subject[0].GetDigest())
+	}
+	// the predicate is decoded with the decoder registered for its type
+	if document, ok := generic.Predicate.(SPDXDocument); assert.True(t, ok, "expected SPDX document, got %T", generic.Predicate) {
+		assert.Equal(t, "SPDX-2.3", document.SPDXVersion)
+	}
+
+	// Generic statements can be signed, stored and loaded, but are no evidence
+	// for steps
+	var key Key
+	if err := key.LoadKeyDefaults("carol"); err != nil {
+		t.Fatal(err)
+	}
+	env := &Envelope{}
+	if err := env.SetPayload(json.RawMessage(statement)); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	linkDir := t.TempDir()
+	if err := env.Dump(filepath.Join(linkDir, fmt.Sprintf(LinkNameFormat, "sbom", key.KeyID))); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadMetadata(filepath.Join(linkDir, fmt.Sprintf(LinkNameFormat, "sbom", key.KeyID)))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, loaded.VerifySignature(key))
+
+	// Statements are loaded the same way as from attestation bundles
+	bundled, err := LoadBundleEnvelope(filepath.Join(linkDir, fmt.Sprintf(LinkNameFormat, "sbom", key.KeyID)))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, bundled.GetPayload(), loaded.GetPayload())
+
+	layout := Layout{Steps: []Step{{SupplyChainItem: SupplyChainItem{Name: "sbom"}, PubKeys: []string{key.KeyID}, Threshold: 1}}}
+	_, err = LoadLinksForLayout(layout, linkDir)
+	assert.ErrorContains(t, err, "found '0'")
+}
+
+// TestInTotoVerifyStatement verifies a layout, whose only step is satisfied by
+// a DSSE wrapped ITE-6 Statement with SLSA v1 provenance.
+func TestInTotoVerifyStatement(t *testing.T) {
+	var layoutKey, functionaryKey, functionaryPub Key
+	assert.Nil(t, layoutKey.LoadKeyDefaults("carol"))
+	assert.Nil(t, functionaryKey.LoadKeyDefaults("dan"))
+	assert.Nil(t, functionaryPub.LoadKeyDefaults("dan.pub"))
+
+	layout := Layout{
+		Type:    "layout",
+		Expires: time.Now().Add(time.Hour).UTC().Format(ISO8601DateSchema),
+		Keys:    map[string]Key{functionaryPub.KeyID: functionaryPub},
+		Steps: []Step{{
+			SupplyChainItem: SupplyChainItem{
+				Name:              "build",
+				ExpectedMaterials: [][]string{{"ALLOW", "git+https://example.com/src"}, {"DISALLOW", "*"}},
+				ExpectedProducts:  [][]string{{"ALLOW", "app"}, {"DISALLOW", "*"}},
+			},
+			PubKeys:   []string{functionaryPub.KeyID},
+			Threshold: 1,
+		}},
+		Inspect: []Inspection{},
+	}
+	layoutMb := &Metablock{Signed: layout}
+	assert.Nil(t, layoutMb.Sign(layoutKey))
+
+	for _, product := range []string{"app", "unexpected"} {
+		statement := map[string]any{
+			"_type":         StatementInTotoV1,
+			"subject":       []map[string]any{{"name": product, "digest": map[string]string{"sha256": testAppDigest}}},
+			"predicateType": "https://slsa.dev/provenance/v1",
+			"predicate": map[string]any{"buildDefinition": map[string]any{
+				"buildType":            "make",
+				"resolvedDependencies": []map[string]any{{"uri": "git+https://example.com/src", "digest": map[string]string{"sha256": testSourceDigest}}},
+			}},
+		}
+		env := &Envelope{}
+		assert.Nil(t, env.SetPayload(statement))
+		assert.Nil(t, env.Sign(functionaryKey))
+
+		linkDir := t.TempDir()
+		assert.Nil(t, env.Dump(filepath.Join(linkDir, fmt.Sprintf(LinkNameFormat, "build", functionaryKey.KeyID))))
+
+		_, err := InTotoVerify(layoutMb, map[string]Key{layoutKey.KeyID: layoutKey}, linkDir, "", nil, nil, false)
+		if product == "app" {
+			assert.Nil(t, err)
+		} else {
+			assert.ErrorContains(t, err, "unexpected")
+		}
+	}
+}
+
+func TestLinkToStatementV1(t *testing.T) {
+	link := Link{
+		Type:        "link",
+		Name:        "build",
+		Materials:   map[string]HashObj{"src": {"sha256": testSourceDigest}},
+		Products:    map[string]HashObj{"b": {"sha256": testAppDigest}, "a": {"sha256": testSourceDigest}},
+		ByProducts:  map[string]any{"return-value": 0, "stdout": "ok"},
+		Command:     []string{"make"},
+		Environment: map[string]any{},
+	}
+
+	statement, err := LinkToStatementV1(link)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, StatementInTotoV1, statement.GetType())
+	assert.Equal(t, PredicateLinkV03, statement.GetPredicateType())
+	if assert.Len(t, statement.GetSubject(), 2) {
+		assert.Equal(t, "a", statement.GetSubject()[0].GetName())
+		assert.Equal(t, "b", statement.GetSubject()[1].GetName())
+	}
+	assert.Equal(t, "build", statement.GetPredicate().GetFields()["name"].GetStringValue())
+
+	var key, pub Key
+	assert.Nil(t, key.LoadKeyDefaults("carol"))
+	assert.Nil(t, pub.LoadKeyDefaults("carol.pub"))
+	env, err := NewLinkStatementEnvelope(link, key)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, env.VerifySignature(pub))
+
+	// The statement is read back as the original link
+	path := filepath.Join(t.TempDir(), "build.link")
+	assert.Nil(t, env.Dump(path))
+	loaded, err := LoadMetadata(path)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, loaded.VerifySignature(pub))
+	loadedLink, ok := loaded.GetPayload().(Link)
+	assert.True(t, ok, "payload must be link")
+	assert.Equal(t, link.Materials, loadedLink.Materials)
+	assert.Equal(t, link.Products, loadedLink.Products)
+	assert.Equal(t, link.Command, loadedLink.Command)
+	assert.Equal(t, env.GetPayload(), loadedLink)
+
+	// The link predicate cannot carry start and finish times
+	timedLink := link
+	timedLink.StartedOn = "2024-06-01T12:00:00Z"
+	_, err = LinkToStatementV1(timedLink)
+	assert.NotNil(t, err)
+
+	// Statements require at least one subject
+	link.Products = map[string]HashObj{}
+	_, err = LinkToStatementV1(link)
+	assert.NotNil(t, err)
+}
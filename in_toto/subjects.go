@@ -0,0 +1,94 @@
+package in_toto
+
+import (
+	"fmt"
+	"sort"
+
+	ita1 "github.com/in-toto/attestation/go/v1"
+)
+
+/*
+SubjectMatches reports which subjects of a Statement are covered by local
+artifacts.  A subject is Covered if the digest of a local artifact matches its
+digest, Mismatched if it is not covered but a local artifact has its name, and
+Uncovered otherwise.  Subjects are identified by name or, if they have no
+name, by their digest in '<algorithm>:<hex digest>' format.
+*/
+type SubjectMatches struct {
+	Covered    []string
+	Mismatched []string
+	Uncovered  []string
+}
+
+// subjectLabel returns the name of a subject, or its digest if it has none.
+func subjectLabel(subject *ita1.ResourceDescriptor) string {
+	if subject.GetName() != "" {
+		return subject.GetName()
+	}
+	digest := NormalizeDigest(subject.GetDigest())
+	algorithms := make([]string, 0, len(digest))
+	for algorithm := range digest {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	if len(algorithms) == 0 {
+		return subject.GetUri()
+	}
+	return fmt.Sprintf("%s:%s", algorithms[0], digest[algorithms[0]])
+}
+
+/*
+MatchSubjects hashes the local artifacts at the passed paths, using all hash
+algorithms of the passed subjects' digests that are supported for recording
+artifacts, and reports which subjects are covered by the artifacts.  Artifacts
+are recorded as by RecordArtifacts, i.e. directories are traversed, and the
+passed exclude patterns and left-strip paths are applied.  Digest algorithm
+names are normalized using NormalizeDigestAlgorithm.  If no subject digest uses
+a supported algorithm, an ErrUnsupportedHashAlgorithm is returned.
+*/
+func MatchSubjects(subjects []*ita1.ResourceDescriptor, paths []string, excludePatterns []string, lStripPaths []string) (SubjectMatches, error) {
+	supportedHashMappings := getHashMapping()
+	algorithmSet := map[string]bool{}
+	for _, subject := range subjects {
+		for algorithm := range NormalizeDigest(subject.GetDigest()) {
+			if _, ok := supportedHashMappings[algorithm]; ok {
+				algorithmSet[algorithm] = true
+			}
+		}
+	}
+	if len(algorithmSet) == 0 {
+		return SubjectMatches{}, fmt.Errorf("%w: no subject digest uses a supported algorithm", ErrUnsupportedHashAlgorithm)
+	}
+	algorithms := make([]string, 0, len(algorithmSet))
+	for algorithm := range algorithmSet {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+
+	artifacts, err := RecordArtifacts(paths, algorithms, excludePatterns, lStripPaths, false, false)
+	if err != nil {
+		return SubjectMatches{}, err
+	}
+
+	matches := SubjectMatches{Covered: []string{}, Mismatched: []string{}, Uncovered: []string{}}
+	for _, subject := range subjects {
+		covered := false
+		for _, artifact := range artifacts {
+			if matchesDigest(subject.GetDigest(), artifact) {
+				covered = true
+				break
+			}
+		}
+
+		label := subjectLabel(subject)
+		if covered {
+			matches.Covered = append(matches.Covered, label)
+		} else if _, ok := artifacts[subject.GetName()]; ok {
+			matches.Mismatched = append(matches.Mismatched, label)
+		} else {
+			matches.Uncovered = append(matches.Uncovered, label)
+		}
+	}
+
+	return matches, nil
+}
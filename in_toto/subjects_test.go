@@ -0,0 +1,62 @@
+package in_toto
+
+import (
+	"testing"
+
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeDigestAlgorithm(t *testing.T) {
+	tables := map[string]string{
+		"sha256":    "sha256",
+		"SHA256":    "sha256",
+		"SHA-512":   "sha512",
+		"sha_384":   "sha384",
+		"sha384":    "sha384",
+		"SHA384":    "sha384",
+		"SHA-384":   "sha384",
+		"SHA3-384":  "sha3_384",
+		"SHA-1":     "sha1",
+		"SHA3-256":  "sha3_256",
+		"sha3_512":  "sha3_512",
+		"MD5":       "md5",
+		"gitCommit": "gitCommit",
+		"sha":       "sha",
+	}
+	for algorithm, expected := range tables {
+		assert.Equal(t, expected, NormalizeDigestAlgorithm(algorithm), algorithm)
+	}
+}
+
+func TestMatchSubjects(t *testing.T) {
+	fooDigest := "52947cb78b91ad01fe81cd6aef42d1f6817e92b9e6936c1e5aabb7c98514f355"
+	subjects := []*ita1.ResourceDescriptor{
+		{Name: "foo.tar.gz", Digest: map[string]string{"SHA-256": fooDigest}},
+		{Name: "renamed.tar.gz", Digest: map[string]string{"sha256": fooDigest, "gitBlob": "abc"}},
+		{Name: "helloworld", Digest: map[string]string{"sha256": testAppDigest}},
+		{Name: "missing", Digest: map[string]string{"sha256": testAppDigest}},
+		{Digest: map[string]string{"gitCommit": "abc"}},
+	}
+
+	matches, err := MatchSubjects(subjects, []string{"foo.tar.gz", "helloworld"}, nil, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, SubjectMatches{
+		Covered:    []string{"foo.tar.gz", "renamed.tar.gz"},
+		Mismatched: []string{"helloworld"},
+		Uncovered:  []string{"missing", "gitCommit:abc"},
+	}, matches)
+
+	// Artifacts are hashed with the algorithms of the subjects
+	sha3Digest, err := RecordArtifact("foo.tar.gz", []string{"sha3_256"}, false)
+	assert.Nil(t, err)
+	matches, err = MatchSubjects([]*ita1.ResourceDescriptor{{Name: "foo", Digest: map[string]string{"SHA3-256": sha3Digest["sha3_256"]}}},
+		[]string{"foo.tar.gz"}, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"foo"}, matches.Covered)
+
+	_, err = MatchSubjects(subjects[4:], []string{"foo.tar.gz"}, nil, nil)
+	assert.ErrorIs(t, err, ErrUnsupportedHashAlgorithm)
+}
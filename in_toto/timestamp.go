@@ -0,0 +1,469 @@
+package in_toto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// ErrInvalidTimestamp indicates that a timestamp token cannot be verified
+var ErrInvalidTimestamp = errors.New("invalid timestamp")
+
+const (
+	// TimestampQueryContentType is the content type of RFC 3161 timestamp requests
+	TimestampQueryContentType = "application/timestamp-query"
+	// TimestampReplyContentType is the content type of RFC 3161 timestamp responses
+	TimestampReplyContentType = "application/timestamp-reply"
+)
+
+// maxTimestampResponseSize limits the size of timestamp responses read from a TSA
+const maxTimestampResponseSize = 1 << 20
+
+var (
+	oidSignedData             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidContentTypeAttribute   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigestAttribute = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSHA256                 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+// timestampHashes maps the OIDs of the supported digest algorithms to hashes
+var timestampHashes = map[string]crypto.Hash{
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+/*
+The following types are the subset of CMS (RFC 5652) and RFC 3161 structures
+needed to request and verify timestamp tokens.  They are decoded with
+encoding/asn1 rather than an external CMS library, because verification only
+needs a single SignerInfo with signed attributes, whose signature is checked
+by crypto/x509 against a TSA certificate passed by the verifier.  Certificates,
+CRLs and unsigned attributes embedded in tokens are never evaluated, which
+keeps the trust decision out of the parser and avoids a dependency for the
+small part of CMS that timestamps use.
+*/
+
+// contentInfo is a CMS ContentInfo, see RFC 5652.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+// signedData is a CMS SignedData, see RFC 5652.
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+// encapsulatedContentInfo is a CMS EncapsulatedContentInfo, see RFC 5652.
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+// signerInfo is a CMS SignerInfo, see RFC 5652.
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// attribute is a CMS Attribute, see RFC 5652.
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// messageImprint is the hash of timestamped data, see RFC 3161.
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// accuracy is the accuracy of the time of a timestamp, see RFC 3161.
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+// tstInfo is the signed content of a timestamp token, see RFC 3161.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time     `asn1:"generalized"`
+	Accuracy       accuracy      `asn1:"optional"`
+	Ordering       bool          `asn1:"optional,default:false"`
+	Nonce          *big.Int      `asn1:"optional"`
+	TSA            asn1.RawValue `asn1:"optional,tag:0"`
+	Extensions     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// timeStampReq is a timestamp request, see RFC 3161.
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional,default:false"`
+}
+
+// pkiStatusInfo is the status of a timestamp response, see RFC 3161.
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+// timeStampResp is a timestamp response, see RFC 3161.
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+/*
+Timestamper returns an RFC 3161 timestamp token, i.e. a DER encoded CMS
+ContentInfo, for the passed data.
+*/
+type Timestamper func(data []byte) ([]byte, error)
+
+/*
+NewRFC3161Timestamper returns a Timestamper, which requests timestamp tokens
+from the time stamping authority (TSA) at the passed URL, using the HTTP
+transport defined in RFC 3161.
+*/
+func NewRFC3161Timestamper(url string) Timestamper {
+	return func(data []byte) ([]byte, error) {
+		return requestTimestamp(http.DefaultClient, url, data)
+	}
+}
+
+/*
+requestTimestamp requests a timestamp token for the SHA-256 digest of the
+passed data from the TSA at the passed URL.  The returned token must carry the
+digest and the nonce of the request, but its signature is not verified.
+*/
+func requestTimestamp(client *http.Client, url string, data []byte) ([]byte, error) {
+	digest := crypto.SHA256.New()
+	digest.Write(data)
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest.Sum(nil),
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpResponse, err := client.Post(url, TimestampQueryContentType, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timestamp request to %s failed with status %s", url, httpResponse.Status)
+	}
+	responseBytes, err := io.ReadAll(io.LimitReader(httpResponse.Body, maxTimestampResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var response timeStampResp
+	if rest, err := asn1.Unmarshal(responseBytes, &response); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("%w: malformed timestamp response", ErrInvalidTimestamp)
+	}
+	// Status 0 is granted, status 1 is granted with modifications
+	if response.Status.Status > 1 || len(response.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("timestamp request to %s was rejected with status %d: %v", url, response.Status.Status, response.Status.StatusString)
+	}
+
+	token := response.TimeStampToken.FullBytes
+	_, info, err := parseTimestampToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("%w: nonce does not match request", ErrInvalidTimestamp)
+	}
+	if err := checkMessageImprint(info.MessageImprint, data); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// parseTimestampToken parses the signed data and the timestamp info of a timestamp token.
+func parseTimestampToken(token []byte) (signedData, tstInfo, error) {
+	var content contentInfo
+	if rest, err := asn1.Unmarshal(token, &content); err != nil || len(rest) > 0 || !content.ContentType.Equal(oidSignedData) {
+		return signedData{}, tstInfo{}, fmt.Errorf("%w: token is not CMS signed data", ErrInvalidTimestamp)
+	}
+
+	var signed signedData
+	if rest, err := asn1.Unmarshal(content.Content.Bytes, &signed); err != nil || len(rest) > 0 {
+		return signedData{}, tstInfo{}, fmt.Errorf("%w: malformed signed data", ErrInvalidTimestamp)
+	}
+	if !signed.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return signedData{}, tstInfo{}, fmt.Errorf("%w: signed content is not timestamp info", ErrInvalidTimestamp)
+	}
+
+	var info tstInfo
+	if rest, err := asn1.Unmarshal(signed.EncapContentInfo.EContent, &info); err != nil || len(rest) > 0 {
+		return signedData{}, tstInfo{}, fmt.Errorf("%w: malformed timestamp info", ErrInvalidTimestamp)
+	}
+	return signed, info, nil
+}
+
+// checkMessageImprint checks that the passed message imprint is the digest of the passed data.
+func checkMessageImprint(imprint messageImprint, data []byte) error {
+	hash, ok := timestampHashes[imprint.HashAlgorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("%w: unsupported digest algorithm %s", ErrInvalidTimestamp, imprint.HashAlgorithm.Algorithm)
+	}
+	digest := hash.New()
+	digest.Write(data)
+	if !bytes.Equal(digest.Sum(nil), imprint.HashedMessage) {
+		return fmt.Errorf("%w: timestamp is not for the passed data", ErrInvalidTimestamp)
+	}
+	return nil
+}
+
+/*
+timestampSignatureAlgorithm returns the signature algorithm of a signer info
+with the passed digest algorithm and a signer with the passed key type.
+*/
+func timestampSignatureAlgorithm(hash crypto.Hash, publicKeyAlgorithm x509.PublicKeyAlgorithm) x509.SignatureAlgorithm {
+	switch publicKeyAlgorithm {
+	case x509.RSA:
+		switch hash {
+		case crypto.SHA256:
+			return x509.SHA256WithRSA
+		case crypto.SHA384:
+			return x509.SHA384WithRSA
+		case crypto.SHA512:
+			return x509.SHA512WithRSA
+		}
+	case x509.ECDSA:
+		switch hash {
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512
+		}
+	case x509.Ed25519:
+		return x509.PureEd25519
+	}
+	return x509.UnknownSignatureAlgorithm
+}
+
+/*
+verifySignerInfo verifies that the signer info signs the passed content with
+the key of the passed certificate.  The signer info must have signed
+attributes with the content type and digest of the content.
+*/
+func verifySignerInfo(signer signerInfo, content []byte, cert *x509.Certificate) error {
+	if len(signer.SignedAttrs.FullBytes) == 0 {
+		return fmt.Errorf("%w: signer info has no signed attributes", ErrInvalidTimestamp)
+	}
+	hash, ok := timestampHashes[signer.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("%w: unsupported digest algorithm %s", ErrInvalidTimestamp, signer.DigestAlgorithm.Algorithm)
+	}
+
+	// The signature is over the DER encoding of the signed attributes as SET
+	// rather than with their implicit context-specific tag
+	signedAttrs := append([]byte{}, signer.SignedAttrs.FullBytes...)
+	signedAttrs[0] = 0x31
+	var attributes []attribute
+	if rest, err := asn1.UnmarshalWithParams(signedAttrs, &attributes, "set"); err != nil || len(rest) > 0 {
+		return fmt.Errorf("%w: malformed signed attributes", ErrInvalidTimestamp)
+	}
+
+	digest := hash.New()
+	digest.Write(content)
+	hasContentType, hasDigest := false, false
+	for _, attr := range attributes {
+		if len(attr.Values) != 1 {
+			continue
+		}
+		switch {
+		case attr.Type.Equal(oidContentTypeAttribute):
+			var contentType asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(attr.Values[0].FullBytes, &contentType); err != nil || !contentType.Equal(oidTSTInfo) {
+				return fmt.Errorf("%w: signed content type is not timestamp info", ErrInvalidTimestamp)
+			}
+			hasContentType = true
+		case attr.Type.Equal(oidMessageDigestAttribute):
+			var messageDigest []byte
+			if _, err := asn1.Unmarshal(attr.Values[0].FullBytes, &messageDigest); err != nil || !bytes.Equal(messageDigest, digest.Sum(nil)) {
+				return fmt.Errorf("%w: signed digest does not match timestamp info", ErrInvalidTimestamp)
+			}
+			hasDigest = true
+		}
+	}
+	if !hasContentType || !hasDigest {
+		return fmt.Errorf("%w: signed attributes lack content type or digest", ErrInvalidTimestamp)
+	}
+
+	algorithm := timestampSignatureAlgorithm(hash, cert.PublicKeyAlgorithm)
+	if err := cert.CheckSignature(algorithm, signedAttrs, signer.Signature); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTimestamp, err)
+	}
+	return nil
+}
+
+/*
+VerifyTimestampToken verifies that the passed RFC 3161 timestamp token is for
+the passed data and is signed by one of the passed TSA certificates, and
+returns the time of the timestamp.  The signing TSA certificate must have the
+time stamping extended key usage and be valid at the time of the timestamp.
+Certificates embedded in the token are not trusted.  If no TSA certificate
+verifies the token, the returned error lists why each certificate that signed
+it was rejected.
+*/
+func VerifyTimestampToken(token []byte, data []byte, tsaCerts []*x509.Certificate) (time.Time, error) {
+	signed, info, err := parseTimestampToken(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := checkMessageImprint(info.MessageImprint, data); err != nil {
+		return time.Time{}, err
+	}
+
+	// Another signer or TSA certificate may verify the token, e.g. a renewed
+	// certificate of the same TSA, thus rejected certificates are only reported
+	certErrs := []error{}
+	for _, signer := range signed.SignerInfos {
+		for _, cert := range tsaCerts {
+			if verifySignerInfo(signer, signed.EncapContentInfo.EContent, cert) != nil {
+				continue
+			}
+
+			isTimestamping := false
+			for _, usage := range cert.ExtKeyUsage {
+				isTimestamping = isTimestamping || usage == x509.ExtKeyUsageTimeStamping
+			}
+			if !isTimestamping {
+				certErrs = append(certErrs, fmt.Errorf("TSA certificate '%s' lacks the time stamping extended key usage", cert.Subject))
+				continue
+			}
+			if info.GenTime.Before(cert.NotBefore) || info.GenTime.After(cert.NotAfter) {
+				certErrs = append(certErrs, fmt.Errorf("TSA certificate '%s' is not valid at %s", cert.Subject, info.GenTime))
+				continue
+			}
+			return info.GenTime, nil
+		}
+	}
+	if len(certErrs) > 0 {
+		return time.Time{}, fmt.Errorf("%w: %w", ErrInvalidTimestamp, errors.Join(certErrs...))
+	}
+	return time.Time{}, fmt.Errorf("%w: token is not signed by a trusted TSA", ErrInvalidTimestamp)
+}
+
+/*
+VerifySignatureTimestamp verifies the timestamp token attached to the passed
+signature, using VerifyTimestampToken, and returns the time of the timestamp.
+Timestamps are for the signature value as it appears in the metadata.
+*/
+func VerifySignatureTimestamp(sig Signature, tsaCerts []*x509.Certificate) (time.Time, error) {
+	if sig.Timestamp == "" {
+		return time.Time{}, fmt.Errorf("%w: signature has no timestamp", ErrInvalidTimestamp)
+	}
+	token, err := base64.StdEncoding.DecodeString(sig.Timestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %w", ErrInvalidTimestamp, err)
+	}
+	return VerifyTimestampToken(token, []byte(sig.Sig), tsaCerts)
+}
+
+/*
+signatureVerificationTime returns the time at which the certificate of the
+passed signature must be valid.  This is the time of the timestamp of the
+signature, if it has one and TSA certificates are passed, and the zero time,
+i.e. the current time, otherwise.  A warning is printed for timestamps that are
+ignored, because no TSA certificates are passed.
+*/
+func signatureVerificationTime(sig Signature, tsaCerts []*x509.Certificate) (time.Time, error) {
+	if sig.Timestamp == "" {
+		return time.Time{}, nil
+	}
+	if len(tsaCerts) == 0 {
+		fmt.Printf("WARNING: Ignoring timestamp of signature by '%s', because no"+
+			" timestamp authorities are configured. Its certificate must be valid"+
+			" now.\n", sig.KeyID)
+		return time.Time{}, nil
+	}
+	return VerifySignatureTimestamp(sig, tsaCerts)
+}
+
+/*
+LoadLayoutTimestampAuthorities loads the certificates of the time stamping
+authorities of the passed layout, together with the passed PEM encoded ones.
+*/
+func LoadLayoutTimestampAuthorities(layout Layout, tsaPems [][]byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for tsaID, tsa := range layout.TimestampAuthorities {
+		_, possibleCert, err := decodeAndParse([]byte(tsa.KeyVal.Certificate))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate of TSA '%s': %w", tsaID, err)
+		}
+		cert, ok := possibleCert.(*x509.Certificate)
+		if !ok {
+			return nil, fmt.Errorf("TSA '%s' has no valid certificate", tsaID)
+		}
+		certs = append(certs, cert)
+	}
+
+	for _, tsaPem := range tsaPems {
+		chain, err := splitCertificateChain(string(tsaPem))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load provided TSA certificate: %w", err)
+		}
+		for _, certPem := range chain {
+			_, possibleCert, err := decodeAndParse([]byte(certPem))
+			if err != nil {
+				return nil, fmt.Errorf("failed to load provided TSA certificate: %w", err)
+			}
+			cert, ok := possibleCert.(*x509.Certificate)
+			if !ok {
+				return nil, fmt.Errorf("provided TSA certificate is not a certificate")
+			}
+			certs = append(certs, cert)
+		}
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,349 @@
+package in_toto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testTSA struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     crypto.Signer
+}
+
+func createTestTSA(t *testing.T, extKeyUsage []x509.ExtKeyUsage) testTSA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to create TSA key: %v", err)
+	}
+	serialNumber, err := createSerialNumber()
+	if err != nil {
+		t.Fatalf("failed to create TSA serial number: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    time.Now().Add(-24 * time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  extKeyUsage,
+	}
+	cert, certPEM, err := createCert(template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("failed to create TSA cert: %v", err)
+	}
+	return testTSA{cert: cert, certPEM: certPEM, key: key}
+}
+
+// token creates a timestamp token for the passed message imprint at the passed time.
+func (tsa testTSA) token(t *testing.T, imprint messageImprint, genTime time.Time, nonce *big.Int) []byte {
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         asn1.ObjectIdentifier{1, 2, 3, 4},
+		MessageImprint: imprint,
+		SerialNumber:   big.NewInt(1),
+		GenTime:        genTime.UTC().Truncate(time.Second),
+		Nonce:          nonce,
+	})
+	assert.Nil(t, err)
+
+	contentType, err := asn1.Marshal(oidTSTInfo)
+	assert.Nil(t, err)
+	infoDigest := sha256.Sum256(info)
+	messageDigest, err := asn1.Marshal(infoDigest[:])
+	assert.Nil(t, err)
+	signedAttrs, err := asn1.MarshalWithParams([]attribute{
+		{Type: oidContentTypeAttribute, Values: []asn1.RawValue{{FullBytes: contentType}}},
+		{Type: oidMessageDigestAttribute, Values: []asn1.RawValue{{FullBytes: messageDigest}}},
+	}, "set")
+	assert.Nil(t, err)
+	attrsDigest := sha256.Sum256(signedAttrs)
+	signature, err := tsa.key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	assert.Nil(t, err)
+	implicitAttrs := append([]byte{}, signedAttrs...)
+	implicitAttrs[0] = 0xa0
+
+	sid, err := asn1.Marshal(struct {
+		Issuer       asn1.RawValue
+		SerialNumber *big.Int
+	}{asn1.RawValue{FullBytes: tsa.cert.RawIssuer}, tsa.cert.SerialNumber})
+	assert.Nil(t, err)
+
+	sha256Algorithm := pkix.AlgorithmIdentifier{Algorithm: oidSHA256}
+	signed, err := asn1.Marshal(signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Algorithm},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidTSTInfo, EContent: info},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    sha256Algorithm,
+			SignedAttrs:        asn1.RawValue{FullBytes: implicitAttrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+			Signature:          signature,
+		}},
+	})
+	assert.Nil(t, err)
+
+	token, err := asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signed},
+	})
+	assert.Nil(t, err)
+	return token
+}
+
+// timestamper returns a Timestamper that timestamps data at the passed time.
+func (tsa testTSA) timestamper(t *testing.T, genTime time.Time) Timestamper {
+	return func(data []byte) ([]byte, error) {
+		digest := sha256.Sum256(data)
+		return tsa.token(t, messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256}, HashedMessage: digest[:]}, genTime, nil), nil
+	}
+}
+
+func TestVerifyTimestampToken(t *testing.T) {
+	tsa := createTestTSA(t, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping})
+	otherTSA := createTestTSA(t, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping})
+	noEKUTSA := createTestTSA(t, nil)
+	genTime := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	data := []byte("signature")
+
+	// A certificate of the TSA's key without time stamping usage does not
+	// prevent verification with another certificate of the key
+	template := *tsa.cert
+	template.ExtKeyUsage = nil
+	noEKUCert, _, err := createCert(&template, &template, tsa.key.Public(), tsa.key)
+	if err != nil {
+		t.Fatalf("failed to create TSA cert: %v", err)
+	}
+
+	tables := []struct {
+		name     string
+		token    []byte
+		data     []byte
+		tsaCerts []*x509.Certificate
+		expected bool
+	}{
+		{"valid", mustTimestamp(t, tsa.timestamper(t, genTime), data), data, []*x509.Certificate{otherTSA.cert, tsa.cert}, true},
+		{"other data", mustTimestamp(t, tsa.timestamper(t, genTime), data), []byte("other"), []*x509.Certificate{tsa.cert}, false},
+		{"untrusted TSA", mustTimestamp(t, tsa.timestamper(t, genTime), data), data, []*x509.Certificate{otherTSA.cert}, false},
+		{"TSA without time stamping usage", mustTimestamp(t, noEKUTSA.timestamper(t, genTime), data), data, []*x509.Certificate{noEKUTSA.cert}, false},
+		{"other certificate of TSA key", mustTimestamp(t, tsa.timestamper(t, genTime), data), data, []*x509.Certificate{noEKUCert, tsa.cert}, true},
+		{"time outside TSA validity", mustTimestamp(t, tsa.timestamper(t, time.Now().Add(-48*time.Hour)), data), data, []*x509.Certificate{tsa.cert}, false},
+		{"malformed token", []byte("123123123"), data, []*x509.Certificate{tsa.cert}, false},
+	}
+
+	for _, table := range tables {
+		t.Run(table.name, func(t *testing.T) {
+			timestamp, err := VerifyTimestampToken(table.token, table.data, table.tsaCerts)
+			if table.expected {
+				assert.Nil(t, err)
+				assert.True(t, genTime.Equal(timestamp))
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidTimestamp)
+			}
+		})
+	}
+}
+
+func mustTimestamp(t *testing.T, timestamper Timestamper, data []byte) []byte {
+	token, err := timestamper(data)
+	if err != nil {
+		t.Fatalf("failed to timestamp: %v", err)
+	}
+	return token
+}
+
+func TestRFC3161Timestamper(t *testing.T) {
+	tsa := createTestTSA(t, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping})
+	rejecting := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, TimestampQueryContentType, r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.Nil(t, err)
+		var request timeStampReq
+		_, err = asn1.Unmarshal(body, &request)
+		assert.Nil(t, err)
+		assert.True(t, request.CertReq)
+
+		response := timeStampResp{Status: pkiStatusInfo{Status: 2}}
+		if !rejecting {
+			response = timeStampResp{TimeStampToken: asn1.RawValue{FullBytes: tsa.token(t, request.MessageImprint, time.Now(), request.Nonce)}}
+		}
+		responseBytes, err := asn1.Marshal(response)
+		assert.Nil(t, err)
+		w.Header().Set("Content-Type", TimestampReplyContentType)
+		_, _ = w.Write(responseBytes)
+	}))
+	defer server.Close()
+
+	data := []byte("signature")
+	token, err := NewRFC3161Timestamper(server.URL)(data)
+	assert.Nil(t, err)
+	_, err = VerifyTimestampToken(token, data, []*x509.Certificate{tsa.cert})
+	assert.Nil(t, err)
+
+	rejecting = true
+	_, err = NewRFC3161Timestamper(server.URL)(data)
+	assert.NotNil(t, err)
+}
+
+func TestAddTimestamp(t *testing.T) {
+	tsa := createTestTSA(t, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping})
+	var key, cert Key
+	assert.Nil(t, key.LoadKeyDefaults("example.com.write-code.key.pem"))
+	assert.Nil(t, cert.LoadKeyDefaults("example.com.write-code.cert.pem"))
+	certKey := key
+	certKey.KeyVal.Certificate = cert.KeyVal.Certificate
+	link := Link{Type: "link", Name: "write-code", Materials: map[string]HashObj{}, Products: map[string]HashObj{}}
+
+	for _, metadata := range []Metadata{&Envelope{}, &Metablock{}} {
+		switch m := metadata.(type) {
+		case *Envelope:
+			assert.Nil(t, m.SetPayload(link))
+		case *Metablock:
+			m.Signed = link
+		}
+		timestamper := tsa.timestamper(t, time.Now())
+
+		// Signatures without certificate cannot be timestamped
+		assert.Nil(t, metadata.Sign(key))
+		addTimestamp := func(keyID string) error {
+			switch m := metadata.(type) {
+			case *Envelope:
+				return m.AddTimestamp(keyID, timestamper)
+			case *Metablock:
+				return m.AddTimestamp(keyID, timestamper)
+			}
+			return nil
+		}
+		assert.NotNil(t, addTimestamp(key.KeyID))
+
+		switch m := metadata.(type) {
+		case *Envelope:
+			assert.Nil(t, m.SetPayload(link))
+		case *Metablock:
+			m.Signatures = []Signature{}
+		}
+		assert.Nil(t, metadata.Sign(certKey))
+		assert.Nil(t, addTimestamp(certKey.KeyID))
+		assert.NotNil(t, addTimestamp("unknown"))
+
+		path := filepath.Join(t.TempDir(), "write-code.link")
+		assert.Nil(t, metadata.Dump(path))
+		loaded, err := LoadMetadata(path)
+		assert.Nil(t, err)
+		sig, err := loaded.GetSignatureForKeyID(certKey.KeyID)
+		assert.Nil(t, err)
+		assert.Equal(t, cert.KeyVal.Certificate, sig.Certificate)
+		assert.NotEmpty(t, sig.Timestamp)
+		_, err = VerifySignatureTimestamp(sig, []*x509.Certificate{tsa.cert})
+		assert.Nil(t, err)
+	}
+}
+
+// TestVerifyLinkSignatureThesholdsTimestamp makes sure that links signed with
+// an expired certificate are only accepted with a timestamp by a trusted TSA
+// from the time the certificate was valid.
+func TestVerifyLinkSignatureThesholdsTimestamp(t *testing.T) {
+	tsa := createTestTSA(t, []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping})
+	// The root must be valid at the time of the timestamp
+	rootPublicKey, rootKey, err := createKeyPair(x509.Ed25519)
+	assert.Nil(t, err)
+	serialNumber, err := createSerialNumber()
+	assert.Nil(t, err)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: "Root CA"},
+		NotBefore:             time.Now().Add(-24 * time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	root, rootPEM, err := createCert(rootTemplate, rootTemplate, rootPublicKey, rootKey)
+	assert.Nil(t, err)
+
+	publicKey, privateKey, err := createKeyPair(x509.Ed25519)
+	assert.Nil(t, err)
+	serialNumber, err = createSerialNumber()
+	assert.Nil(t, err)
+	_, leafPEM, err := createCert(&x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: "step1.example.com"},
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     time.Now().Add(-time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, root, publicKey, rootKey)
+	assert.Nil(t, err)
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.Nil(t, err)
+
+	var key, rootCA, tsaKey Key
+	assert.Nil(t, key.LoadKeyReaderDefaults(bytes.NewReader(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyBytes}))))
+	key.KeyVal.Certificate = string(leafPEM)
+	assert.Nil(t, rootCA.LoadKeyReaderDefaults(bytes.NewReader(rootPEM)))
+	assert.Nil(t, tsaKey.LoadKeyReaderDefaults(bytes.NewReader(tsa.certPEM)))
+
+	rootPool := x509.NewCertPool()
+	rootPool.AddCert(root)
+
+	tables := []struct {
+		name              string
+		genTime           time.Time
+		tsas              map[string]Key
+		expectedAuthorize bool
+	}{
+		{"timestamp while valid", time.Now().Add(-90 * time.Minute), map[string]Key{tsaKey.KeyID: tsaKey}, true},
+		{"timestamp after expiry", time.Now(), map[string]Key{tsaKey.KeyID: tsaKey}, false},
+		{"no trusted TSA", time.Now().Add(-90 * time.Minute), nil, false},
+	}
+
+	for _, table := range tables {
+		t.Run(table.name, func(t *testing.T) {
+			layout := Layout{
+				Type: "layout",
+				Steps: []Step{{
+					SupplyChainItem: SupplyChainItem{Name: "step1"},
+					Threshold:       1,
+					CertificateConstraints: []CertificateConstraint{{
+						CommonName: "step1.example.com",
+						Roots:      []string{"*"},
+					}},
+				}},
+				RootCas:              map[string]Key{rootCA.KeyID: rootCA},
+				TimestampAuthorities: table.tsas,
+			}
+
+			env := &Envelope{}
+			assert.Nil(t, env.SetPayload(Link{Type: "link", Name: "step1", Materials: map[string]HashObj{}, Products: map[string]HashObj{}}))
+			assert.Nil(t, env.Sign(key))
+			assert.Nil(t, env.AddTimestamp(key.KeyID, tsa.timestamper(t, table.genTime)))
+
+			stepsMetadata := map[string]map[string]Metadata{"step1": {key.KeyID: env}}
+			_, err := VerifyLinkSignatureThesholds(layout, stepsMetadata, rootPool, x509.NewCertPool())
+			if table.expectedAuthorize {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
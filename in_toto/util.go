@@ -152,6 +152,22 @@ func (s Set) IsSubSet(subset Set) bool {
 	return true
 }
 
+/*
+loadMetablockPayload loads the signed part of a Metablock like loadPayload, but
+rejects Statements that cannot be used as link, which are only supported in
+DSSE envelopes.
+*/
+func loadMetablockPayload(payloadBytes []byte) (any, error) {
+	payload, err := loadPayload(payloadBytes)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := payload.(*ParsedStatement); ok {
+		return nil, fmt.Errorf("%w: statements must be signed in DSSE envelopes", ErrUnknownMetadataType)
+	}
+	return payload, nil
+}
+
 func loadPayload(payloadBytes []byte) (any, error) {
 	var payload map[string]any
 	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
@@ -184,6 +200,16 @@ func loadPayload(payloadBytes []byte) (any, error) {
 		}
 
 		return layout, nil
+	} else if payload["_type"] == StatementInTotoV01 || payload["_type"] == StatementInTotoV1 {
+		// Statements with link or provenance predicates are used as links,
+		// others are decoded as in attestation bundles, e.g. verification
+		// summaries, SBOMs or custom predicates, which can be loaded, signed
+		// and merged, but are no evidence for steps
+		link, err := linkFromStatement(payloadBytes)
+		if !errors.Is(err, ErrUnsupportedPredicate) {
+			return link, err
+		}
+		return decodeStatement(payloadBytes)
 	}
 
 	return nil, ErrUnknownMetadataType
@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
@@ -43,6 +44,17 @@ non-zero exit code, the first return value is an empty Metablock map and the
 second return value is the error.
 */
 func RunInspections(layout Layout, runDir string, lineNormalization bool, useDSSE bool) (map[string]Metadata, error) {
+	return RunInspectionsWithOptions(layout, runDir, RunOptions{LineNormalization: lineNormalization, UseDSSE: useDSSE})
+}
+
+/*
+RunInspectionsWithOptions provides the same functionality as RunInspections,
+but takes its optional settings as RunOptions, e.g. to restrict the execution
+of inspection commands via the Command options.  FollowSymlinkDirs,
+RecordTimes and Environment are applied to the created links as in
+InTotoRunWithOptions.
+*/
+func RunInspectionsWithOptions(layout Layout, runDir string, options RunOptions) (map[string]Metadata, error) {
 	inspectionMetadata := make(map[string]Metadata)
 
 	for _, inspection := range layout.Inspect {
@@ -52,8 +64,8 @@ func RunInspections(layout Layout, runDir string, lineNormalization bool, useDSS
 			paths = []string{runDir}
 		}
 
-		linkEnv, err := InTotoRun(inspection.Name, runDir, paths, paths,
-			inspection.Run, Key{}, []string{"sha256"}, nil, nil, lineNormalization, false, useDSSE)
+		linkEnv, err := InTotoRunWithOptions(inspection.Name, runDir, paths, paths,
+			inspection.Run, Key{}, []string{"sha256"}, nil, nil, options)
 
 		if err != nil {
 			return nil, err
@@ -510,6 +522,59 @@ func LoadLayoutCertificates(layout Layout, intermediatePems [][]byte) (*x509.Cer
 	return rootPool, intermediatePool, nil
 }
 
+/*
+ThresholdError is returned if a step has fewer links with a valid signature
+from an authorized functionary than its threshold requires.  SignerErrors maps
+the key IDs of the signers of rejected links to the reason for rejecting them,
+e.g. a *CertConstraintsError that explains why the certificate of a signer
+matches none of the certificate constraints of the step.
+*/
+type ThresholdError struct {
+	Step         string
+	Threshold    int
+	Verified     int
+	Available    int
+	SignerErrors map[string]error
+}
+
+// Error lists the reasons for rejecting links, ordered by signer key ID.
+func (e *ThresholdError) Error() string {
+	msg := fmt.Sprintf("step '%s' requires '%d' link metadata file(s)."+
+		" '%d' out of '%d' available link(s) have a valid signature from an"+
+		" authorized signer", e.Step, e.Threshold, e.Verified, e.Available)
+	if e.Available == 0 {
+		return msg + ": no links found"
+	}
+
+	reasons := make([]string, 0, len(e.SignerErrors))
+	for _, keyID := range e.signerKeyIDs() {
+		reasons = append(reasons, fmt.Sprintf("'%s': %s", keyID, e.SignerErrors[keyID]))
+	}
+	if len(reasons) == 0 {
+		return msg
+	}
+	return msg + ": " + strings.Join(reasons, "; ")
+}
+
+// Unwrap returns the reasons for rejecting links, ordered by signer key ID.
+func (e *ThresholdError) Unwrap() []error {
+	errs := make([]error, 0, len(e.SignerErrors))
+	for _, keyID := range e.signerKeyIDs() {
+		errs = append(errs, e.SignerErrors[keyID])
+	}
+	return errs
+}
+
+// signerKeyIDs returns the sorted key IDs of the signers of rejected links.
+func (e *ThresholdError) signerKeyIDs() []string {
+	keyIDs := make([]string, 0, len(e.SignerErrors))
+	for keyID := range e.SignerErrors {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+	return keyIDs
+}
+
 /*
 VerifyLinkSignatureThesholds verifies that for each step of the passed layout,
 there are at least Threshold links, validly signed by different authorized
@@ -532,11 +597,31 @@ links with valid signatures from distinct functionaries and has the format:
 
 If for any step of the layout there are not enough links available, the first
 return value is an empty map of Metablock maps and the second return value is
-the error.
+a *ThresholdError.
 */
 func VerifyLinkSignatureThesholds(layout Layout,
 	stepsMetadata map[string]map[string]Metadata, rootCertPool, intermediateCertPool *x509.CertPool) (
 	map[string]map[string]Metadata, error) {
+	crls, err := LoadLayoutCRLs(layout, nil)
+	if err != nil {
+		return nil, err
+	}
+	tsaCerts, err := LoadLayoutTimestampAuthorities(layout, nil)
+	if err != nil {
+		return nil, err
+	}
+	return verifyLinkSignatureThresholds(layout, stepsMetadata, rootCertPool, intermediateCertPool, crls, tsaCerts)
+}
+
+/*
+verifyLinkSignatureThresholds implements VerifyLinkSignatureThesholds, checking
+the certificates of functionaries that are not listed in the PubKeys of a step
+against the passed CRLs, at the time of the signature's timestamp if it is
+issued by one of the passed TSAs.
+*/
+func verifyLinkSignatureThresholds(layout Layout,
+	stepsMetadata map[string]map[string]Metadata, rootCertPool, intermediateCertPool *x509.CertPool, crls *CRLSet, tsaCerts []*x509.Certificate) (
+	map[string]map[string]Metadata, error) {
 	// This will stores links with valid signature from an authorized functionary
 	// for all steps
 	stepsMetadataVerified := make(map[string]map[string]Metadata)
@@ -544,17 +629,14 @@ func VerifyLinkSignatureThesholds(layout Layout,
 	// Try to find enough (>= threshold) links each with a valid signature from
 	// distinct authorized functionaries for each step
 	for _, step := range layout.Steps {
-		var stepErr error
+		// This will store the reason for rejecting the link of each signer
+		signerErrs := make(map[string]error)
 
 		// This will store links with valid signature from an authorized
 		// functionary for the given step
 		linksPerStepVerified := make(map[string]Metadata)
 
-		// Check if there are any links at all for the given step
-		linksPerStep, ok := stepsMetadata[step.Name]
-		if !ok || len(linksPerStep) < 1 {
-			stepErr = fmt.Errorf("no links found")
-		}
+		linksPerStep := stepsMetadata[step.Name]
 
 		// For each link corresponding to a step, check that the signer key was
 		// authorized, the layout contains a verification key and the signature
@@ -579,26 +661,46 @@ func VerifyLinkSignatureThesholds(layout Layout,
 			if !isAuthorizedSignature {
 				sig, err := linkEnv.GetSignatureForKeyID(signerKeyID)
 				if err != nil {
-					stepErr = err
+					signerErrs[signerKeyID] = err
 					continue
 				}
 
 				cert, err := sig.GetCertificate()
 				if err != nil {
-					stepErr = err
+					signerErrs[signerKeyID] = err
+					continue
+				}
+
+				sigIntermediatePool, err := extendIntermediatePool(intermediateCertPool, sig)
+				if err != nil {
+					signerErrs[signerKeyID] = err
+					continue
+				}
+
+				// certificates are checked at the time of a trusted timestamp, if any
+				verificationTime, err := signatureVerificationTime(sig, tsaCerts)
+				if err != nil {
+					signerErrs[signerKeyID] = err
 					continue
 				}
 
 				// test certificate against the step's constraints to make sure it's a valid functionary
-				err = step.CheckCertConstraints(cert, layout.RootCAIDs(), rootCertPool, intermediateCertPool)
+				err = step.CheckCertConstraintsAt(cert, layout.RootCAIDs(), rootCertPool, sigIntermediatePool, verificationTime)
+				if err != nil {
+					signerErrs[signerKeyID] = err
+					continue
+				}
+
+				// reject certificates revoked by a CA of their chain of trust
+				err = checkCertRevocation(cert, rootCertPool, sigIntermediatePool, crls, verificationTime)
 				if err != nil {
-					stepErr = err
+					signerErrs[signerKeyID] = err
 					continue
 				}
 
 				err = linkEnv.VerifySignature(cert)
 				if err != nil {
-					stepErr = err
+					signerErrs[signerKeyID] = err
 					continue
 				}
 
@@ -610,16 +712,206 @@ func VerifyLinkSignatureThesholds(layout Layout,
 		stepsMetadataVerified[step.Name] = linksPerStepVerified
 
 		if len(linksPerStepVerified) < step.Threshold {
-			linksPerStep := stepsMetadata[step.Name]
-			return nil, fmt.Errorf("step '%s' requires '%d' link metadata file(s)."+
-				" '%d' out of '%d' available link(s) have a valid signature from an"+
-				" authorized signer: %v", step.Name, step.Threshold,
-				len(linksPerStepVerified), len(linksPerStep), stepErr)
+			return nil, &ThresholdError{
+				Step:         step.Name,
+				Threshold:    step.Threshold,
+				Verified:     len(linksPerStepVerified),
+				Available:    len(linksPerStep),
+				SignerErrors: signerErrs,
+			}
 		}
 	}
 	return stepsMetadataVerified, nil
 }
 
+/*
+extendIntermediatePool returns a copy of the passed intermediate certificate
+pool, which additionally contains the intermediate certificates attached to
+the passed signature.  Attached intermediates only help to build a chain to a
+trusted root, they are never trusted by themselves.
+*/
+func extendIntermediatePool(intermediateCertPool *x509.CertPool, sig Signature) (*x509.CertPool, error) {
+	intermediates, err := sig.GetIntermediates()
+	if err != nil {
+		return nil, err
+	}
+	if len(intermediates) == 0 {
+		return intermediateCertPool, nil
+	}
+
+	pool := x509.NewCertPool()
+	if intermediateCertPool != nil {
+		pool = intermediateCertPool.Clone()
+	}
+	for _, intermediate := range intermediates {
+		pool.AddCert(intermediate)
+	}
+	return pool, nil
+}
+
+// ErrStaleLink indicates that a link finished longer ago than the maximum link age of its step
+var ErrStaleLink = errors.New("link is older than the maximum link age")
+
+// ErrLinkOrder indicates that a link started before a link of a step it must be after finished
+var ErrLinkOrder = errors.New("link violates the order of steps")
+
+// ErrMissingLinkTime indicates that a link does not record a time that a step constraint requires
+var ErrMissingLinkTime = errors.New("link does not record its start and finish times")
+
+// ErrFutureLink indicates that a link finished after the verification time
+var ErrFutureLink = errors.New("link finished after the verification time")
+
+/*
+MaxLinkTimeSkew is the time by which the finish time of a link may be after the
+verification time, to allow for clock skew between functionaries and verifiers.
+*/
+const MaxLinkTimeSkew = time.Minute
+
+/*
+VerifyLinkTimes verifies the start and finish times recorded in the links of
+the passed steps metadata against the MaxLinkAge and After constraints of the
+steps of the passed layout.  A link of a step with a MaxLinkAge must have
+finished at most MaxLinkAge before the passed verification time, and at most
+MaxLinkTimeSkew after it, and a link of
+a step with After must have started after all remaining links of the listed
+steps finished.  Links that violate the constraints, or do not record the times
+they require, are dropped.  If fewer than Threshold links remain for a step,
+the first return value is nil and the second return value is the error.
+Metadata that is not a link, e.g. of sublayouts, is not checked.  The returned
+map of link metadata per steps has the format of the passed one.
+*/
+func VerifyLinkTimes(layout Layout, stepsMetadata map[string]map[string]Metadata,
+	verificationTime time.Time) (map[string]map[string]Metadata, error) {
+	// Drop stale links first, so that only fresh links determine the order
+	freshMetadata := make(map[string]map[string]Metadata)
+	for _, step := range layout.Steps {
+		if step.MaxLinkAge == "" {
+			freshMetadata[step.Name] = stepsMetadata[step.Name]
+			continue
+		}
+		maxLinkAge, err := time.ParseDuration(step.MaxLinkAge)
+		if err != nil {
+			return nil, err
+		}
+
+		freshMetadata[step.Name], err = filterStepLinks(step, stepsMetadata[step.Name],
+			"time constraints", func(link Link) error {
+				finishedOn, err := parseLinkTime(link.FinishedOn)
+				if err != nil {
+					return err
+				}
+				if verificationTime.Sub(finishedOn) > maxLinkAge {
+					return fmt.Errorf("%w: finished on '%s', max link age is '%s'",
+						ErrStaleLink, link.FinishedOn, step.MaxLinkAge)
+				}
+				if finishedOn.Sub(verificationTime) > MaxLinkTimeSkew {
+					return fmt.Errorf("%w: finished on '%s', verified at '%s'",
+						ErrFutureLink, link.FinishedOn, verificationTime.UTC().Format(ISO8601DateSchema))
+				}
+				return nil
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stepsMetadataVerified := make(map[string]map[string]Metadata)
+	for _, step := range layout.Steps {
+		if len(step.After) == 0 {
+			stepsMetadataVerified[step.Name] = freshMetadata[step.Name]
+			continue
+		}
+
+		var err error
+		stepsMetadataVerified[step.Name], err = filterStepLinks(step, freshMetadata[step.Name],
+			"time constraints", func(link Link) error {
+				return verifyLinkOrder(link, step.After, freshMetadata)
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stepsMetadataVerified, nil
+}
+
+/*
+filterStepLinks returns the passed links of the passed step, except for the
+links for which the passed check returns an error.  Metadata that is not a
+link, e.g. of sublayouts, is kept.  If links are dropped and fewer than
+Threshold links remain, the first return value is nil and the second return
+value is an error, which lists the reasons for dropping links and names the
+passed constraints.
+*/
+func filterStepLinks(step Step, links map[string]Metadata, constraints string,
+	check func(Link) error) (map[string]Metadata, error) {
+	linksPerStepVerified := make(map[string]Metadata)
+	linkErrs := make(map[string]error)
+	for keyID, linkEnv := range links {
+		if link, ok := linkEnv.GetPayload().(Link); ok {
+			if err := check(link); err != nil {
+				linkErrs[keyID] = err
+				continue
+			}
+		}
+		linksPerStepVerified[keyID] = linkEnv
+	}
+
+	if len(linkErrs) != 0 && len(linksPerStepVerified) < step.Threshold {
+		keyIDs := make([]string, 0, len(linkErrs))
+		for keyID := range linkErrs {
+			keyIDs = append(keyIDs, keyID)
+		}
+		sort.Strings(keyIDs)
+
+		errs := make([]error, 0, len(keyIDs))
+		for _, keyID := range keyIDs {
+			errs = append(errs, fmt.Errorf("'%s': %w", keyID, linkErrs[keyID]))
+		}
+		return nil, fmt.Errorf("step '%s' requires '%d' link metadata file(s)."+
+			" '%d' out of '%d' link(s) satisfy the %s of the step: %w",
+			step.Name, step.Threshold, len(linksPerStepVerified), len(links),
+			constraints, errors.Join(errs...))
+	}
+	return linksPerStepVerified, nil
+}
+
+/*
+verifyLinkOrder verifies that the passed link started after all links of the
+steps with the passed names finished.
+*/
+func verifyLinkOrder(link Link, after []string, stepsMetadata map[string]map[string]Metadata) error {
+	startedOn, err := parseLinkTime(link.StartedOn)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range after {
+		for _, linkEnv := range stepsMetadata[name] {
+			previous, ok := linkEnv.GetPayload().(Link)
+			if !ok {
+				continue
+			}
+			finishedOn, err := parseLinkTime(previous.FinishedOn)
+			if err != nil {
+				return fmt.Errorf("%w: link of step '%s'", err, name)
+			}
+			if startedOn.Before(finishedOn) {
+				return fmt.Errorf("%w: started on '%s', before a link of step '%s' finished on '%s'",
+					ErrLinkOrder, link.StartedOn, name, previous.FinishedOn)
+			}
+		}
+	}
+	return nil
+}
+
+// parseLinkTime parses a link start or finish time, which must be recorded.
+func parseLinkTime(linkTime string) (time.Time, error) {
+	if linkTime == "" {
+		return time.Time{}, ErrMissingLinkTime
+	}
+	return time.Parse(ISO8601DateSchema, linkTime)
+}
+
 /*
 LoadLinksForLayout loads for every Step of the passed Layout a Metablock
 containing the corresponding Link.  A base path to a directory that contains
@@ -664,6 +956,13 @@ func LoadLinksForLayout(layout Layout, linkDir string) (map[string]map[string]Me
 			if err != nil {
 				continue
 			}
+			// Only links and sublayouts are evidence for steps, not e.g. statements
+			// with other predicates
+			switch linkEnv.GetPayload().(type) {
+			case Link, Layout:
+			default:
+				continue
+			}
 
 			// To get the full key from the metadata's signatures, we have to check
 			// for one with the same short id...
@@ -780,6 +1079,17 @@ steps carried out in the sublayout.
 func VerifySublayouts(layout Layout,
 	stepsMetadataVerified map[string]map[string]Metadata,
 	superLayoutLinkPath string, intermediatePems [][]byte, lineNormalization bool) (map[string]map[string]Metadata, error) {
+	return verifySublayouts(layout, stepsMetadataVerified, superLayoutLinkPath,
+		VerifyOptions{IntermediatePems: intermediatePems, LineNormalization: lineNormalization})
+}
+
+/*
+verifySublayouts implements VerifySublayouts, verifying sublayouts with the
+passed options.
+*/
+func verifySublayouts(layout Layout,
+	stepsMetadataVerified map[string]map[string]Metadata,
+	superLayoutLinkPath string, options VerifyOptions) (map[string]map[string]Metadata, error) {
 	for stepName, linkData := range stepsMetadataVerified {
 		for keyID, metadata := range linkData {
 			if _, ok := metadata.GetPayload().(Layout); ok {
@@ -790,8 +1100,8 @@ func VerifySublayouts(layout Layout,
 					stepName, keyID)
 				sublayoutLinkPath := filepath.Join(superLayoutLinkPath,
 					sublayoutLinkDir)
-				summaryLink, err := InTotoVerify(metadata, layoutKeys,
-					sublayoutLinkPath, stepName, make(map[string]string), intermediatePems, lineNormalization)
+				summaryLink, err := InTotoVerifyWithOptions(metadata, layoutKeys,
+					sublayoutLinkPath, stepName, make(map[string]string), options)
 				if err != nil {
 					return nil, err
 				}
@@ -823,12 +1133,29 @@ func substituteParametersInSliceOfSlices(replacer *strings.Replacer,
 	return newSlice
 }
 
+/*
+substituteParametersInMap is a helper function that substitutes parameters in
+the values of a map.  It returns a new map, or nil if the passed map is nil.
+*/
+func substituteParametersInMap(replacer *strings.Replacer,
+	m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	newMap := make(map[string]string, len(m))
+	for key, value := range m {
+		newMap[key] = replacer.Replace(value)
+	}
+	return newMap
+}
+
 /*
 SubstituteParameters performs parameter substitution in steps and inspections
 in the following fields:
 - Expected Materials and Expected Products of both
 - Run of inspections
 - Expected Command of steps
+- Values of the Expected Environment of steps
 The substitution marker is '{}' and the keyword within the braces is replaced
 by a value found in the substitution map passed, parameterDictionary. The
 layout with parameters substituted is returned to the calling function.
@@ -863,6 +1190,8 @@ func SubstituteParameters(layout Layout,
 			replacer, layout.Steps[i].ExpectedProducts)
 		layout.Steps[i].ExpectedCommand = substituteParamatersInSlice(replacer,
 			layout.Steps[i].ExpectedCommand)
+		layout.Steps[i].ExpectedEnvironment = substituteParametersInMap(replacer,
+			layout.Steps[i].ExpectedEnvironment)
 	}
 
 	for i := range layout.Inspect {
@@ -911,100 +1240,39 @@ and "delete" are currently not supported.
 func InTotoVerify(layoutEnv Metadata, layoutKeys map[string]Key,
 	linkDir string, stepName string, parameterDictionary map[string]string, intermediatePems [][]byte, lineNormalization bool) (
 	Metadata, error) {
+	return InTotoVerifyWithOptions(layoutEnv, layoutKeys, linkDir, stepName, parameterDictionary,
+		VerifyOptions{IntermediatePems: intermediatePems, LineNormalization: lineNormalization})
+}
 
-	// Verify root signatures
-	if err := VerifyLayoutSignatures(layoutEnv, layoutKeys); err != nil {
-		return nil, err
-	}
-
-	useDSSE := false
-	if _, ok := layoutEnv.(*Envelope); ok {
-		useDSSE = true
-	}
-
-	// Extract the layout from its Metadata container (for further processing)
-	layout, ok := layoutEnv.GetPayload().(Layout)
-	if !ok {
-		return nil, ErrNotLayout
-	}
-
-	// Verify layout expiration
-	if err := VerifyLayoutExpiration(layout); err != nil {
-		return nil, err
-	}
-
-	// Substitute parameters in layout
-	layout, err := SubstituteParameters(layout, parameterDictionary)
-	if err != nil {
-		return nil, err
-	}
-
-	rootCertPool, intermediateCertPool, err := LoadLayoutCertificates(layout, intermediatePems)
-	if err != nil {
-		return nil, err
-	}
-
-	// Load links for layout
-	stepsMetadata, err := LoadLinksForLayout(layout, linkDir)
-	if err != nil {
-		return nil, err
-	}
-
-	// Verify link signatures
-	stepsMetadataVerified, err := VerifyLinkSignatureThesholds(layout,
-		stepsMetadata, rootCertPool, intermediateCertPool)
-	if err != nil {
-		return nil, err
-	}
-
-	// Verify and resolve sublayouts
-	stepsSublayoutVerified, err := VerifySublayouts(layout,
-		stepsMetadataVerified, linkDir, intermediatePems, lineNormalization)
-	if err != nil {
-		return nil, err
-	}
-
-	// Verify command alignment (WARNING only)
-	VerifyStepCommandAlignment(layout, stepsSublayoutVerified)
-
-	// Given that signature thresholds have been checked above and the rest of
-	// the relevant link properties, i.e. materials and products, have to be
-	// exactly equal, we can reduce the map of steps metadata. However, we error
-	// if the relevant properties are not equal among links of a step.
-	stepsMetadataReduced, err := ReduceStepsMetadata(layout,
-		stepsSublayoutVerified)
-	if err != nil {
-		return nil, err
-	}
-
-	// Verify artifact rules
-	if err = VerifyArtifacts(layout.stepsAsInterfaceSlice(),
-		stepsMetadataReduced); err != nil {
-		return nil, err
-	}
-
-	inspectionMetadata, err := RunInspections(layout, "", lineNormalization, useDSSE)
-	if err != nil {
-		return nil, err
-	}
-
-	// Add steps metadata to inspection metadata, because inspection artifact
-	// rules may also refer to artifacts reported by step links
-	for k, v := range stepsMetadataReduced {
-		inspectionMetadata[k] = v
-	}
-
-	if err = VerifyArtifacts(layout.inspectAsInterfaceSlice(),
-		inspectionMetadata); err != nil {
-		return nil, err
-	}
-
-	summaryLink, err := GetSummaryLink(layout, stepsMetadataReduced, stepName, useDSSE)
-	if err != nil {
-		return nil, err
-	}
+/*
+VerifyOptions holds the optional settings of InTotoVerifyWithOptions.
+IntermediatePems are PEM encoded certificates, which are used as intermediates
+in addition to the intermediate CAs of the layout.  CRLPems are PEM or DER
+encoded certificate revocation lists, which are used in addition to the CRLs of
+the layout, see LoadLayoutCRLs.  LineNormalization enables line ending
+normalization when hashing artifacts.  TSACertPems are PEM encoded
+certificates of time stamping authorities, which are trusted in addition to
+those of the layout to timestamp link signatures, see VerifyTimestampToken.
+InspectionCommand restricts the execution of the inspection commands of the
+layout, e.g. with a timeout, a clean environment or resource limits, see
+CommandOptions.
+*/
+type VerifyOptions struct {
+	IntermediatePems  [][]byte
+	CRLPems           [][]byte
+	TSACertPems       [][]byte
+	LineNormalization bool
+	InspectionCommand CommandOptions
+}
 
-	return summaryLink, nil
+/*
+InTotoVerifyWithOptions provides the same functionality as InTotoVerify, but
+takes its optional settings as VerifyOptions.
+*/
+func InTotoVerifyWithOptions(layoutEnv Metadata, layoutKeys map[string]Key,
+	linkDir string, stepName string, parameterDictionary map[string]string, options VerifyOptions) (
+	Metadata, error) {
+	return inTotoVerify(layoutEnv, layoutKeys, linkDir, "", stepName, parameterDictionary, options)
 }
 
 /*
@@ -1052,6 +1320,18 @@ func InTotoVerifyWithDirectory(layoutEnv Metadata, layoutKeys map[string]Key,
 		return nil, err
 	}
 
+	return inTotoVerify(layoutEnv, layoutKeys, linkDir, runDir, stepName, parameterDictionary,
+		VerifyOptions{IntermediatePems: intermediatePems, LineNormalization: lineNormalization})
+}
+
+/*
+inTotoVerify implements InTotoVerify and InTotoVerifyWithDirectory, running
+inspections in the passed runDir.
+*/
+func inTotoVerify(layoutEnv Metadata, layoutKeys map[string]Key,
+	linkDir string, runDir string, stepName string, parameterDictionary map[string]string, options VerifyOptions) (
+	Metadata, error) {
+
 	// Verify root signatures
 	if err := VerifyLayoutSignatures(layoutEnv, layoutKeys); err != nil {
 		return nil, err
@@ -1074,12 +1354,22 @@ func InTotoVerifyWithDirectory(layoutEnv Metadata, layoutKeys map[string]Key,
 	}
 
 	// Substitute parameters in layout
-	layout, err = SubstituteParameters(layout, parameterDictionary)
+	layout, err := SubstituteParameters(layout, parameterDictionary)
 	if err != nil {
 		return nil, err
 	}
 
-	rootCertPool, intermediateCertPool, err := LoadLayoutCertificates(layout, intermediatePems)
+	rootCertPool, intermediateCertPool, err := LoadLayoutCertificates(layout, options.IntermediatePems)
+	if err != nil {
+		return nil, err
+	}
+
+	crls, err := LoadLayoutCRLs(layout, options.CRLPems)
+	if err != nil {
+		return nil, err
+	}
+
+	tsaCerts, err := LoadLayoutTimestampAuthorities(layout, options.TSACertPems)
 	if err != nil {
 		return nil, err
 	}
@@ -1091,15 +1381,29 @@ func InTotoVerifyWithDirectory(layoutEnv Metadata, layoutKeys map[string]Key,
 	}
 
 	// Verify link signatures
-	stepsMetadataVerified, err := VerifyLinkSignatureThesholds(layout,
-		stepsMetadata, rootCertPool, intermediateCertPool)
+	stepsMetadataVerified, err := verifyLinkSignatureThresholds(layout,
+		stepsMetadata, rootCertPool, intermediateCertPool, crls, tsaCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify link start and finish times
+	stepsMetadataVerified, err = VerifyLinkTimes(layout,
+		stepsMetadataVerified, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify link environments
+	stepsMetadataVerified, err = VerifyLinkEnvironments(layout,
+		stepsMetadataVerified)
 	if err != nil {
 		return nil, err
 	}
 
 	// Verify and resolve sublayouts
-	stepsSublayoutVerified, err := VerifySublayouts(layout,
-		stepsMetadataVerified, linkDir, intermediatePems, lineNormalization)
+	stepsSublayoutVerified, err := verifySublayouts(layout,
+		stepsMetadataVerified, linkDir, options)
 	if err != nil {
 		return nil, err
 	}
@@ -1123,7 +1427,11 @@ func InTotoVerifyWithDirectory(layoutEnv Metadata, layoutKeys map[string]Key,
 		return nil, err
 	}
 
-	inspectionMetadata, err := RunInspections(layout, runDir, lineNormalization, useDSSE)
+	inspectionMetadata, err := RunInspectionsWithOptions(layout, runDir, RunOptions{
+		LineNormalization: options.LineNormalization,
+		UseDSSE:           useDSSE,
+		Command:           options.InspectionCommand,
+	})
 	if err != nil {
 		return nil, err
 	}
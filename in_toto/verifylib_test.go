@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"errors"
 	"fmt"
 	"os"
 	"path"
@@ -326,6 +327,22 @@ func TestRunInspections(t *testing.T) {
 		t.Errorf("RunInspections returned '(%s, %s)', expected"+
 			" '(nil, *exec.Error)'", result, err)
 	}
+
+	// Fail RunInspectionsWithOptions due to command exceeding its timeout
+	layout.Inspect = []Inspection{
+		{
+			SupplyChainItem: SupplyChainItem{Name: "foo"},
+			Run:             []string{"sh", "-c", "sleep 10"},
+		},
+	}
+	result, err = RunInspectionsWithOptions(layout, "", RunOptions{
+		LineNormalization: testOSisWindows(),
+		Command:           CommandOptions{Timeout: 100 * time.Millisecond},
+	})
+	if result != nil || !errors.Is(err, ErrCommandTimeout) {
+		t.Errorf("RunInspectionsWithOptions returned '(%s, %s)', expected"+
+			" '(nil, %s)'", result, err, ErrCommandTimeout)
+	}
 }
 
 func TestVerifyArtifact(t *testing.T) {
@@ -890,6 +907,198 @@ func TestVerifyLinkSignatureThesholds(t *testing.T) {
 	}
 }
 
+// TestVerifyLinkSignatureThesholdsCertChain makes sure that functionaries are
+// authorized via certificate constraints for both metadata formats, with the
+// intermediate certificate either attached to the signature or passed in a
+// pool.
+func TestVerifyLinkSignatureThesholdsCertChain(t *testing.T) {
+	var key, leaf, intermediate, root Key
+	assert.Nil(t, key.LoadKeyDefaults("example.com.write-code.key.pem"))
+	assert.Nil(t, leaf.LoadKeyDefaults("example.com.write-code.cert.pem"))
+	assert.Nil(t, intermediate.LoadKeyDefaults("example.com.intermediate.cert.pem"))
+	assert.Nil(t, root.LoadKeyDefaults("root.cert.pem"))
+
+	rootPool := x509.NewCertPool()
+	assert.True(t, rootPool.AppendCertsFromPEM([]byte(root.KeyVal.Certificate)))
+	intermediatePool := x509.NewCertPool()
+	assert.True(t, intermediatePool.AppendCertsFromPEM([]byte(intermediate.KeyVal.Certificate)))
+
+	layout := Layout{
+		Type: "layout",
+		Steps: []Step{{
+			SupplyChainItem: SupplyChainItem{Name: "write-code"},
+			Threshold:       1,
+			CertificateConstraints: []CertificateConstraint{{
+				CommonName:    "write-code.example.com",
+				Organizations: []string{"example"},
+				Roots:         []string{"*"},
+				URIs:          []string{"spiffe://example.com/write-code"},
+			}},
+		}},
+		RootCas: map[string]Key{root.KeyID: root},
+	}
+	link := Link{Type: "link", Name: "write-code", Materials: map[string]HashObj{}, Products: map[string]HashObj{}}
+
+	tables := []struct {
+		name              string
+		metadata          Metadata
+		certificate       string
+		intermediatePool  *x509.CertPool
+		expectedAuthorize bool
+	}{
+		{"dsse with attached intermediate", &Envelope{}, leaf.KeyVal.Certificate + intermediate.KeyVal.Certificate, x509.NewCertPool(), true},
+		{"dsse with intermediate pool", &Envelope{}, leaf.KeyVal.Certificate, intermediatePool, true},
+		{"dsse without intermediate", &Envelope{}, leaf.KeyVal.Certificate, x509.NewCertPool(), false},
+		{"metablock with attached intermediate", &Metablock{}, leaf.KeyVal.Certificate + intermediate.KeyVal.Certificate, nil, true},
+		{"metablock with intermediate pool", &Metablock{}, leaf.KeyVal.Certificate, intermediatePool, true},
+	}
+	for _, table := range tables {
+		signingKey := key
+		signingKey.KeyVal.Certificate = table.certificate
+
+		switch m := table.metadata.(type) {
+		case *Envelope:
+			assert.Nil(t, m.SetPayload(link), table.name)
+		case *Metablock:
+			m.Signed = link
+		}
+		assert.Nil(t, table.metadata.Sign(signingKey), table.name)
+
+		// Certificates must survive writing and reading the metadata
+		path := filepath.Join(t.TempDir(), "write-code.link")
+		assert.Nil(t, table.metadata.Dump(path), table.name)
+		loaded, err := LoadMetadata(path)
+		if !assert.Nil(t, err, table.name) {
+			continue
+		}
+		sig, err := loaded.GetSignatureForKeyID(key.KeyID)
+		assert.Nil(t, err, table.name)
+		assert.Equal(t, table.certificate, sig.Certificate, table.name)
+
+		stepsMetadata := map[string]map[string]Metadata{"write-code": {key.KeyID: loaded}}
+		_, err = VerifyLinkSignatureThesholds(layout, stepsMetadata, rootPool, table.intermediatePool)
+		if table.expectedAuthorize {
+			assert.Nil(t, err, table.name)
+		} else {
+			assert.NotNil(t, err, table.name)
+		}
+	}
+
+	// Functionaries are not authorized if a CRL required by the layout is missing
+	layout.CRLs = map[string]CRL{root.KeyID: {URI: "https://example.com/root.crl"}}
+	env := &Envelope{}
+	assert.Nil(t, env.SetPayload(link))
+	signingKey := key
+	signingKey.KeyVal.Certificate = leaf.KeyVal.Certificate
+	assert.Nil(t, env.Sign(signingKey))
+	stepsMetadata := map[string]map[string]Metadata{"write-code": {key.KeyID: env}}
+	_, err := VerifyLinkSignatureThesholds(layout, stepsMetadata, rootPool, intermediatePool)
+	assert.ErrorIs(t, err, ErrMissingCRL)
+
+	// The threshold error explains why the certificate matches no constraint
+	layout.CRLs = nil
+	layout.Steps[0].CertificateConstraints[0].CommonName = "build.example.com"
+	_, err = VerifyLinkSignatureThesholds(layout, stepsMetadata, rootPool, intermediatePool)
+	var thresholdErr *ThresholdError
+	if assert.ErrorAs(t, err, &thresholdErr) {
+		assert.Equal(t, "write-code", thresholdErr.Step)
+		assert.Equal(t, 0, thresholdErr.Verified)
+		assert.Equal(t, 1, thresholdErr.Available)
+		assert.Contains(t, thresholdErr.SignerErrors, key.KeyID)
+	}
+	var constraintsErr *CertConstraintsError
+	if assert.ErrorAs(t, err, &constraintsErr) && assert.Len(t, constraintsErr.Results, 1) {
+		result := constraintsErr.Results[0]
+		assert.False(t, result.Passed())
+		assert.Equal(t, "common name", result.Checks[0].Attribute)
+		assert.Equal(t, []string{"build.example.com"}, result.Checks[0].Expected)
+		assert.Equal(t, []string{"write-code.example.com"}, result.Checks[0].Actual)
+		assert.NotEmpty(t, result.Checks[0].Error)
+	}
+}
+
+func TestVerifyLinkTimes(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	linkAt := func(name string, startedOn, finishedOn time.Time) Metadata {
+		link := Link{Type: "link", Name: name}
+		if !startedOn.IsZero() {
+			link.StartedOn = startedOn.Format(ISO8601DateSchema)
+		}
+		if !finishedOn.IsZero() {
+			link.FinishedOn = finishedOn.Format(ISO8601DateSchema)
+		}
+		return &Metablock{Signed: link}
+	}
+	hoursAgo := func(hours int) time.Time {
+		return now.Add(-time.Duration(hours) * time.Hour)
+	}
+
+	layout := Layout{Steps: []Step{
+		{SupplyChainItem: SupplyChainItem{Name: "build"}, Threshold: 1, MaxLinkAge: "24h"},
+		{SupplyChainItem: SupplyChainItem{Name: "test"}, Threshold: 1, After: []string{"build"}},
+	}}
+
+	tables := []struct {
+		name          string
+		stepsMetadata map[string]map[string]Metadata
+		expectedErr   error
+		expectedLinks map[string]int
+	}{
+		{"fresh and ordered", map[string]map[string]Metadata{
+			"build": {"a": linkAt("build", hoursAgo(3), hoursAgo(2))},
+			"test":  {"b": linkAt("test", hoursAgo(1), now)},
+		}, nil, map[string]int{"build": 1, "test": 1}},
+		{"stale link", map[string]map[string]Metadata{
+			"build": {"a": linkAt("build", hoursAgo(49), hoursAgo(48))},
+			"test":  {"b": linkAt("test", hoursAgo(1), now)},
+		}, ErrStaleLink, nil},
+		{"stale link above threshold", map[string]map[string]Metadata{
+			"build": {"a": linkAt("build", hoursAgo(3), hoursAgo(2)), "c": linkAt("build", hoursAgo(49), hoursAgo(48))},
+			"test":  {"b": linkAt("test", hoursAgo(1), now)},
+		}, nil, map[string]int{"build": 1, "test": 1}},
+		{"future link", map[string]map[string]Metadata{
+			"build": {"a": linkAt("build", hoursAgo(3), now.Add(time.Hour))},
+			"test":  {"b": linkAt("test", now.Add(2*time.Hour), now.Add(3*time.Hour))},
+		}, ErrFutureLink, nil},
+		{"link within clock skew", map[string]map[string]Metadata{
+			"build": {"a": linkAt("build", hoursAgo(3), now.Add(30*time.Second))},
+			"test":  {"b": linkAt("test", now.Add(time.Minute), now.Add(2*time.Minute))},
+		}, nil, map[string]int{"build": 1, "test": 1}},
+		{"link out of order", map[string]map[string]Metadata{
+			"build": {"a": linkAt("build", hoursAgo(3), hoursAgo(1))},
+			"test":  {"b": linkAt("test", hoursAgo(2), now)},
+		}, ErrLinkOrder, nil},
+		{"link without finish time", map[string]map[string]Metadata{
+			"build": {"a": linkAt("build", hoursAgo(3), time.Time{})},
+			"test":  {"b": linkAt("test", hoursAgo(1), now)},
+		}, ErrMissingLinkTime, nil},
+		{"link without start time", map[string]map[string]Metadata{
+			"build": {"a": linkAt("build", hoursAgo(3), hoursAgo(2))},
+			"test":  {"b": linkAt("test", time.Time{}, now)},
+		}, ErrMissingLinkTime, nil},
+	}
+
+	for _, table := range tables {
+		t.Run(table.name, func(t *testing.T) {
+			result, err := VerifyLinkTimes(layout, table.stepsMetadata, now)
+			if table.expectedErr != nil {
+				assert.ErrorIs(t, err, table.expectedErr)
+				return
+			}
+			assert.Nil(t, err)
+			for stepName, count := range table.expectedLinks {
+				assert.Len(t, result[stepName], count, stepName)
+			}
+		})
+	}
+
+	// Steps without time constraints accept links without times
+	result, err := VerifyLinkTimes(Layout{Steps: []Step{{SupplyChainItem: SupplyChainItem{Name: "build"}, Threshold: 1}}},
+		map[string]map[string]Metadata{"build": {"a": linkAt("build", time.Time{}, time.Time{})}}, now)
+	assert.Nil(t, err)
+	assert.Len(t, result["build"], 1)
+}
+
 func TestLoadLinksForLayout(t *testing.T) {
 	keyID1 := "d3ffd1086938b3698618adf088bf14b13db4c8ae19e4e78d73da49ee88492710"
 	keyID2 := "b7d643dec0a051096ee5d87221b5d91a33daa658699d30903e1cefb90c418401"
@@ -1022,7 +1231,8 @@ func TestSubstituteParamaters(t *testing.T) {
 						"WITH", "MATERIALS", "FROM", "{SOURCE_STEP}"}},
 					ExpectedProducts: [][]string{{"CREATE", "{NEW_THING}"}},
 				},
-				ExpectedCommand: []string{"{EDITOR}"},
+				ExpectedCommand:     []string{"{EDITOR}"},
+				ExpectedEnvironment: map[string]string{"variables.EDITOR": "{EDITOR}"},
 			},
 		},
 	}
@@ -1037,6 +1247,11 @@ func TestSubstituteParamaters(t *testing.T) {
 			newLayout.Steps[0].ExpectedCommand[0])
 	}
 
+	if newLayout.Steps[0].ExpectedEnvironment["variables.EDITOR"] != "vim" {
+		t.Errorf("parameter substitution failed - expected 'vim', got %s",
+			newLayout.Steps[0].ExpectedEnvironment["variables.EDITOR"])
+	}
+
 	if newLayout.Steps[0].ExpectedProducts[0][1] != "new_thing" {
 		t.Errorf("parameter substitution failed - expected 'new_thing',"+
 			" got %s", newLayout.Steps[0].ExpectedProducts[0][1])
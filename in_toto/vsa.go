@@ -0,0 +1,144 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	vsa1 "github.com/in-toto/attestation/go/predicates/vsa/v1"
+	ita1 "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// PredicateVSAV1 is the predicate type of SLSA Verification Summary
+	// Attestations (VSA) v1.
+	PredicateVSAV1 = "https://slsa.dev/verification_summary/v1"
+	// VerificationResultPassed is the VSA verification result of a passed
+	// verification.
+	VerificationResultPassed = "PASSED"
+	// DefaultVerifierID is the VSA verifier ID used if none is configured.
+	DefaultVerifierID = "https://github.com/in-toto/in-toto-golang"
+	// DefaultVerifiedLevel is the VSA verified level used if none is
+	// configured, as the verification of a layout alone does not establish a
+	// SLSA build level.
+	DefaultVerifiedLevel = "SLSA_BUILD_LEVEL_0"
+)
+
+// ErrMissingResourceURI indicates that a VSA was requested without resource URI
+var ErrMissingResourceURI = errors.New("verification summary requires a resource uri")
+
+// ErrMissingPolicyURI indicates that a VSA was requested without policy URI
+var ErrMissingPolicyURI = errors.New("verification summary requires a policy uri")
+
+/*
+VerificationSummaryOptions configures the VSA created by
+NewVerificationSummary.  VerifierID identifies the verifier and defaults to
+DefaultVerifierID.  PolicyURI and PolicyDigest identify the verified layout,
+where PolicyURI must be resolvable by consumers of the VSA, e.g. not a local
+path.  ResourceURI is the URI of the verified resource.  Both URIs are
+required.  VerifiedLevels are the SLSA levels the resource was verified to
+meet and default to DefaultVerifiedLevel.  TimeVerified defaults to the
+current time.
+*/
+type VerificationSummaryOptions struct {
+	VerifierID     string
+	PolicyURI      string
+	PolicyDigest   HashObj
+	ResourceURI    string
+	VerifiedLevels []string
+	TimeVerified   time.Time
+}
+
+/*
+NewVerificationSummary creates an ITE-6 v1 Statement with a passed SLSA VSA
+v1 predicate for the summary link returned by InTotoVerify.  The products of
+the summary link, i.e. the final products of the supply chain, are the
+subjects of the Statement.  As Statements require at least one subject, a
+summary without products results in an error, as do options without the
+resource and policy URIs required by VSA v1.
+*/
+func NewVerificationSummary(summaryLink Link, opts VerificationSummaryOptions) (*ita1.Statement, error) {
+	if opts.ResourceURI == "" {
+		return nil, ErrMissingResourceURI
+	}
+	if opts.PolicyURI == "" {
+		return nil, ErrMissingPolicyURI
+	}
+	if len(opts.VerifiedLevels) == 0 {
+		opts.VerifiedLevels = []string{DefaultVerifiedLevel}
+	}
+	if opts.VerifierID == "" {
+		opts.VerifierID = DefaultVerifierID
+	}
+	if opts.TimeVerified.IsZero() {
+		opts.TimeVerified = time.Now()
+	}
+
+	predicateBytes, err := protojson.Marshal(&vsa1.VerificationSummary{
+		Verifier:     &vsa1.VerificationSummary_Verifier{Id: opts.VerifierID},
+		TimeVerified: timestamppb.New(opts.TimeVerified.UTC().Truncate(time.Second)),
+		ResourceUri:  opts.ResourceURI,
+		Policy: &vsa1.VerificationSummary_Policy{
+			Uri:    opts.PolicyURI,
+			Digest: opts.PolicyDigest,
+		},
+		VerificationResult: VerificationResultPassed,
+		VerifiedLevels:     opts.VerifiedLevels,
+	})
+	if err != nil {
+		return nil, err
+	}
+	predicate := &structpb.Struct{}
+	if err := protojson.Unmarshal(predicateBytes, predicate); err != nil {
+		return nil, err
+	}
+
+	statement := &ita1.Statement{
+		Type:          ita1.StatementTypeUri,
+		Subject:       artifactsToResourceDescriptors(summaryLink.Products),
+		PredicateType: PredicateVSAV1,
+		Predicate:     predicate,
+	}
+	if err := statement.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid verification summary: %w", err)
+	}
+
+	return statement, nil
+}
+
+/*
+NewVerificationSummaryEnvelope creates a DSSE envelope with the VSA Statement
+created by NewVerificationSummary, and signs it with the passed key, unless
+the key is the zero value.  Unlike for Statements usable as link, the payload
+of the envelope is the Statement itself.
+*/
+func NewVerificationSummaryEnvelope(summaryLink Link, opts VerificationSummaryOptions, key Key) (*Envelope, error) {
+	statement, err := NewVerificationSummary(summaryLink, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	statementBytes, err := protojson.Marshal(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &Envelope{}
+	if err := env.SetPayload(json.RawMessage(statementBytes)); err != nil {
+		return nil, err
+	}
+	env.payload = statement
+
+	if !reflect.ValueOf(key).IsZero() {
+		if err := env.Sign(key); err != nil {
+			return nil, err
+		}
+	}
+
+	return env, nil
+}
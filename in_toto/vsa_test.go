@@ -0,0 +1,110 @@
+package in_toto
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	vsa1 "github.com/in-toto/attestation/go/predicates/vsa/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestNewVerificationSummaryEnvelope(t *testing.T) {
+	var key, pub Key
+	assert.Nil(t, key.LoadKeyDefaults("carol"))
+	assert.Nil(t, pub.LoadKeyDefaults("carol.pub"))
+
+	summaryLink := Link{
+		Type:      "link",
+		Materials: map[string]HashObj{"src": {"sha256": testSourceDigest}},
+		Products:  map[string]HashObj{"app": {"sha256": testAppDigest}},
+	}
+	opts := VerificationSummaryOptions{
+		PolicyURI:    "https://example.com/root.layout",
+		PolicyDigest: HashObj{"sha256": testSourceDigest},
+		ResourceURI:  "https://example.com/app",
+		TimeVerified: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	env, err := NewVerificationSummaryEnvelope(summaryLink, opts, key)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, env.VerifySignature(pub))
+
+	payloadBytes, err := env.envelope.DecodeB64Payload()
+	assert.Nil(t, err)
+	var statement map[string]any
+	assert.Nil(t, json.Unmarshal(payloadBytes, &statement))
+	assert.Equal(t, StatementInTotoV1, statement["_type"])
+	assert.Equal(t, PredicateVSAV1, statement["predicateType"])
+	assert.Equal(t, []any{map[string]any{"name": "app", "digest": map[string]any{"sha256": testAppDigest}}}, statement["subject"])
+	assert.Equal(t, map[string]any{
+		"verifier":           map[string]any{"id": DefaultVerifierID},
+		"timeVerified":       "2024-01-01T12:00:00Z",
+		"resourceUri":        "https://example.com/app",
+		"policy":             map[string]any{"uri": "https://example.com/root.layout", "digest": map[string]any{"sha256": testSourceDigest}},
+		"verificationResult": VerificationResultPassed,
+		"verifiedLevels":     []any{DefaultVerifiedLevel},
+	}, statement["predicate"])
+
+	// VSAs require final products
+	noProductsLink := summaryLink
+	noProductsLink.Products = map[string]HashObj{}
+	_, err = NewVerificationSummaryEnvelope(noProductsLink, opts, key)
+	assert.NotNil(t, err)
+
+	// VSAs require resource and policy URIs
+	noResourceOpts := opts
+	noResourceOpts.ResourceURI = ""
+	_, err = NewVerificationSummaryEnvelope(summaryLink, noResourceOpts, key)
+	assert.ErrorIs(t, err, ErrMissingResourceURI)
+	noPolicyOpts := opts
+	noPolicyOpts.PolicyURI = ""
+	_, err = NewVerificationSummaryEnvelope(summaryLink, noPolicyOpts, key)
+	assert.ErrorIs(t, err, ErrMissingPolicyURI)
+}
+
+// TestVerificationSummaryFields decodes VSAs with the VSA v1 schema, which
+// rejects unknown fields, and makes sure all required fields are set.
+func TestVerificationSummaryFields(t *testing.T) {
+	summaryLink := Link{Type: "link", Products: map[string]HashObj{"app": {"sha256": testAppDigest}}}
+
+	tables := []struct {
+		name     string
+		levels   []string
+		expected []string
+	}{
+		{"default verified levels", nil, []string{DefaultVerifiedLevel}},
+		{"passed verified levels", []string{"SLSA_BUILD_LEVEL_3", "FOO_LEVEL_1"}, []string{"SLSA_BUILD_LEVEL_3", "FOO_LEVEL_1"}},
+	}
+	for _, table := range tables {
+		env, err := NewVerificationSummaryEnvelope(summaryLink, VerificationSummaryOptions{
+			PolicyURI:      "https://example.com/root.layout",
+			PolicyDigest:   HashObj{"sha256": testSourceDigest},
+			ResourceURI:    "https://example.com/app",
+			VerifiedLevels: table.levels,
+		}, Key{})
+		if !assert.Nil(t, err, table.name) {
+			continue
+		}
+		payloadBytes, err := env.envelope.DecodeB64Payload()
+		assert.Nil(t, err, table.name)
+		var statement struct {
+			Predicate json.RawMessage `json:"predicate"`
+		}
+		assert.Nil(t, json.Unmarshal(payloadBytes, &statement), table.name)
+
+		var predicate vsa1.VerificationSummary
+		if !assert.Nil(t, protojson.Unmarshal(statement.Predicate, &predicate), table.name) {
+			continue
+		}
+		assert.Equal(t, DefaultVerifierID, predicate.GetVerifier().GetId(), table.name)
+		assert.NotNil(t, predicate.GetTimeVerified(), table.name)
+		assert.Equal(t, "https://example.com/app", predicate.GetResourceUri(), table.name)
+		assert.Equal(t, "https://example.com/root.layout", predicate.GetPolicy().GetUri(), table.name)
+		assert.Equal(t, VerificationResultPassed, predicate.GetVerificationResult(), table.name)
+		assert.Equal(t, table.expected, predicate.GetVerifiedLevels(), table.name)
+	}
+}
@@ -88,6 +88,10 @@ func (s SVIDDetails) InTotoKey() (intoto.Key, error) {
 		return key, fmt.Errorf("failed to load key from spire: %w", err)
 	}
 
+	// Attach the intermediates, so that they can be carried in signatures
 	key.KeyVal.Certificate = string(pem.EncodeToMemory(&pem.Block{Bytes: s.Certificate.Raw, Type: "CERTIFICATE"}))
+	for _, intermediate := range s.Intermediates {
+		key.KeyVal.Certificate += string(pem.EncodeToMemory(&pem.Block{Bytes: intermediate.Raw, Type: "CERTIFICATE"}))
+	}
 	return key, nil
 }
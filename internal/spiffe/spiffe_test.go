@@ -39,7 +39,8 @@ func assertInTotoKey(tb testing.TB, key intoto.Key, svid *x509svid.SVID) {
 	privKey, _ := x509.ParseCertificate(certData.Bytes)
 	pubKeyBytes, _ := x509.MarshalPKIXPublicKey(privKey.PublicKey)
 	assert.Equal(tb, strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{Bytes: pubKeyBytes, Type: "PUBLIC KEY"}))), key.KeyVal.Public)
-	assert.Equal(tb, string(pem.EncodeToMemory(&pem.Block{Bytes: svid.Certificates[0].Raw, Type: "CERTIFICATE"})), key.KeyVal.Certificate)
+	// The certificate is followed by any intermediates of the SVID
+	assert.Equal(tb, string(cerBytes), key.KeyVal.Certificate)
 
 }
 
@@ -2,8 +2,12 @@ package main
 
 import (
 	"github.com/in-toto/in-toto-golang/cmd"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
 )
 
 func main() {
+	// commands run with resource limits or in a sandbox re-execute in-toto
+	intoto.MaybeRunCommandInit()
+
 	cmd.Execute()
 }